@@ -0,0 +1,63 @@
+require 'rspec'
+require 'json'
+require 'bosh/template/test'
+
+describe 'turbulence_agent job' do
+  let(:job) { Bosh::Template::Test::ReleaseDir.new(File.join(File.dirname(__FILE__), '..')).job('turbulence_agent') }
+  let(:template) { job.template('config/config.json') }
+
+  let(:agent_ca_cert) { 'agent-ca-cert' }
+
+  let(:api_link) do
+    Bosh::Template::Test::Link.new(
+      name: 'api',
+      instances: [Bosh::Template::Test::LinkInstance.new(address: '10.0.0.5')],
+      properties: {
+        'advertised_host' => '',
+        'agent_listen_port' => 8081,
+        'agent_channel_port' => 0,
+        'cert' => {'ca' => 'api-ca-cert'},
+        'agent_ca_cert' => agent_ca_cert,
+        'username' => 'turbulence',
+        'password' => 'operator-password',
+      },
+    )
+  end
+
+  let(:properties) do
+    {'api_client_ca' => {'certificate' => 'agent-ca-cert', 'private_key' => 'agent-ca-key'}}
+  end
+
+  def render(properties, index)
+    instance = Bosh::Template::Test::InstanceSpec.new(name: 'agent', id: "agent-id-#{index}", index: index)
+    JSON.parse(template.render(properties, spec: instance, consumes: [api_link]))
+  end
+
+  it 'renders config that lets each of multiple instances issue its own client certificate' do
+    configs = [render(properties, 0), render(properties, 1)]
+
+    configs.each do |config|
+      expect(config['AgentID']).to eq('_agent_id_')
+      expect(config['API']['ClientCACert']).to eq('agent-ca-cert')
+      expect(config['API']['ClientCAKey']).to eq('agent-ca-key')
+      expect(config['API']).to_not include('ClientCert', 'ClientKey', 'Username', 'Password')
+    end
+
+    expect(configs[0]).to eq(configs[1])
+  end
+
+  it 'refuses to render without client CA when API server requires client certificates' do
+    expect { render({}, 0) }.to raise_error(/Expected 'api_client_ca' to be specified/)
+  end
+
+  context 'when API server does not require client certificates' do
+    let(:agent_ca_cert) { '' }
+
+    it 'renders shared credentials for all instances' do
+      [render({}, 0), render({}, 1)].each do |config|
+        expect(config['API']['Username']).to eq('turbulence')
+        expect(config['API']['Password']).to eq('operator-password')
+      end
+    end
+  end
+end
@@ -1,23 +1,31 @@
 package main
 
 import (
+	"strings"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 
+	"github.com/cppforlife/turbulence/agentreg"
 	"github.com/cppforlife/turbulence/tasks"
 	"github.com/cppforlife/turbulence/tasks/monit"
 )
 
 type Agent struct {
-	agentID     string
-	agentConfig AgentConfig
+	agentID      string
+	agentConfig  AgentConfig
+	capabilities agentreg.Capabilities
 
-	client        Client
-	monitProvider monit.ClientProvider
+	client        APIClient
+	monitProvider monit.Provider
 	cmdRunner     boshsys.CmdRunner
+	journal       tasks.Journal
+	containers    tasks.ContainerFinder
+
+	running *runningTasks
+	locks   *resourceLocks
 
 	logTag string
 	logger boshlog.Logger
@@ -27,139 +35,351 @@ type agentTask interface {
 	Execute(stopCh chan struct{}) error
 }
 
+// Must be kept in sync with buildAgentTask
+var supportedTaskTypes = []string{
+	tasks.OptionsType(tasks.NoopOptions{}),
+	tasks.OptionsType(tasks.KillProcessOptions{}),
+	tasks.OptionsType(tasks.StressOptions{}),
+	tasks.OptionsType(tasks.ControlNetOptions{}),
+	tasks.OptionsType(tasks.FirewallOptions{}),
+	tasks.OptionsType(tasks.FillDiskOptions{}),
+	tasks.OptionsType(tasks.ShutdownOptions{}),
+}
+
+func isSupportedTaskType(taskType string) bool {
+	for _, t := range supportedTaskTypes {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
 type AgentConfig struct {
 	APIHost string
 	APIPort int
 
+	// Zero when agent does not use agent channel
+	APIChannelPort int
+
+	// Empty when agent does not run on a BOSH VM
 	BOSHMbusHost string
 	BOSHMbusPort int
+
+	ExtraOutputDests []tasks.FirewallTaskDest
+
+	// Zero value disables stopping of tasks when API is unreachable
+	MaxAPIDisconnection time.Duration
+
+	// Empty value allows all supported task types
+	AllowedTaskTypes []string
+}
+
+func (c AgentConfig) IsTaskTypeAllowed(taskType string) bool {
+	if len(c.AllowedTaskTypes) == 0 {
+		return true
+	}
+
+	for _, t := range c.AllowedTaskTypes {
+		if t == taskType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c AgentConfig) DisallowedTaskTypes() []string {
+	var types []string
+
+	for _, t := range supportedTaskTypes {
+		if !c.IsTaskTypeAllowed(t) {
+			types = append(types, t)
+		}
+	}
+
+	return types
 }
 
 func (c AgentConfig) AllowedOutputDests() []tasks.FirewallTaskDest {
-	return []tasks.FirewallTaskDest{
-		{Host: c.APIHost, Port: c.APIPort},
-		{Host: c.BOSHMbusHost, Port: c.BOSHMbusPort, IsBOSHMbus: true},
+	dests := []tasks.FirewallTaskDest{{Host: c.APIHost, Port: c.APIPort}}
+
+	if c.APIChannelPort != 0 {
+		dests = append(dests, tasks.FirewallTaskDest{Host: c.APIHost, Port: c.APIChannelPort})
+	}
+
+	if len(c.BOSHMbusHost) > 0 {
+		dests = append(dests, tasks.FirewallTaskDest{Host: c.BOSHMbusHost, Port: c.BOSHMbusPort, IsBOSHMbus: true})
 	}
+
+	return append(dests, c.ExtraOutputDests...)
 }
 
 func newAgent(
 	agentID string,
 	agentConfig AgentConfig,
-	client Client,
-	monitProvider monit.ClientProvider,
+	capabilities agentreg.Capabilities,
+	client APIClient,
+	monitProvider monit.Provider,
 	cmdRunner boshsys.CmdRunner,
+	journal tasks.Journal,
+	containers tasks.ContainerFinder,
 	logger boshlog.Logger,
 ) Agent {
 	return Agent{
-		agentID:     agentID,
-		agentConfig: agentConfig,
+		agentID:      agentID,
+		agentConfig:  agentConfig,
+		capabilities: capabilities,
 
 		client:        client,
 		monitProvider: monitProvider,
 		cmdRunner:     cmdRunner,
+		journal:       journal,
+		containers:    containers,
+
+		running: newRunningTasks(),
+		locks:   newResourceLocks(),
 
 		logTag: "Agent",
 		logger: logger,
 	}
 }
 
+// RevertLeftoverChanges reverts system changes made by tasks
+// that did not get a chance to revert them (e.g. agent was killed)
+func (a Agent) RevertLeftoverChanges() error {
+	a.logger.Info(a.logTag, "Reverting leftover changes from journal")
+
+	return a.journal.RevertAll()
+}
+
 func (a Agent) ContiniouslyExecuteTasks() error {
 	a.logger.Info(a.logTag, "Started continiously executing tasks")
 
-	ticker := time.NewTicker(1 * time.Second)
+	go a.continiouslyRegister()
+
+	lastPolledAt := time.Now()
 
 	for {
-		select {
-		case <-ticker.C:
-			tasks, err := a.client.FetchTasks(a.agentID)
-			if err != nil {
-				a.logger.Error(a.logTag, "Failed fetching tasks: %s", err.Error())
-			} else {
-				// Execute tasks in parallel
-				for _, task := range tasks {
-					go a.executeTask(task)
+		// Blocks until there are new tasks or some tasks need to be stopped
+		pollReq := tasks.PollRequest{
+			RunningTaskIDs:     a.running.IDs(),
+			StoppedTaskIDs:     a.running.StoppedIDs(),
+			AdjustmentVersions: a.running.AdjustmentVersions(),
+		}
+
+		pollStartedAt := time.Now()
+
+		resp, err := a.client.PollTasks(a.agentID, pollReq)
+		if err != nil {
+			a.logger.Error(a.logTag, "Failed polling tasks: %s", err.Error())
+
+			// Do not leave tasks running forever if API is gone
+			if a.isDisconnectedTooLong(lastPolledAt) {
+				if num := a.running.StopAll(); num > 0 {
+					a.logger.Error(a.logTag, "Stopping %d agent task(s) since API was unreachable for more than '%s'",
+						num, a.agentConfig.MaxAPIDisconnection)
 				}
 			}
+
+			time.Sleep(1 * time.Second)
+			continue
 		}
+
+		lastPolledAt = time.Now()
+
+		// Execute tasks in parallel
+		for _, task := range resp.Tasks {
+			progress := newTaskProgress(task.ID, a.client, a.logger)
+			stopCh, adjustCh := a.running.Add(task, progress)
+			go a.executeTask(task, progress, stopCh, adjustCh)
+		}
+
+		for _, adj := range resp.Adjustments {
+			a.logger.Debug(a.logTag, "Adjusting agent task '%s' (version %d)", adj.TaskID, adj.Version)
+
+			err := a.running.Adjust(adj)
+			if err != nil {
+				a.logger.Error(a.logTag, "Failed adjusting agent task: %s", err.Error())
+			}
+		}
+
+		for _, taskID := range resp.StoppedTaskIDs {
+			a.logger.Debug(a.logTag, "Stopping agent task '%s'", taskID)
+
+			if !a.running.Stop(taskID) {
+				a.logger.Debug(a.logTag, "Agent task '%s' is not running or already stopping", taskID)
+			}
+		}
+
+		time.Sleep(pollDelay(pollStartedAt, time.Now(), resp))
 	}
 }
 
-func (a Agent) executeTask(task tasks.Task) {
+// minPollInterval keeps agent from hammering API if it keeps returning
+// stops or adjustments that agent cannot act on
+const minPollInterval = 1 * time.Second
+
+// pollDelay returns how long to wait before polling again; new tasks are picked up right away
+func pollDelay(startedAt, now time.Time, resp tasks.PollResponse) time.Duration {
+	if len(resp.Tasks) > 0 {
+		return 0
+	}
+
+	if elapsed := now.Sub(startedAt); elapsed < minPollInterval {
+		return minPollInterval - elapsed
+	}
+
+	return 0
+}
+
+// Re-register periodically since API does not persist registrations
+func (a Agent) continiouslyRegister() {
+	for {
+		err := a.client.Register(a.agentID, a.capabilities)
+		if err != nil {
+			a.logger.Error(a.logTag, "Failed registering agent: %s", err.Error())
+		}
+
+		time.Sleep(1 * time.Minute)
+	}
+}
+
+func (a Agent) executeTask(task tasks.Task, progress *taskProgress, stopCh chan struct{}, adjustCh <-chan tasks.Options) {
 	a.logger.Debug(a.logTag, "Received agent task options '%#v'", task)
 
-	task1, err := a.buildAgentTask(task)
+	defer a.running.Remove(task.ID)
+	heartbeatDoneCh := make(chan struct{})
+	heartbeatStoppedCh := make(chan struct{})
 
-	if task1 != nil && err == nil {
-		stopCh := make(chan struct{}, 1) // allow one stop
-		endPollCh := make(chan struct{}, 1)
-
-		go func() {
-			for {
-				select {
-				case <-endPollCh:
-					return
-				default:
-				}
+	go func() {
+		progress.Heartbeat(heartbeatDoneCh)
+		close(heartbeatStoppedCh)
+	}()
 
-				resp, err := a.client.FetchTaskState(task.ID)
-				if err != nil {
-					a.logger.Error(a.logTag, "Failed fetching agent task control: %s", err.Error())
-				}
+	if task.DryRun {
+		// Do not hold faults (e.g. until timeout) since nothing is applied
+		stopCh = make(chan struct{})
+		close(stopCh)
+	}
 
-				if resp.Stop {
-					close(stopCh)
-					return
-				}
+	task1, err := a.buildAgentTask(task, progress, adjustCh)
+	if err != nil {
+		err = tasks.NewTaskError(tasks.ErrorCategoryValidation, err, nil)
+	}
 
-				time.Sleep(1 * time.Second)
-			}
-		}()
+	// Dry run tasks do not change anything hence cannot conflict
+	if task1 != nil && err == nil && !task.DryRun {
+		err = a.lockResources(task)
+		defer a.locks.Release(task.ID)
+	}
 
+	if task1 != nil && err == nil {
 		err = task1.Execute(stopCh)
 		if err != nil {
 			err = bosherr.WrapError(err, "Task execution")
 			a.logger.Error(a.logTag, "Failed executing agent task: %s", err.Error())
-		}
 
-		close(endPollCh)
+			taskErr := progress.Error(err)
+
+			// Task may have failed before reverting all of its changes
+			revertErr := a.journal.Revert(task.ID)
+			if revertErr != nil {
+				a.logger.Error(a.logTag, "Failed reverting agent task changes: %s", revertErr.Error())
+				taskErr.Category = tasks.ErrorCategoryRevertFailed
+			}
+
+			err = taskErr
+		}
 	}
 
+	// Last progress must be recorded before the result
+	close(heartbeatDoneCh)
+	<-heartbeatStoppedCh
+
 	err = a.client.RecordTaskResult(task.ID, err)
 	if err != nil {
 		a.logger.Error(a.logTag, "Failed updating agent task: %s", err.Error())
 	}
 }
 
-func (a Agent) buildAgentTask(task tasks.Task) (agentTask, error) {
+func (a Agent) lockResources(task tasks.Task) error {
+	ifaceNames, err := tasks.NonLocalIfaceNames()
+	if err != nil {
+		return tasks.NewTaskError(tasks.ErrorCategoryExecution, err, nil)
+	}
+
+	err = a.locks.Acquire(task.ID, tasks.Resources(task.Options(), ifaceNames))
+	if err != nil {
+		a.logger.Error(a.logTag, "Rejecting agent task '%s': %s", task.ID, err.Error())
+		return tasks.NewTaskError(tasks.ErrorCategoryConflict, err, nil)
+	}
+
+	return nil
+}
+
+func (a Agent) isDisconnectedTooLong(lastPolledAt time.Time) bool {
+	max := a.agentConfig.MaxAPIDisconnection
+	return max > 0 && time.Since(lastPolledAt) > max
+}
+
+func (a Agent) buildAgentTask(task tasks.Task, progress *taskProgress, adjustCh <-chan tasks.Options) (agentTask, error) {
 	var t agentTask
-	var err error
+
+	var cmdRunner boshsys.CmdRunner = progressCmdRunner{a.cmdRunner, progress}
+	journal := a.journal.ForTask(task.ID)
+
+	if task.DryRun {
+		cmdRunner = dryRunCmdRunner{a.cmdRunner, progress}
+		journal = a.journal.ForDryRunTask(task.ID)
+	}
+
+	err := task.Options().Validate()
+	if err != nil {
+		return t, bosherr.WrapError(err, "Validating agent task options")
+	}
+
+	// Agent configuration is the last line of defense against selecting wrong instances
+	taskType := tasks.OptionsType(task.Options())
+
+	if !a.agentConfig.IsTaskTypeAllowed(taskType) {
+		a.logger.Error(a.logTag, "Refusing disallowed agent task '%s' of type '%s'", task.ID, taskType)
+		return t, bosherr.Errorf("Task type '%s' is not allowed on this agent (allowed: '%s')",
+			taskType, strings.Join(a.agentConfig.AllowedTaskTypes, "', '"))
+	}
 
 	switch opts := task.Options().(type) {
 	case tasks.NoopOptions:
 		t = tasks.NewNoopTask(opts)
 
 	case tasks.KillProcessOptions:
-		monitClient, err := a.monitProvider.Get()
-		if err != nil {
-			err = bosherr.WrapError(err, "Failed to retrieve monit client")
-		} else {
-			t = tasks.NewKillProcessTask(monitClient, a.cmdRunner, opts, a.logger)
+		var monitClient monit.Client
+
+		// Killing processes by pattern or in a container does not require a process manager
+		if len(opts.ProcessName) == 0 && opts.Target == nil {
+			monitClient, err = a.monitProvider.Get()
+			if err != nil {
+				err = bosherr.WrapError(err, "Failed to retrieve monit client")
+				break
+			}
 		}
 
+		t = tasks.NewKillProcessTask(monitClient, cmdRunner, a.containers, opts, a.logger)
+
 	case tasks.StressOptions:
-		t = tasks.NewStressTask(a.cmdRunner, opts, a.logger)
+		t = tasks.NewStressTask(cmdRunner, progress, adjustCh, opts, a.logger)
 
 	case tasks.ControlNetOptions:
-		t = tasks.NewControlNetTask(a.cmdRunner, opts, a.logger)
+		t = tasks.NewControlNetTask(cmdRunner, a.containers, journal, progress, adjustCh, opts, a.logger)
 
 	case tasks.FirewallOptions:
-		t = tasks.NewFirewallTask(a.cmdRunner, opts, a.agentConfig.AllowedOutputDests(), a.logger)
+		t = tasks.NewFirewallTask(task.ID, cmdRunner, a.containers, journal, progress, opts, a.agentConfig.AllowedOutputDests(), a.logger)
 
 	case tasks.FillDiskOptions:
-		t = tasks.NewFillDiskTask(a.cmdRunner, opts, a.logger)
+		t = tasks.NewFillDiskTask(cmdRunner, opts, a.logger)
 
 	case tasks.ShutdownOptions:
-		t = tasks.NewShutdownTask(a.cmdRunner, opts, a.logger)
+		t = tasks.NewShutdownTask(cmdRunner, opts, a.logger)
 
 	default:
 		err = bosherr.Errorf("Unknown agent task '%T'", task.Optionss[0])
@@ -8,9 +8,18 @@ import (
 	boshhttp "github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
+	"github.com/cppforlife/turbulence/agentreg"
 	"github.com/cppforlife/turbulence/tasks"
 )
 
+// APIClient is implemented by JSON over HTTPS API client and agent channel client
+type APIClient interface {
+	Register(string, agentreg.Capabilities) error
+	PollTasks(string, tasks.PollRequest) (tasks.PollResponse, error)
+	RecordTaskProgress(string, tasks.ProgressRequest) error
+	RecordTaskResult(string, error) error
+}
+
 type Client struct {
 	clientRequest clientRequest
 }
@@ -25,34 +34,60 @@ func NewClient(endpoint string, httpClient boshhttp.HTTPClient, logger boshlog.L
 	return Client{clientRequest: clientRequest}
 }
 
-func (c Client) FetchTasks(agentID string) ([]tasks.Task, error) {
-	var resp []tasks.Task
+func (c Client) Register(agentID string, caps agentreg.Capabilities) error {
+	var resp interface{}
 
-	// todo use query string
-	// todo rename to agent_tasks
-	path := fmt.Sprintf("/api/v1/agents/%s/tasks", agentID)
+	path := fmt.Sprintf("/api/v1/agents/%s", agentID)
 
-	err := c.clientRequest.Post(path, nil, &resp)
+	bytes, err := json.Marshal(caps)
 	if err != nil {
-		return resp, bosherr.WrapErrorf(err, "Fetching tasks '%s'", agentID)
+		return bosherr.WrapErrorf(err, "Marshalling capabilities")
 	}
 
-	return resp, nil
+	err = c.clientRequest.Post(path, bytes, &resp)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Registering agent '%s'", agentID)
+	}
+
+	return nil
 }
 
-func (c Client) FetchTaskState(taskID string) (tasks.StateResponse, error) {
-	var resp tasks.StateResponse
+func (c Client) PollTasks(agentID string, req tasks.PollRequest) (tasks.PollResponse, error) {
+	var resp tasks.PollResponse
 
-	path := fmt.Sprintf("/api/v1/agent_tasks/%s/state", taskID)
+	path := fmt.Sprintf("/api/v1/agents/%s/poll", agentID)
 
-	err := c.clientRequest.Get(path, &resp)
+	bytes, err := json.Marshal(req)
 	if err != nil {
-		return resp, bosherr.WrapErrorf(err, "Fetching task '%s' state", taskID)
+		return resp, bosherr.WrapErrorf(err, "Marshalling poll request")
+	}
+
+	err = c.clientRequest.Post(path, bytes, &resp)
+	if err != nil {
+		return resp, bosherr.WrapErrorf(err, "Polling tasks '%s'", agentID)
 	}
 
 	return resp, nil
 }
 
+func (c Client) RecordTaskProgress(taskID string, req tasks.ProgressRequest) error {
+	var resp interface{}
+
+	path := fmt.Sprintf("/api/v1/agent_tasks/%s/progress", taskID)
+
+	bytes, err := json.Marshal(req)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling task progress")
+	}
+
+	err = c.clientRequest.Post(path, bytes, &resp)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Updating task '%s' progress", taskID)
+	}
+
+	return nil
+}
+
 func (c Client) RecordTaskResult(taskID string, err error) error {
 	var resp interface{}
 
@@ -61,6 +96,10 @@ func (c Client) RecordTaskResult(taskID string, err error) error {
 
 	if err != nil {
 		req.Error = err.Error()
+
+		if taskErr, ok := err.(tasks.TaskError); ok {
+			req.TaskError = &taskErr
+		}
 	}
 
 	bytes, err := json.Marshal(req)
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// issueClientCertificate signs new client certificate with agent ID as its common name
+// so that agents sharing the same config still present their own identity to the API
+func issueClientCertificate(agentID, caCertPEM, caKeyPEM string) (*tls.Certificate, error) {
+	ca, err := tls.X509KeyPair([]byte(caCertPEM), []byte(caKeyPEM))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Loading client CA certificate")
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing client CA certificate")
+	}
+
+	if !caCert.IsCA {
+		return nil, bosherr.Error("Expected 'ClientCACert' to be a CA certificate")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating client key")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating client certificate serial number")
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: agentID},
+		NotBefore:    time.Now().Add(-time.Hour), // tolerate clock skew with API server
+		NotAfter:     caCert.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Signing client certificate")
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
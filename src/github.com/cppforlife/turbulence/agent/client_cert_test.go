@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APIClientCertificate", func() {
+	var (
+		caCertPEM, caKeyPEM string
+		caPool              *x509.CertPool
+	)
+
+	BeforeEach(func() {
+		caCertPEM, caKeyPEM = newTestCertPEM(true)
+
+		caPool = x509.NewCertPool()
+		Expect(caPool.AppendCertsFromPEM([]byte(caCertPEM))).To(BeTrue())
+	})
+
+	newConfig := func(agentID string) Config {
+		return Config{
+			AgentID: agentID,
+			API:     APIConfig{Host: "api", Port: 8081, ClientCACert: caCertPEM, ClientCAKey: caKeyPEM},
+		}
+	}
+
+	It("issues certificate for each agent sharing the same CA", func() {
+		for _, agentID := range []string{"agent1", "agent2"} {
+			cert, err := newConfig(agentID).APIClientCertificate()
+			Expect(err).ToNot(HaveOccurred())
+
+			parsedCert, err := x509.ParseCertificate(cert.Certificate[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsedCert.Subject.CommonName).To(Equal(agentID))
+
+			_, err = parsedCert.Verify(x509.VerifyOptions{
+				Roots:     caPool,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("does not require shared credentials when CA is configured", func() {
+		Expect(newConfig("agent1").API.Validate()).To(Succeed())
+	})
+
+	It("rejects both client certificate and its CA", func() {
+		config := newConfig("agent1")
+		config.API.ClientCert, config.API.ClientKey = newTestCertPEM(false)
+
+		err := config.API.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected only one of 'ClientCert' and 'ClientCACert'"))
+	})
+
+	It("rejects CA certificate that cannot issue certificates", func() {
+		config := newConfig("agent1")
+		config.API.ClientCACert, config.API.ClientCAKey = newTestCertPEM(false)
+
+		_, err := config.APIClientCertificate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected 'ClientCACert' to be a CA certificate"))
+	})
+})
+
+// newTestCertPEM returns self-signed certificate and its RSA key as generated by BOSH
+func newTestCertPEM(isCA bool) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return string(certPEM), string(keyPEM)
+}
@@ -1,26 +1,134 @@
 package main
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cppforlife/turbulence/tasks/monit"
+)
+
+const (
+	defaultJournalDir      = "/var/vcap/data/turbulence_agent/journal"
+	defaultLinuxJournalDir = "/var/lib/turbulence_agent/journal"
+	defaultFaultCgroup     = "turbulence_faults"
+
+	defaultLocalSocketPath      = "/var/vcap/sys/run/turbulence_agent/agent.sock"
+	defaultLinuxLocalSocketPath = "/var/run/turbulence_agent/agent.sock"
+)
+
+const (
+	PlatformBOSH  = "bosh"  // BOSH VM with BOSH Agent and monit
+	PlatformLinux = "linux" // any other Linux host
+)
+
+const (
+	ProcessManagerMonit   = "monit"
+	ProcessManagerSystemd = "systemd"
+	ProcessManagerNone    = "none"
 )
 
 type Config struct {
 	AgentID string
 
+	// Defaults to 'bosh'; 'linux' does not require BOSH settings and monit
+	Platform string
+
+	// Required on 'linux' platform so that host could be selected by incidents
+	Host HostConfig
+
+	// Process manager that lists processes KillProcess task may kill;
+	// defaults to 'monit' on BOSH VMs and 'none' on other hosts
+	ProcessManager ProcessManagerConfig
+
+	// Firewall tasks do not block outgoing traffic to these destinations
+	// in addition to the API server (and BOSH Agent's mbus on BOSH VMs)
+	AllowedOutputDests []DestConfig
+
+	// Directory where applied changes are recorded so that they could be reverted
+	JournalDir string
+
+	// Unix socket that serves status and emergency revert requests from the same host
+	LocalSocketPath string
+
+	// Active tasks are stopped if API cannot be reached for this long;
+	// Times may be suffixed with ms,s,m,h; empty value disables it
+	MaxAPIDisconnection string
+
+	// Agent process is protected from OOM killer with this value;
+	// 0 keeps value inherited from the parent process
+	OOMScoreAdj int
+
+	FaultLimits FaultLimitsConfig
+
+	// Task types (e.g. Stress) agent is allowed to run;
+	// all supported task types are allowed if empty
+	AllowedTaskTypes []string
+
 	API APIConfig
 }
 
+// FaultLimitsConfig limits resources that all processes started by tasks
+// (e.g. stress) can use together so that they cannot starve the agent, monit or BOSH Agent
+type FaultLimitsConfig struct {
+	// Name of the cgroup fault processes are placed in
+	Cgroup string
+
+	// Sizes may be suffixed with K,M,G or be a percentage of total memory (e.g. 75%);
+	// empty value disables the limit
+	Memory string
+}
+
+type HostConfig struct {
+	Deployment string
+	Group      string
+	AZ         string
+}
+
+type ProcessManagerConfig struct {
+	Type string // monit, systemd or none
+
+	// Default to BOSH monit credentials path and host
+	MonitCredsPath string
+	MonitHost      string
+
+	// Unit name patterns (e.g. 'postgresql*.service') of services that may be killed;
+	// required with systemd since it also runs services that keep host reachable
+	SystemdUnits []string
+}
+
+type DestConfig struct {
+	Host string
+	Port int
+}
+
 type APIConfig struct {
 	Host string
 	Port int
 
+	// Agent channel (gRPC) is used instead of JSON over HTTPS API when set
+	ChannelPort int
+
 	// CA certificate is not required
 	CACert string
 
+	// Client certificate identifies the agent when API requires per-agent
+	// certificates; its common name must match agent ID
+	ClientCert string
+	ClientKey  string
+
+	// CA that issues client certificate for agent ID when agent starts;
+	// used instead of client certificate when agents share config (e.g. BOSH job)
+	ClientCACert string
+	ClientCAKey  string
+
 	Username string
 	Password string
 }
@@ -38,6 +146,12 @@ func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
 		return config, bosherr.WrapError(err, "Unmarshalling config")
 	}
 
+	config.applyDefaults()
+
+	if len(config.FaultLimits.Cgroup) == 0 {
+		config.FaultLimits.Cgroup = defaultFaultCgroup
+	}
+
 	err = config.Validate()
 	if err != nil {
 		return config, bosherr.WrapError(err, "Validating config")
@@ -46,12 +160,140 @@ func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
 	return config, nil
 }
 
+func (c *Config) applyDefaults() {
+	if len(c.Platform) == 0 {
+		c.Platform = PlatformBOSH
+	}
+
+	if len(c.JournalDir) == 0 {
+		if c.Platform == PlatformBOSH {
+			c.JournalDir = defaultJournalDir
+		} else {
+			c.JournalDir = defaultLinuxJournalDir
+		}
+	}
+
+	if len(c.LocalSocketPath) == 0 {
+		if c.Platform == PlatformBOSH {
+			c.LocalSocketPath = defaultLocalSocketPath
+		} else {
+			c.LocalSocketPath = defaultLinuxLocalSocketPath
+		}
+	}
+
+	if len(c.ProcessManager.Type) == 0 {
+		if c.Platform == PlatformBOSH {
+			c.ProcessManager.Type = ProcessManagerMonit
+		} else {
+			c.ProcessManager.Type = ProcessManagerNone
+		}
+	}
+
+	if len(c.ProcessManager.MonitCredsPath) == 0 {
+		c.ProcessManager.MonitCredsPath = monit.DefaultCredsPath
+	}
+
+	if len(c.ProcessManager.MonitHost) == 0 {
+		c.ProcessManager.MonitHost = monit.DefaultHost
+	}
+}
+
+func (c Config) MaxAPIDisconnectionDuration() (time.Duration, error) {
+	if len(c.MaxAPIDisconnection) == 0 {
+		return 0, nil
+	}
+
+	dur, err := time.ParseDuration(c.MaxAPIDisconnection)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Parsing 'MaxAPIDisconnection'")
+	}
+
+	return dur, nil
+}
+
 func (c Config) Validate() error {
+	if _, err := c.MaxAPIDisconnectionDuration(); err != nil {
+		return err
+	}
+
+	switch c.Platform {
+	case PlatformBOSH, PlatformLinux:
+	default:
+		return bosherr.Errorf("Expected 'Platform' to be 'bosh' or 'linux' but was '%s'", c.Platform)
+	}
+
+	if c.Platform == PlatformLinux {
+		if len(c.Host.Deployment) == 0 || len(c.Host.Group) == 0 {
+			return bosherr.Error("Expected 'Host.Deployment' and 'Host.Group' to be specified on 'linux' platform")
+		}
+	}
+
+	switch c.ProcessManager.Type {
+	case ProcessManagerMonit, ProcessManagerSystemd, ProcessManagerNone:
+	default:
+		return bosherr.Errorf("Expected 'ProcessManager.Type' to be 'monit', 'systemd' or 'none' but was '%s'", c.ProcessManager.Type)
+	}
+
+	if c.ProcessManager.Type == ProcessManagerSystemd && len(c.ProcessManager.SystemdUnits) == 0 {
+		return bosherr.Error("Expected 'ProcessManager.SystemdUnits' to be specified with 'systemd' process manager")
+	}
+
+	for i, pattern := range c.ProcessManager.SystemdUnits {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return bosherr.WrapErrorf(err, "Parsing 'ProcessManager.SystemdUnits[%d]'", i)
+		}
+	}
+
+	for i, dest := range c.AllowedOutputDests {
+		if len(dest.Host) == 0 || dest.Port <= 0 {
+			return bosherr.Errorf("Expected 'AllowedOutputDests[%d]' to include 'Host' and 'Port'", i)
+		}
+	}
+
+	if c.OOMScoreAdj < -1000 || c.OOMScoreAdj > 1000 {
+		return bosherr.Errorf("Expected 'OOMScoreAdj' to be between -1000 and 1000 but was '%d'", c.OOMScoreAdj)
+	}
+
+	if !faultMemoryRegexp.MatchString(c.FaultLimits.Memory) {
+		return bosherr.Errorf("Expected 'FaultLimits.Memory' to be a size suffixed with K,M,G or a percentage but was '%s'", c.FaultLimits.Memory)
+	}
+
+	for _, taskType := range c.AllowedTaskTypes {
+		if !isSupportedTaskType(taskType) {
+			return bosherr.Errorf("Expected 'AllowedTaskTypes' to include only supported task types ('%s') but included '%s'",
+				strings.Join(supportedTaskTypes, "', '"), taskType)
+		}
+	}
+
+	// Root cgroup includes all processes on the host
+	cgroup := c.FaultLimits.Cgroup
+
+	if strings.Contains(cgroup, "..") || filepath.Clean("/"+strings.TrimSpace(cgroup)) == "/" {
+		return bosherr.Errorf("Expected 'FaultLimits.Cgroup' to be a cgroup name but was '%s'", cgroup)
+	}
+
 	err := c.API.Validate()
 	if err != nil {
 		return bosherr.WrapError(err, "Validating 'API' config")
 	}
 
+	cert, err := c.APIClientCertificate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating 'API' config")
+	}
+
+	if cert != nil {
+		parsedCert, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return bosherr.WrapError(err, "Parsing 'API.ClientCert'")
+		}
+
+		if parsedCert.Subject.CommonName != c.AgentID {
+			return bosherr.Errorf("Expected 'API.ClientCert' common name to match agent ID '%s' but was '%s'",
+				c.AgentID, parsedCert.Subject.CommonName)
+		}
+	}
+
 	return nil
 }
 
@@ -69,6 +311,29 @@ func (c APIConfig) CACertPool() (*x509.CertPool, error) {
 	return certPool, nil
 }
 
+// APIClientCertificate returns nil if neither client certificate nor its CA is configured
+func (c Config) APIClientCertificate() (*tls.Certificate, error) {
+	if len(c.API.ClientCACert) == 0 && len(c.API.ClientCAKey) == 0 {
+		return c.API.ClientCertificate()
+	}
+
+	return issueClientCertificate(c.AgentID, c.API.ClientCACert, c.API.ClientCAKey)
+}
+
+// ClientCertificate returns nil if client certificate is not configured
+func (c APIConfig) ClientCertificate() (*tls.Certificate, error) {
+	if len(c.ClientCert) == 0 && len(c.ClientKey) == 0 {
+		return nil, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Loading client certificate")
+	}
+
+	return &cert, nil
+}
+
 func (c APIConfig) Validate() error {
 	if len(c.Host) == 0 {
 		return bosherr.Error("Missing 'Host'")
@@ -82,6 +347,15 @@ func (c APIConfig) Validate() error {
 		return err
 	}
 
+	if len(c.ClientCert) > 0 && len(c.ClientCACert) > 0 {
+		return bosherr.Error("Expected only one of 'ClientCert' and 'ClientCACert' to be specified")
+	}
+
+	// Agents with client certificates do not get shared credentials
+	if len(c.ClientCert) > 0 || len(c.ClientCACert) > 0 {
+		return nil
+	}
+
 	if len(c.Username) == 0 {
 		return bosherr.Error("Missing 'Username'")
 	}
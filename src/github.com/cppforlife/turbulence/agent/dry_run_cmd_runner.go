@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// dryRunCmdRunner reports commands that task would run without running them.
+// Commands that only inspect the system (e.g. tc configuration, systemd services)
+// are run so that task resolves everything else as usual.
+type dryRunCmdRunner struct {
+	cmdRunner boshsys.CmdRunner
+	progress  *taskProgress
+}
+
+func (r dryRunCmdRunner) RunComplexCommand(cmd boshsys.Command) (string, string, int, error) {
+	if isReadOnlyCmd(cmd.Name, cmd.Args) {
+		return r.cmdRunner.RunComplexCommand(cmd)
+	}
+
+	r.record(cmd.Name, cmd.Args)
+	return "", "", 0, nil
+}
+
+func (r dryRunCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	r.record(cmd.Name, cmd.Args)
+	return dryRunProcess{}, nil
+}
+
+func (r dryRunCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	if isReadOnlyCmd(cmdName, args) {
+		return r.cmdRunner.RunCommand(cmdName, args...)
+	}
+
+	r.record(cmdName, args)
+	return "", "", 0, nil
+}
+
+func (r dryRunCmdRunner) RunCommandWithInput(input, cmdName string, args ...string) (string, string, int, error) {
+	if isReadOnlyCmd(cmdName, args) {
+		return r.cmdRunner.RunCommandWithInput(input, cmdName, args...)
+	}
+
+	r.record(cmdName, args)
+	return "", "", 0, nil
+}
+
+// CommandExists is checked for real so that dry run reports commands task would actually run
+func (r dryRunCmdRunner) CommandExists(cmdName string) bool {
+	return r.cmdRunner.CommandExists(cmdName)
+}
+
+func (r dryRunCmdRunner) record(cmdName string, args []string) {
+	fmt.Fprintf(r.progress, "Would run: %s %s\n", cmdName, strings.Join(args, " "))
+}
+
+// isReadOnlyCmd returns true for commands that tasks use to inspect the system;
+// commands run in container's network namespace are checked without the wrapper
+func isReadOnlyCmd(cmdName string, args []string) bool {
+	if cmdName == "sh" && len(args) > 2 && args[0] == "-c" && strings.HasSuffix(args[1], `--net -- "$0" "$@"`) {
+		return isReadOnlyCmd(args[2], args[3:])
+	}
+
+	switch {
+	case cmdName == "tc" && len(args) > 1:
+		return args[1] == "show" // e.g. tc qdisc show dev eth0
+	case cmdName == "systemctl" && len(args) > 0:
+		return args[0] == "list-units" || args[0] == "show"
+	default:
+		return false
+	}
+}
+
+type dryRunProcess struct{}
+
+func (dryRunProcess) Wait() <-chan boshsys.Result {
+	ch := make(chan boshsys.Result, 1)
+	ch <- boshsys.Result{}
+	return ch
+}
+
+func (dryRunProcess) TerminateNicely(time.Duration) error { return nil }
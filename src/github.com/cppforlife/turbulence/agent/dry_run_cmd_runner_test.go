@@ -0,0 +1,65 @@
+package main
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("dryRunCmdRunner", func() {
+	var (
+		cmdRunner *fakesys.FakeCmdRunner
+		runner    dryRunCmdRunner
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		runner = dryRunCmdRunner{cmdRunner, newTaskProgress("task1", Client{}, boshlog.NewLogger(boshlog.LevelNone))}
+	})
+
+	It("runs commands that only inspect the system", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root bands 3\n"})
+
+		stdout, _, _, err := runner.RunCommand("tc", "qdisc", "show", "dev", "eth0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("qdisc prio 1: root bands 3\n"))
+
+		container := tasks.Container{MainPID: 95, Netns: "net:[4026532]"}
+		name, args := container.NetnsCmd("tc", []string{"class", "show", "dev", "eth0"})
+
+		_, _, _, err = runner.RunCommand(name, args...)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{
+			{"tc", "qdisc", "show", "dev", "eth0"},
+			append([]string{name}, args...),
+		}))
+		Expect(runner.progress.output.String()).To(BeEmpty())
+	})
+
+	It("only reports commands that change the system", func() {
+		container := tasks.Container{MainPID: 95, Netns: "net:[4026532]"}
+		name, args := container.NetnsCmd("tc", []string{"qdisc", "replace", "dev", "eth0", "root", "netem"})
+
+		for _, cmd := range [][]string{{"kill", "-9", "123"}, {"systemctl", "stop", "ssh.service"}, append([]string{name}, args...)} {
+			_, _, _, err := runner.RunCommand(cmd[0], cmd[1:]...)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(cmdRunner.RunCommands).To(BeEmpty())
+		Expect(runner.progress.output.String()).To(ContainSubstring("Would run: kill -9 123\n"))
+		Expect(runner.progress.output.String()).To(ContainSubstring("Would run: systemctl stop ssh.service\n"))
+		Expect(runner.progress.output.String()).To(ContainSubstring("tc qdisc replace dev eth0 root netem\n"))
+	})
+
+	It("checks whether commands exist", func() {
+		cmdRunner.AvailableCommands = map[string]bool{"iptables": true}
+
+		Expect(runner.CommandExists("iptables")).To(BeTrue())
+		Expect(runner.CommandExists("ip6tables")).To(BeFalse())
+	})
+})
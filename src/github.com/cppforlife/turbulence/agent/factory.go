@@ -8,16 +8,22 @@ import (
 	"net/url"
 	"time"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshhttp "github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/agentrpc"
+	"github.com/cppforlife/turbulence/tasks"
 	"github.com/cppforlife/turbulence/tasks/monit"
+	"github.com/cppforlife/turbulence/tasks/systemd"
 )
 
 type Factory struct {
-	agentID string
-	config  APIConfig
+	config Config
 
 	fs        boshsys.FileSystem
 	cmdRunner boshsys.CmdRunner
@@ -27,15 +33,13 @@ type Factory struct {
 }
 
 func NewFactory(
-	agentID string,
-	config APIConfig,
+	config Config,
 	fs boshsys.FileSystem,
 	cmdRunner boshsys.CmdRunner,
 	logger boshlog.Logger,
 ) Factory {
 	return Factory{
-		agentID: agentID,
-		config:  config,
+		config: config,
 
 		fs:        fs,
 		cmdRunner: cmdRunner,
@@ -51,42 +55,144 @@ func (f Factory) New() (Agent, error) {
 		return Agent{}, err
 	}
 
-	client, err := f.httpClient()
+	client, err := f.apiClient()
 	if err != nil {
 		return Agent{}, err
 	}
 
-	monitProvider := monit.NewClientProvider(f.fs, f.logger)
+	monitProvider := f.processManager()
+	journal := tasks.NewJournal(f.config.JournalDir, f.fs, f.cmdRunner, f.logger)
 
-	return newAgent(f.agentID, agentConfig, client, monitProvider, f.cmdRunner, f.logger), nil
+	caps := f.capabilities(agentConfig, monitProvider)
+
+	faultLimits := NewFaultLimits(f.config.FaultLimits, f.fs, f.logger)
+
+	err = faultLimits.Setup()
+	if err != nil {
+		return Agent{}, err
+	}
+
+	// Journal reverts changes with unlimited commands
+	cmdRunner := faultLimits.CmdRunner(f.cmdRunner)
+
+	containers := tasks.NewContainerFinder(f.fs)
+
+	return newAgent(f.config.AgentID, agentConfig, caps, client, monitProvider, cmdRunner, journal, containers, f.logger), nil
+}
+
+func (f Factory) processManager() monit.Provider {
+	switch f.config.ProcessManager.Type {
+	case ProcessManagerMonit:
+		return monit.NewClientProvider(
+			f.config.ProcessManager.MonitCredsPath, f.config.ProcessManager.MonitHost, f.fs, f.logger)
+	case ProcessManagerSystemd:
+		return systemd.NewClientProvider(f.config.ProcessManager.SystemdUnits, f.cmdRunner, f.fs, f.logger)
+	default:
+		return noProcessManager{}
+	}
+}
+
+func (f Factory) capabilities(agentConfig AgentConfig, monitProvider monit.Provider) agentreg.Capabilities {
+	caps := agentreg.Capabilities{
+		Version:   version,
+		TaskTypes: supportedTaskTypes,
+		DryRun:    true,
+
+		DisallowedTaskTypes: agentConfig.DisallowedTaskTypes(),
+	}
+
+	if f.config.Platform != PlatformBOSH {
+		caps.Host = &agentreg.Host{
+			Deployment: f.config.Host.Deployment,
+			Group:      f.config.Host.Group,
+			AZ:         f.config.Host.AZ,
+		}
+	}
+
+	for _, tool := range tasks.Tools {
+		var found bool
+
+		switch tool {
+		case "sysrq":
+			found = f.fs.FileExists("/proc/sysrq-trigger")
+		case "monit":
+			// Stands for any configured process manager
+			found = monitProvider.IsAvailable()
+		default:
+			found = f.cmdRunner.CommandExists(tool)
+		}
+
+		if found {
+			caps.Tools = append(caps.Tools, tool)
+		} else {
+			f.logger.Info(f.logTag, "Tool '%s' is not available", tool)
+		}
+	}
+
+	return caps
 }
 
 func (f Factory) agentConfig() (AgentConfig, error) {
-	settings, err := NewBOSHSettingsFromPath(f.fs)
-	if err != nil {
-		return AgentConfig{}, err
+	var mbusHost string
+	var mbusPort int
+
+	if f.config.Platform == PlatformBOSH {
+		settings, err := NewBOSHSettingsFromPath(f.fs)
+		if err != nil {
+			return AgentConfig{}, err
+		}
+
+		mbusHost, mbusPort, err = settings.HostPort()
+		if err != nil {
+			return AgentConfig{}, err
+		}
+	}
+
+	var extraDests []tasks.FirewallTaskDest
+
+	for _, dest := range f.config.AllowedOutputDests {
+		extraDests = append(extraDests, tasks.FirewallTaskDest{Host: dest.Host, Port: dest.Port})
 	}
 
-	mbusHost, mbusPort, err := settings.HostPort()
+	maxAPIDisconnection, err := f.config.MaxAPIDisconnectionDuration()
 	if err != nil {
 		return AgentConfig{}, err
 	}
 
 	agentConfig := AgentConfig{
-		APIHost: f.config.Host,
-		APIPort: f.config.Port,
+		APIHost:        f.config.API.Host,
+		APIPort:        f.config.API.Port,
+		APIChannelPort: f.config.API.ChannelPort,
 
 		BOSHMbusHost: mbusHost,
 		BOSHMbusPort: mbusPort,
+
+		ExtraOutputDests: extraDests,
+
+		MaxAPIDisconnection: maxAPIDisconnection,
+		AllowedTaskTypes:    f.config.AllowedTaskTypes,
 	}
 
 	return agentConfig, nil
 }
 
-func (f Factory) httpClient() (Client, error) {
-	certPool, err := f.config.CACertPool()
+func (f Factory) apiClient() (APIClient, error) {
+	tlsConfig, err := f.tlsConfig()
 	if err != nil {
-		return Client{}, err
+		return nil, err
+	}
+
+	if f.config.API.ChannelPort != 0 {
+		return f.channelClient(tlsConfig)
+	}
+
+	return f.httpClient(tlsConfig), nil
+}
+
+func (f Factory) tlsConfig() (*tls.Config, error) {
+	certPool, err := f.config.API.CACertPool()
+	if err != nil {
+		return nil, err
 	}
 
 	if certPool == nil {
@@ -95,8 +201,45 @@ func (f Factory) httpClient() (Client, error) {
 		f.logger.Debug(f.logTag, "Using custom root CAs")
 	}
 
+	tlsConfig := &tls.Config{RootCAs: certPool}
+
+	clientCert, err := f.config.APIClientCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	if clientCert != nil {
+		f.logger.Debug(f.logTag, "Using client certificate")
+		tlsConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (f Factory) channelClient(tlsConfig *tls.Config) (APIClient, error) {
+	addr := fmt.Sprintf("%s:%d", f.config.API.Host, f.config.API.ChannelPort)
+
+	f.logger.Debug(f.logTag, "Using agent channel '%s'", addr)
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+
+	if len(f.config.API.Username) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(
+			agentrpc.BasicAuthCredentials{Username: f.config.API.Username, Password: f.config.API.Password}))
+	}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Dialing agent channel")
+	}
+
+	// Timeout must be longer than how long API holds polls
+	return agentrpc.NewClient(conn, f.config.AgentID, 60*time.Second, f.logger), nil
+}
+
+func (f Factory) httpClient(tlsConfig *tls.Config) Client {
 	httpTransport := &http.Transport{
-		TLSClientConfig:     &tls.Config{RootCAs: certPool},
+		TLSClientConfig:     tlsConfig,
 		TLSHandshakeTimeout: 10 * time.Second,
 
 		Dial:  (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 0}).Dial,
@@ -105,11 +248,25 @@ func (f Factory) httpClient() (Client, error) {
 
 	endpoint := url.URL{
 		Scheme: "https",
-		Host:   fmt.Sprintf("%s:%d", f.config.Host, f.config.Port),
-		User:   url.UserPassword(f.config.Username, f.config.Password),
+		Host:   fmt.Sprintf("%s:%d", f.config.API.Host, f.config.API.Port),
 	}
 
-	httpClient := boshhttp.NewHTTPClient(&http.Client{Transport: httpTransport}, f.logger)
+	if len(f.config.API.Username) > 0 {
+		endpoint.User = url.UserPassword(f.config.API.Username, f.config.API.Password)
+	}
+
+	// Timeout must be longer than how long API holds poll requests
+	rawClient := &http.Client{Transport: httpTransport, Timeout: 60 * time.Second}
+
+	httpClient := boshhttp.NewHTTPClient(rawClient, f.logger)
+
+	return NewClient(endpoint.String(), httpClient, f.logger)
+}
+
+type noProcessManager struct{}
+
+func (noProcessManager) IsAvailable() bool { return false }
 
-	return NewClient(endpoint.String(), httpClient, f.logger), nil
+func (noProcessManager) Get() (monit.Client, error) {
+	return nil, bosherr.Error("Process manager is not configured")
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// Fault processes are the first ones OOM killer picks
+	faultOOMScoreAdj = 1000
+)
+
+var faultMemoryRegexp = regexp.MustCompile(`(?i)^(\d+[kmg]?|\d{1,2}%|100%)?$`)
+
+// FaultLimits places processes started by tasks into a dedicated cgroup
+type FaultLimits struct {
+	config FaultLimitsConfig
+	fs     boshsys.FileSystem
+
+	// Empty if fault processes are not limited
+	procsPath string
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFaultLimits(config FaultLimitsConfig, fs boshsys.FileSystem, logger boshlog.Logger) *FaultLimits {
+	return &FaultLimits{config: config, fs: fs, logTag: "agent.FaultLimits", logger: logger}
+}
+
+// Setup creates cgroup (v1 or v2 memory controller) with configured memory ceiling
+func (l *FaultLimits) Setup() error {
+	if len(l.config.Memory) == 0 {
+		l.logger.Info(l.logTag, "Memory used by fault processes is not limited")
+		return nil
+	}
+
+	limit, err := l.memoryLimitBytes()
+	if err != nil {
+		return err
+	}
+
+	dir, limitFile, unified := l.cgroupDir()
+
+	if l.isRootCgroup(dir) {
+		return bosherr.Errorf("Expected fault processes cgroup '%s' not to be a root cgroup", dir)
+	}
+
+	if unified {
+		// Unified hierarchy requires enabling controller for child cgroups
+		err := l.fs.WriteFileString(filepath.Join(cgroupRoot, "cgroup.subtree_control"), "+memory")
+		if err != nil {
+			return bosherr.WrapError(err, "Enabling memory cgroup controller")
+		}
+	}
+
+	err = l.fs.MkdirAll(dir, 0755)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating cgroup '%s'", dir)
+	}
+
+	err = l.fs.WriteFileString(filepath.Join(dir, limitFile), strconv.FormatUint(limit, 10))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Setting memory limit of cgroup '%s'", dir)
+	}
+
+	l.procsPath = filepath.Join(dir, "cgroup.procs")
+
+	l.logger.Info(l.logTag, "Limiting memory used by fault processes to %d bytes via cgroup '%s'", limit, dir)
+
+	return nil
+}
+
+// cgroupDir returns cgroup directory and its memory limit file for cgroup v2 or v1
+func (l *FaultLimits) cgroupDir() (string, string, bool) {
+	if l.fs.FileExists(filepath.Join(cgroupRoot, "cgroup.controllers")) {
+		return filepath.Join(cgroupRoot, l.config.Cgroup), "memory.max", true
+	}
+
+	return filepath.Join(cgroupRoot, "memory", l.config.Cgroup), "memory.limit_in_bytes", false
+}
+
+func (l *FaultLimits) isRootCgroup(dir string) bool {
+	return dir == cgroupRoot || dir == filepath.Join(cgroupRoot, "memory")
+}
+
+// KillAll kills processes left in the cgroup (e.g. after agent was killed)
+func (l *FaultLimits) KillAll(cmdRunner boshsys.CmdRunner) ([]string, error) {
+	dir, _, _ := l.cgroupDir()
+
+	// Would kill every process on the host
+	if l.isRootCgroup(dir) {
+		return nil, bosherr.Errorf("Refusing to kill processes of root cgroup '%s'", dir)
+	}
+
+	procsPath := filepath.Join(dir, "cgroup.procs")
+
+	if !l.fs.FileExists(procsPath) {
+		return nil, nil
+	}
+
+	procs, err := l.fs.ReadFileString(procsPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading fault processes")
+	}
+
+	pids := strings.Fields(procs)
+
+	if len(pids) > 0 {
+		_, _, _, err = cmdRunner.RunCommand("kill", append([]string{"-9"}, pids...)...)
+		if err != nil {
+			return pids, bosherr.WrapError(err, "Killing fault processes")
+		}
+	}
+
+	return pids, nil
+}
+
+func (l *FaultLimits) memoryLimitBytes() (uint64, error) {
+	mem := strings.ToLower(l.config.Memory)
+
+	if strings.HasSuffix(mem, "%") {
+		percent, _ := strconv.ParseUint(strings.TrimSuffix(mem, "%"), 10, 64)
+
+		total, err := l.totalMemoryBytes()
+		if err != nil {
+			return 0, err
+		}
+
+		return total / 100 * percent, nil
+	}
+
+	units := map[string]uint64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30}
+	num := strings.TrimRight(mem, "kmg")
+
+	val, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Parsing memory limit '%s'", l.config.Memory)
+	}
+
+	return val * units[mem[len(num):]], nil
+}
+
+func (l *FaultLimits) totalMemoryBytes() (uint64, error) {
+	meminfo, err := l.fs.ReadFileString("/proc/meminfo")
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Reading meminfo")
+	}
+
+	// e.g. MemTotal:        4046872 kB
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 3 && fields[0] == "MemTotal:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, bosherr.WrapError(err, "Parsing total memory")
+			}
+
+			return kb * 1024, nil
+		}
+	}
+
+	return 0, bosherr.Error("Finding total memory in meminfo")
+}
+
+// CmdRunner returns runner that starts long running commands (e.g. stress)
+// in the cgroup and makes them preferred victims of OOM killer
+func (l *FaultLimits) CmdRunner(cmdRunner boshsys.CmdRunner) boshsys.CmdRunner {
+	return faultLimitsCmdRunner{cmdRunner, l.procsPath}
+}
+
+// ProtectFromOOMKiller adjusts OOM score of the agent process;
+// processes started by the agent inherit it unless they are fault processes
+func ProtectFromOOMKiller(fs boshsys.FileSystem, scoreAdj int) error {
+	if scoreAdj == 0 {
+		return nil
+	}
+
+	err := fs.WriteFileString("/proc/self/oom_score_adj", strconv.Itoa(scoreAdj))
+	if err != nil {
+		return bosherr.WrapError(err, "Adjusting OOM score")
+	}
+
+	return nil
+}
+
+type faultLimitsCmdRunner struct {
+	boshsys.CmdRunner
+	procsPath string
+}
+
+func (r faultLimitsCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	// Process moves itself before exec so that all its children stay limited;
+	// command does not start if it cannot be limited
+	script := fmt.Sprintf("echo %d > /proc/$$/oom_score_adj", faultOOMScoreAdj)
+
+	if len(r.procsPath) > 0 {
+		script += fmt.Sprintf(" && echo $$ > %s", r.procsPath)
+	}
+
+	script += ` && exec "$0" "$@"`
+
+	cmd.Args = append([]string{"-c", script, cmd.Name}, cmd.Args...)
+	cmd.Name = "sh"
+
+	return r.CmdRunner.RunComplexCommandAsync(cmd)
+}
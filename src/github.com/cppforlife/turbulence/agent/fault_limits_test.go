@@ -0,0 +1,87 @@
+package main
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FaultLimits", func() {
+	var (
+		fs        *fakesys.FakeFileSystem
+		cmdRunner *fakesys.FakeCmdRunner
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cmdRunner = fakesys.NewFakeCmdRunner()
+
+		fs.WriteFileString("/sys/fs/cgroup/cgroup.controllers", "cpu memory")
+		fs.WriteFileString("/sys/fs/cgroup/cgroup.procs", "1\n95\n")
+		fs.WriteFileString("/sys/fs/cgroup/turbulence_faults/cgroup.procs", "120\n121\n")
+	})
+
+	newFaultLimits := func(cgroup string) *FaultLimits {
+		return NewFaultLimits(FaultLimitsConfig{Cgroup: cgroup, Memory: "1G"}, fs, boshlog.NewLogger(boshlog.LevelNone))
+	}
+
+	It("kills processes left in fault processes cgroup", func() {
+		pids, err := newFaultLimits("turbulence_faults").KillAll(cmdRunner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pids).To(Equal([]string{"120", "121"}))
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{{"kill", "-9", "120", "121"}}))
+	})
+
+	It("refuses to use root cgroup", func() {
+		for _, cgroup := range []string{"", ".", "/", "./"} {
+			_, err := newFaultLimits(cgroup).KillAll(cmdRunner)
+			Expect(err).To(HaveOccurred())
+
+			Expect(newFaultLimits(cgroup).Setup()).ToNot(Succeed())
+		}
+
+		Expect(cmdRunner.RunCommands).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Config", func() {
+	It("rejects fault processes cgroup names that resolve to root cgroup", func() {
+		config := Config{Platform: PlatformBOSH, ProcessManager: ProcessManagerConfig{Type: ProcessManagerMonit}}
+
+		for _, cgroup := range []string{" ", ".", "/", "a/..", "/./"} {
+			config.FaultLimits.Cgroup = cgroup
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected 'FaultLimits.Cgroup' to be a cgroup name"))
+		}
+	})
+
+	It("requires unit patterns with systemd process manager", func() {
+		config := Config{Platform: PlatformBOSH, ProcessManager: ProcessManagerConfig{Type: ProcessManagerSystemd}}
+
+		err := config.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected 'ProcessManager.SystemdUnits' to be specified"))
+
+		config.ProcessManager.SystemdUnits = []string{"[postgresql"}
+
+		err = config.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing 'ProcessManager.SystemdUnits[0]'"))
+	})
+
+	It("requires shared credentials only without client certificate", func() {
+		config := APIConfig{Host: "api", Port: 8081}
+
+		err := config.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Missing 'Username'"))
+
+		config.ClientCert = "cert"
+		config.ClientKey = "key"
+		Expect(config.Validate()).ToNot(HaveOccurred())
+	})
+})
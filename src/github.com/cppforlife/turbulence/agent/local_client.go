@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// LocalClient talks to an agent running on the same host via its local socket
+type LocalClient struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+func NewLocalClient(socketPath string) LocalClient {
+	transport := &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", socketPath)
+		},
+	}
+
+	return LocalClient{socketPath, &http.Client{Transport: transport}}
+}
+
+// IsReachable returns false if agent is not running
+func (c LocalClient) IsReachable() bool {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+
+	return true
+}
+
+func (c LocalClient) Status() (LocalStatus, error) {
+	var status LocalStatus
+
+	resp, err := c.httpClient.Get("http://agent/status")
+	if err != nil {
+		return status, bosherr.WrapError(err, "Requesting agent status")
+	}
+
+	return status, c.readJSON(resp, &status)
+}
+
+func (c LocalClient) Revert() (LocalRevertResult, error) {
+	var result LocalRevertResult
+
+	resp, err := c.httpClient.Post("http://agent/revert", "application/json", nil)
+	if err != nil {
+		return result, bosherr.WrapError(err, "Requesting agent revert")
+	}
+
+	return result, c.readJSON(resp, &result)
+}
+
+func (LocalClient) readJSON(resp *http.Response, val interface{}) error {
+	defer resp.Body.Close()
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading agent response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return bosherr.Errorf("Agent responded with status '%d': %s", resp.StatusCode, bytes)
+	}
+
+	err = json.Unmarshal(bytes, val)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Unmarshalling agent response '%s'", bytes))
+	}
+
+	return nil
+}
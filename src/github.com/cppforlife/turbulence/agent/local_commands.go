@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// runLocalCommand runs subcommands meant for operators that are logged into the host
+func runLocalCommand(
+	name string,
+	config Config,
+	fs boshsys.FileSystem,
+	cmdRunner boshsys.CmdRunner,
+	logger boshlog.Logger,
+) error {
+	client := NewLocalClient(config.LocalSocketPath)
+
+	switch name {
+	case "status":
+		status, err := client.Status()
+		if err != nil {
+			return err
+		}
+
+		return printJSON(status)
+
+	case "revert":
+		var result LocalRevertResult
+
+		if client.IsReachable() {
+			var err error
+
+			result, err = client.Revert()
+			if err != nil {
+				return err
+			}
+		} else {
+			// Agent is not running (e.g. it was killed) hence cannot stop its tasks
+			logger.Error("main", "Reverting without agent since it is not reachable via '%s'", config.LocalSocketPath)
+
+			result = revertWithoutAgent(config, fs, cmdRunner, logger)
+		}
+
+		err := printJSON(result)
+		if err != nil {
+			return err
+		}
+
+		if len(result.Error) > 0 {
+			return bosherr.Error(result.Error)
+		}
+
+		return nil
+
+	default:
+		return bosherr.Errorf("Unknown command '%s' (expected 'status' or 'revert')", name)
+	}
+}
+
+// revertWithoutAgent kills leftover fault processes and reverts recorded changes
+func revertWithoutAgent(config Config, fs boshsys.FileSystem, cmdRunner boshsys.CmdRunner, logger boshlog.Logger) LocalRevertResult {
+	result := LocalRevertResult{StoppedTaskIDs: []string{}, RunningTaskIDs: []string{}}
+
+	var errs []error
+
+	_, err := NewFaultLimits(config.FaultLimits, fs, logger).KillAll(cmdRunner)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = tasks.NewJournal(config.JournalDir, fs, cmdRunner, logger).RevertAll()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		result.Error = bosherr.NewMultiError(errs...).Error()
+	}
+
+	return result
+}
+
+func printJSON(val interface{}) error {
+	bytes, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling output")
+	}
+
+	fmt.Println(string(bytes))
+
+	return nil
+}
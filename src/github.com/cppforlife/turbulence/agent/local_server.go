@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// Tasks are expected to revert their changes quickly once stopped
+var emergencyRevertTimeout = 30 * time.Second
+
+type LocalStatus struct {
+	AgentID string
+	Tasks   []LocalTaskStatus
+
+	// Changes recorded by tasks that are no longer running (e.g. before agent restart)
+	LeftoverChanges []tasks.JournalRecord
+}
+
+type LocalTaskStatus struct {
+	ID        string
+	Type      string
+	State     string
+	StartedAt time.Time
+	Stopped   bool
+	DryRun    bool `json:",omitempty"`
+
+	Options tasks.OptionsSlice
+
+	// Commands that would revert changes applied by the task so far
+	RevertCmds []tasks.JournalCmd
+}
+
+type LocalRevertResult struct {
+	StoppedTaskIDs []string
+
+	// Tasks that did not finish reverting their changes in time;
+	// their changes are left for them to revert
+	RunningTaskIDs []string
+
+	Error string `json:",omitempty"`
+}
+
+// LocalStatus lists tasks active on this host with their applied changes
+func (a Agent) LocalStatus() (LocalStatus, error) {
+	status := LocalStatus{AgentID: a.agentID, Tasks: []LocalTaskStatus{}}
+
+	records, err := a.journal.Records()
+	if err != nil {
+		return status, err
+	}
+
+	recordsByTaskID := map[string]tasks.JournalRecord{}
+
+	for _, record := range records {
+		recordsByTaskID[record.TaskID] = record
+	}
+
+	for _, running := range a.running.List() {
+		status.Tasks = append(status.Tasks, LocalTaskStatus{
+			ID:        running.Task.ID,
+			Type:      tasks.OptionsType(running.Task.Options()),
+			State:     running.State(),
+			StartedAt: running.StartedAt,
+			Stopped:   running.Stopped,
+			DryRun:    running.Task.DryRun,
+
+			Options:    running.Task.Optionss,
+			RevertCmds: recordsByTaskID[running.Task.ID].RevertCmds,
+		})
+
+		delete(recordsByTaskID, running.Task.ID)
+	}
+
+	for _, record := range records {
+		if _, found := recordsByTaskID[record.TaskID]; found {
+			status.LeftoverChanges = append(status.LeftoverChanges, record)
+		}
+	}
+
+	return status, nil
+}
+
+// EmergencyRevert stops all running tasks and reverts changes recorded
+// in the journal by tasks that are no longer running without involving the API
+func (a Agent) EmergencyRevert() LocalRevertResult {
+	result := LocalRevertResult{StoppedTaskIDs: []string{}, RunningTaskIDs: []string{}}
+
+	for _, running := range a.running.List() {
+		if a.running.Stop(running.Task.ID) {
+			result.StoppedTaskIDs = append(result.StoppedTaskIDs, running.Task.ID)
+		}
+	}
+
+	a.logger.Error(a.logTag, "Stopped %d agent task(s) via emergency revert", len(result.StoppedTaskIDs))
+
+	for deadline := time.Now().Add(emergencyRevertTimeout); time.Now().Before(deadline); {
+		if len(a.running.List()) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	runningIDs := map[string]struct{}{}
+
+	for _, running := range a.running.List() {
+		result.RunningTaskIDs = append(result.RunningTaskIDs, running.Task.ID)
+		runningIDs[running.Task.ID] = struct{}{}
+	}
+
+	records, err := a.journal.Records()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// Tasks that are still reverting would conflict with reverting their changes here
+	for _, record := range records {
+		if _, found := runningIDs[record.TaskID]; found {
+			continue
+		}
+
+		err := a.journal.Revert(record.TaskID)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	return result
+}
+
+// LocalServer serves status and emergency revert requests over a unix socket
+// that is only accessible to root on the same host
+type LocalServer struct {
+	socketPath string
+	agent      Agent
+	fs         boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewLocalServer(socketPath string, agent Agent, fs boshsys.FileSystem, logger boshlog.Logger) LocalServer {
+	return LocalServer{socketPath, agent, fs, "agent.LocalServer", logger}
+}
+
+func (s LocalServer) ListenAndServe() error {
+	err := s.fs.MkdirAll(filepath.Dir(s.socketPath), 0700)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating local socket directory")
+	}
+
+	// Socket may be left over from previous agent run
+	err = s.fs.RemoveAll(s.socketPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Removing local socket")
+	}
+
+	// Socket is created with 0600 permissions so that it's never accessible to other users
+	oldUmask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", s.socketPath)
+	syscall.Umask(oldUmask)
+
+	if err != nil {
+		return bosherr.WrapError(err, "Listening on local socket")
+	}
+
+	defer listener.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.status)
+	mux.HandleFunc("/revert", s.revert)
+
+	s.logger.Debug(s.logTag, "Serving local API on '%s'", s.socketPath)
+
+	return http.Serve(listener, mux)
+}
+
+func (s LocalServer) status(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, err := s.agent.LocalStatus()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, status)
+}
+
+func (s LocalServer) revert(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeJSON(w, s.agent.EmergencyRevert())
+}
+
+func (s LocalServer) writeJSON(w http.ResponseWriter, val interface{}) {
+	bytes, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(bytes)
+	if err != nil {
+		s.logger.Error(s.logTag, "Failed writing response: %s", err)
+	}
+}
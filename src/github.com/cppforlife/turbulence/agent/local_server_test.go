@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Agent EmergencyRevert", func() {
+	var (
+		dir       string
+		cmdRunner *fakesys.FakeCmdRunner
+		journal   tasks.Journal
+		agent     Agent
+		logger    boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		dir, err = ioutil.TempDir("", "turbulence-journal")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		journal = tasks.NewJournal(dir, boshsys.NewOsFileSystem(logger), cmdRunner, logger)
+
+		agent = newAgent("agent1", AgentConfig{}, agentreg.Capabilities{}, nil, nil, cmdRunner, journal, tasks.ContainerFinder{}, logger)
+
+		emergencyRevertTimeout = 100 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		emergencyRevertTimeout = 30 * time.Second
+		os.RemoveAll(dir)
+	})
+
+	It("leaves changes of tasks that are still reverting to them", func() {
+		Expect(journal.ForTask("running").Record("revert-running")).To(Succeed())
+		Expect(journal.ForTask("leftover").Record("revert-leftover")).To(Succeed())
+
+		task := tasks.Task{ID: "running", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}
+		agent.running.Add(task, newTaskProgress(task.ID, Client{}, logger))
+
+		result := agent.EmergencyRevert()
+		Expect(result.StoppedTaskIDs).To(Equal([]string{"running"}))
+		Expect(result.RunningTaskIDs).To(Equal([]string{"running"}))
+		Expect(result.Error).To(BeEmpty())
+
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{{"revert-leftover"}}))
+
+		records, err := journal.Records()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].TaskID).To(Equal("running"))
+	})
+})
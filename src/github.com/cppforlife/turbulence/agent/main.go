@@ -11,6 +11,9 @@ import (
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
+// Version is reported to the API; set with -ldflags "-X main.version=..."
+var version = "dev"
+
 var (
 	debugOpt      = flag.Bool("debug", false, "Output debug logs")
 	configPathOpt = flag.String("configPath", "", "Path to configuration file")
@@ -28,11 +31,33 @@ func main() {
 	config, err := NewConfigFromPath(*configPathOpt, fs)
 	ensureNoErr(logger, "Loading config", err)
 
-	factory := NewFactory(config.AgentID, config.API, fs, cmdRunner, logger)
+	// e.g. agent -configPath=... revert
+	if len(flag.Args()) > 0 {
+		err = runLocalCommand(flag.Arg(0), config, fs, cmdRunner, logger)
+		ensureNoErr(logger, "Running command", err)
+		return
+	}
+
+	// Agent must survive memory pressure caused by faults to be able to stop them
+	err = ProtectFromOOMKiller(fs, config.OOMScoreAdj)
+	ensureNoErr(logger, "Protecting from OOM killer", err)
+
+	factory := NewFactory(config, fs, cmdRunner, logger)
 
 	agent, err := factory.New()
 	ensureNoErr(logger, "Building agent", err)
 
+	go func() {
+		err := NewLocalServer(config.LocalSocketPath, agent, fs, logger).ListenAndServe()
+		logger.Error("main", "Serving local API: %s", err)
+	}()
+
+	// Continue executing tasks even if some changes could not be reverted
+	err = agent.RevertLeftoverChanges()
+	if err != nil {
+		logger.Error("main", "Reverting leftover changes: %s", err)
+	}
+
 	err = agent.ContiniouslyExecuteTasks()
 	ensureNoErr(logger, "Executing tasks", err)
 }
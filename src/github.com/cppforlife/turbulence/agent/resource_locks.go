@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// resourceLocks makes sure that tasks changing the same system resources
+// do not run at the same time and corrupt each other's revert
+type resourceLocks struct {
+	owners map[string]string // resource -> task ID
+	lock   sync.Mutex
+}
+
+func newResourceLocks() *resourceLocks {
+	return &resourceLocks{owners: map[string]string{}}
+}
+
+// Acquire locks all resources or none of them
+func (l *resourceLocks) Acquire(taskID string, resources []string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	conflicts := map[string][]string{}
+
+	for _, res := range resources {
+		if owner, found := l.owners[res]; found && owner != taskID {
+			conflicts[owner] = append(conflicts[owner], res)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		var descs []string
+
+		for owner, ress := range conflicts {
+			descs = append(descs, "'"+strings.Join(ress, "', '")+"' held by task '"+owner+"'")
+		}
+
+		sort.Strings(descs)
+
+		return bosherr.Errorf("Conflicting with running tasks: %s", strings.Join(descs, "; "))
+	}
+
+	for _, res := range resources {
+		l.owners[res] = taskID
+	}
+
+	return nil
+}
+
+func (l *resourceLocks) Release(taskID string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for res, owner := range l.owners {
+		if owner == taskID {
+			delete(l.owners, res)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// runningTasks keeps track of tasks executed by the agent so that they could be stopped or adjusted
+type runningTasks struct {
+	tasks   map[string]runningTask
+	stopChs map[string]chan struct{}
+	stopped map[string]struct{}
+
+	adjustChs          map[string]chan tasks.Options
+	adjustmentVersions map[string]int
+
+	lock sync.Mutex
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{
+		tasks:   map[string]runningTask{},
+		stopChs: map[string]chan struct{}{},
+		stopped: map[string]struct{}{},
+
+		adjustChs:          map[string]chan tasks.Options{},
+		adjustmentVersions: map[string]int{},
+	}
+}
+
+type runningTask struct {
+	Task      tasks.Task
+	StartedAt time.Time
+	Stopped   bool
+
+	progress *taskProgress
+}
+
+func (t runningTask) State() string { return t.progress.State() }
+
+func (r *runningTasks) Add(task tasks.Task, progress *taskProgress) (chan struct{}, <-chan tasks.Options) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	taskID := task.ID
+	r.tasks[taskID] = runningTask{Task: task, StartedAt: time.Now().UTC(), progress: progress}
+
+	stopCh := make(chan struct{})
+	r.stopChs[taskID] = stopCh
+
+	// Only latest options are kept if task has not picked up previous ones
+	adjustCh := make(chan tasks.Options, 1)
+	r.adjustChs[taskID] = adjustCh
+
+	return stopCh, adjustCh
+}
+
+func (r *runningTasks) Remove(taskID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.tasks, taskID)
+	delete(r.stopChs, taskID)
+	delete(r.stopped, taskID)
+	delete(r.adjustChs, taskID)
+	delete(r.adjustmentVersions, taskID)
+}
+
+// Adjust passes new options to a running task; rejected adjustments are reported
+// in task's output and still count as seen so that API does not keep sending them
+func (r *runningTasks) Adjust(adj tasks.Adjustment) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// API fails tasks that agent does not report as running
+	task, found := r.tasks[adj.TaskID]
+	if !found {
+		return bosherr.Errorf("Task '%s' is not running", adj.TaskID)
+	}
+
+	if adj.Version <= r.adjustmentVersions[adj.TaskID] {
+		return nil // already seen
+	}
+
+	r.adjustmentVersions[adj.TaskID] = adj.Version
+
+	err := tasks.ValidateAdjustment(task.Task, adj.Options)
+	if err != nil {
+		fmt.Fprintf(task.progress, "Rejected adjustment (version %d): %s\n", adj.Version, err)
+		return bosherr.WrapErrorf(err, "Rejecting adjustment of task '%s'", adj.TaskID)
+	}
+
+	adjustCh := r.adjustChs[adj.TaskID]
+
+	// Never blocks since lock is held by the only sender
+	select {
+	case <-adjustCh:
+	default:
+	}
+
+	adjustCh <- adj.Options[0]
+
+	return nil
+}
+
+func (r *runningTasks) AdjustmentVersions() map[string]int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	versions := map[string]int{}
+
+	for taskID, version := range r.adjustmentVersions {
+		versions[taskID] = version
+	}
+
+	return versions
+}
+
+// Stop can be called multiple times for the same task
+func (r *runningTasks) Stop(taskID string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.stop(taskID)
+}
+
+func (r *runningTasks) StopAll() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var num int
+
+	for taskID := range r.stopChs {
+		if r.stop(taskID) {
+			num++
+		}
+	}
+
+	return num
+}
+
+// IDs returns non-nil list so that API could tell that agent reports running tasks
+func (r *runningTasks) IDs() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ids := []string{}
+
+	for taskID := range r.tasks {
+		ids = append(ids, taskID)
+	}
+
+	return ids
+}
+
+func (r *runningTasks) StoppedIDs() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ids := []string{}
+
+	for taskID := range r.stopped {
+		ids = append(ids, taskID)
+	}
+
+	return ids
+}
+
+// List returns running tasks ordered by start time
+func (r *runningTasks) List() []runningTask {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var list []runningTask
+
+	for taskID, task := range r.tasks {
+		_, task.Stopped = r.stopped[taskID]
+		list = append(list, task)
+	}
+
+	sort.Sort(runningTasksByStart(list))
+
+	return list
+}
+
+type runningTasksByStart []runningTask
+
+func (s runningTasksByStart) Len() int           { return len(s) }
+func (s runningTasksByStart) Less(i, j int) bool { return s[i].StartedAt.Before(s[j].StartedAt) }
+func (s runningTasksByStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (r *runningTasks) stop(taskID string) bool {
+	stopCh, found := r.stopChs[taskID]
+	if !found {
+		return false
+	}
+
+	if _, found := r.stopped[taskID]; found {
+		return false
+	}
+
+	close(stopCh)
+	r.stopped[taskID] = struct{}{}
+
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("runningTasks", func() {
+	var (
+		running *runningTasks
+		task    tasks.Task
+	)
+
+	BeforeEach(func() {
+		running = newRunningTasks()
+		task = tasks.Task{ID: "task1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}
+	})
+
+	It("reports running tasks as a non-nil list", func() {
+		Expect(running.IDs()).To(Equal([]string{}))
+
+		running.Add(task, newTaskProgress(task.ID, Client{}, boshlog.NewLogger(boshlog.LevelNone)))
+		Expect(running.IDs()).To(Equal([]string{"task1"}))
+
+		running.Remove(task.ID)
+		Expect(running.IDs()).To(Equal([]string{}))
+	})
+
+	It("stops running tasks once and ignores unknown tasks", func() {
+		stopCh, _ := running.Add(task, newTaskProgress(task.ID, Client{}, boshlog.NewLogger(boshlog.LevelNone)))
+
+		Expect(running.Stop("unknown")).To(BeFalse())
+		Expect(running.StoppedIDs()).To(Equal([]string{}))
+
+		Expect(running.Stop(task.ID)).To(BeTrue())
+		Expect(running.Stop(task.ID)).To(BeFalse())
+		Expect(running.StoppedIDs()).To(Equal([]string{"task1"}))
+		Expect(stopCh).To(BeClosed())
+	})
+})
+
+var _ = Describe("runningTasks adjustments", func() {
+	var (
+		running  *runningTasks
+		progress *taskProgress
+		adjustCh <-chan tasks.Options
+	)
+
+	BeforeEach(func() {
+		running = newRunningTasks()
+		progress = newTaskProgress("task1", Client{}, boshlog.NewLogger(boshlog.LevelNone))
+
+		task := tasks.Task{ID: "task1", Optionss: tasks.OptionsSlice{tasks.StressOptions{NumCPUWorkers: 1}}}
+		_, adjustCh = running.Add(task, progress)
+	})
+
+	It("passes new options to the task", func() {
+		adj := tasks.Adjustment{TaskID: "task1", Version: 1, Options: tasks.OptionsSlice{tasks.StressOptions{NumCPUWorkers: 2}}}
+
+		Expect(running.Adjust(adj)).To(Succeed())
+		Expect(adjustCh).To(Receive(Equal(tasks.StressOptions{NumCPUWorkers: 2})))
+		Expect(running.AdjustmentVersions()).To(Equal(map[string]int{"task1": 1}))
+
+		// Already seen versions are ignored
+		Expect(running.Adjust(adj)).To(Succeed())
+		Expect(adjustCh).ToNot(Receive())
+	})
+
+	It("records version of rejected adjustments and reports rejection in task output", func() {
+		adj := tasks.Adjustment{TaskID: "task1", Version: 2, Options: tasks.OptionsSlice{tasks.NoopOptions{}}}
+
+		err := running.Adjust(adj)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must match task type 'Stress'"))
+
+		Expect(adjustCh).ToNot(Receive())
+		Expect(running.AdjustmentVersions()).To(Equal(map[string]int{"task1": 2}))
+		Expect(progress.output.String()).To(ContainSubstring("Rejected adjustment (version 2)"))
+	})
+
+	It("returns error for tasks that are not running", func() {
+		err := running.Adjust(tasks.Adjustment{TaskID: "unknown", Version: 1})
+		Expect(err).To(HaveOccurred())
+		Expect(running.AdjustmentVersions()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("pollDelay", func() {
+	startedAt := time.Now()
+
+	It("delays polls that quickly return only stops or adjustments", func() {
+		resp := tasks.PollResponse{StoppedTaskIDs: []string{"task1"}}
+
+		Expect(pollDelay(startedAt, startedAt.Add(100*time.Millisecond), resp)).To(Equal(900 * time.Millisecond))
+		Expect(pollDelay(startedAt, startedAt.Add(30*time.Second), resp)).To(BeZero())
+	})
+
+	It("does not delay picking up new tasks", func() {
+		resp := tasks.PollResponse{Tasks: []tasks.Task{{ID: "task1"}}}
+
+		Expect(pollDelay(startedAt, startedAt, resp)).To(BeZero())
+	})
+})
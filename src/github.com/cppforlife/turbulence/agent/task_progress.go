@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+const taskHeartbeatInterval = 10 * time.Second
+
+// taskProgress sends task state changes, heartbeats and
+// captured command output to the API while task is running
+type taskProgress struct {
+	taskID string
+	client APIClient
+
+	output     bytes.Buffer
+	outputLock sync.Mutex
+
+	// Used for categorizing task errors
+	state     string
+	stateLock sync.Mutex
+
+	// Keeps progress requests in order
+	sendLock sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func newTaskProgress(taskID string, client APIClient, logger boshlog.Logger) *taskProgress {
+	return &taskProgress{
+		taskID: taskID,
+		client: client,
+
+		logTag: "taskProgress",
+		logger: logger,
+	}
+}
+
+func (p *taskProgress) ReportState(state string) {
+	p.stateLock.Lock()
+	p.state = state
+	p.stateLock.Unlock()
+
+	p.send(state)
+}
+
+// State returns last reported state; empty until task reports it
+func (p *taskProgress) State() string {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+
+	return p.state
+}
+
+// Error categorizes task execution error based on what task was doing when it failed
+// and which command (if any) caused it
+func (p *taskProgress) Error(err error) tasks.TaskError {
+	failedCmd := failedCmdFromErr(err)
+
+	category := tasks.ErrorCategory(err, failedCmd, p.State() == tasks.TaskStateReverting)
+
+	return tasks.NewTaskError(category, err, failedCmd)
+}
+
+// Heartbeat sends heartbeats (with any captured output) until doneCh is closed
+func (p *taskProgress) Heartbeat(doneCh chan struct{}) {
+	ticker := time.NewTicker(taskHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.send("")
+		case <-doneCh:
+			p.send("") // flush remaining output
+			return
+		}
+	}
+}
+
+func (p *taskProgress) Write(b []byte) (int, error) {
+	p.outputLock.Lock()
+	defer p.outputLock.Unlock()
+
+	return p.output.Write(b)
+}
+
+func (p *taskProgress) send(state string) {
+	p.sendLock.Lock()
+	defer p.sendLock.Unlock()
+
+	p.outputLock.Lock()
+	output := p.output.String()
+	p.output.Reset()
+	p.outputLock.Unlock()
+
+	err := p.client.RecordTaskProgress(p.taskID, tasks.ProgressRequest{State: state, Output: output})
+	if err != nil {
+		// Output is dropped since API would not be able to show it in order anyway
+		p.logger.Error(p.logTag, "Failed recording task '%s' progress: %s", p.taskID, err.Error())
+	}
+}
+
+// progressCmdRunner captures output of commands run by a task
+type progressCmdRunner struct {
+	boshsys.CmdRunner
+	progress *taskProgress
+}
+
+func (r progressCmdRunner) RunComplexCommand(cmd boshsys.Command) (string, string, int, error) {
+	stdout, stderr, exitStatus, err := r.CmdRunner.RunComplexCommand(cmd)
+	return stdout, stderr, exitStatus, r.record(cmd.Name, cmd.Args, stdout, stderr, exitStatus, err)
+}
+
+func (r progressCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	fmt.Fprintf(r.progress, "$ %s %s\n", cmd.Name, strings.Join(cmd.Args, " "))
+
+	// Stream output of long running commands (e.g. stress) as it is produced
+	if cmd.Stdout == nil {
+		cmd.Stdout = r.progress
+	}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = r.progress
+	}
+
+	return r.CmdRunner.RunComplexCommandAsync(cmd)
+}
+
+func (r progressCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	stdout, stderr, exitStatus, err := r.CmdRunner.RunCommand(cmdName, args...)
+	return stdout, stderr, exitStatus, r.record(cmdName, args, stdout, stderr, exitStatus, err)
+}
+
+func (r progressCmdRunner) RunCommandWithInput(input, cmdName string, args ...string) (string, string, int, error) {
+	stdout, stderr, exitStatus, err := r.CmdRunner.RunCommandWithInput(input, cmdName, args...)
+	return stdout, stderr, exitStatus, r.record(cmdName, args, stdout, stderr, exitStatus, err)
+}
+
+// record returns command error with details of the failed command
+func (r progressCmdRunner) record(cmdName string, args []string, stdout, stderr string, exitStatus int, err error) error {
+	cmd := strings.TrimSpace(cmdName + " " + strings.Join(args, " "))
+
+	fmt.Fprintf(r.progress, "$ %s\n%s%s", cmd, stdout, stderr)
+
+	if exitStatus != 0 {
+		fmt.Fprintf(r.progress, "(exit status %d)\n", exitStatus)
+	}
+
+	if err != nil {
+		return failedCmdError{err, tasks.FailedCmd{Cmd: cmd, ExitStatus: exitStatus, Output: stdout + stderr}}
+	}
+
+	return nil
+}
+
+// failedCmdError keeps failed command with its error so that task error refers
+// to the command that caused it and not to other (possibly tolerated) failures
+type failedCmdError struct {
+	error
+	cmd tasks.FailedCmd
+}
+
+// failedCmdFromErr returns nil if error was not caused by a failed command
+func failedCmdFromErr(err error) *tasks.FailedCmd {
+	for err != nil {
+		switch typedErr := err.(type) {
+		case failedCmdError:
+			return &typedErr.cmd
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
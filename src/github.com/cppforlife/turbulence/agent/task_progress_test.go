@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("taskProgress", func() {
+	var (
+		cmdRunner *fakesys.FakeCmdRunner
+		progress  *taskProgress
+		runner    progressCmdRunner
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		progress = newTaskProgress("task1", Client{}, boshlog.NewLogger(boshlog.LevelNone))
+		runner = progressCmdRunner{cmdRunner, progress}
+
+		cmdRunner.AddCmdResult("tc qdisc change dev eth0", fakesys.FakeCmdResult{
+			Stderr: "RTNETLINK answers: Invalid argument\n", ExitStatus: 2, Error: errors.New("exit status 2"),
+		})
+	})
+
+	It("refers to the failed command that caused task error", func() {
+		_, _, _, err := runner.RunCommand("tc", "qdisc", "change", "dev", "eth0")
+		Expect(err).To(MatchError("exit status 2"))
+
+		taskErr := progress.Error(bosherr.WrapError(err, "Adjusting netem"))
+		Expect(taskErr.Cmd).To(Equal("tc qdisc change dev eth0"))
+		Expect(taskErr.ExitStatus).To(Equal(2))
+		Expect(taskErr.Output).To(Equal("RTNETLINK answers: Invalid argument\n"))
+	})
+
+	It("does not refer to earlier tolerated command failures", func() {
+		_, _, _, err := runner.RunCommand("tc", "qdisc", "change", "dev", "eth0")
+		Expect(err).To(HaveOccurred())
+
+		taskErr := progress.Error(bosherr.WrapError(errors.New("fake-err"), "Waiting"))
+		Expect(taskErr.Cmd).To(BeEmpty())
+		Expect(taskErr.ExitStatus).To(BeZero())
+	})
+})
@@ -0,0 +1,21 @@
+package agentreg
+
+import (
+	"time"
+)
+
+// Agents long poll for tasks for up to 30s (older agents poll every second)
+const aliveThreshold = 45 * time.Second
+
+type Agent struct {
+	ID string
+
+	Capabilities Capabilities
+	RegisteredAt time.Time // zero if agent never registered (e.g. older agent)
+
+	LastSeenAt time.Time
+}
+
+func (a Agent) IsRegistered() bool { return a.RegisteredAt != time.Time{} }
+
+func (a Agent) IsAlive() bool { return time.Since(a.LastSeenAt) < aliveThreshold }
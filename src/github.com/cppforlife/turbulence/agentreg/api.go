@@ -0,0 +1,139 @@
+package agentreg
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cppforlife/turbulence/director"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+type Response struct {
+	ID string
+
+	// Empty if agent does not belong to any known instance
+	Instance *InstanceResp `json:",omitempty"`
+
+	// Empty if agent was never seen (e.g. agent is not colocated)
+	LastSeenAt string
+	Alive      bool
+
+	// Empty if agent never registered its capabilities
+	Capabilities *Capabilities `json:",omitempty"`
+
+	ActiveTasks []TaskResp
+}
+
+type InstanceResp struct {
+	ID         string
+	Group      string
+	Deployment string
+	AZ         string
+}
+
+type TaskResp struct {
+	ID   string
+	Type string
+}
+
+type Responses []Response
+
+// NewResponses lists agents for all instances that have VMs
+// followed by agents that do not belong to any known instance.
+func NewResponses(instances []director.Instance, agents []Agent, activeTasks map[string][]tasks.Task) Responses {
+	resps := Responses{}
+
+	agentsByID := map[string]Agent{}
+
+	for _, agent := range agents {
+		agentsByID[agent.ID] = agent
+	}
+
+	sort.Sort(instancesByName(instances))
+
+	for _, inst := range instances {
+		if !inst.HasVM() {
+			continue
+		}
+
+		agent, found := agentsByID[inst.AgentID()]
+		if !found {
+			agent = Agent{ID: inst.AgentID()}
+		}
+
+		resp := NewResponse(agent, activeTasks[agent.ID])
+		resp.Instance = &InstanceResp{
+			ID:         inst.ID(),
+			Group:      inst.Group(),
+			Deployment: inst.Deployment(),
+			AZ:         inst.AZ(),
+		}
+
+		resps = append(resps, resp)
+
+		delete(agentsByID, agent.ID)
+	}
+
+	for _, agent := range agents {
+		if _, found := agentsByID[agent.ID]; found {
+			resps = append(resps, NewResponse(agent, activeTasks[agent.ID]))
+		}
+	}
+
+	return resps
+}
+
+func NewResponse(agent Agent, activeTasks []tasks.Task) Response {
+	resp := Response{
+		ID: agent.ID,
+
+		ActiveTasks: []TaskResp{},
+	}
+
+	if (agent.LastSeenAt != time.Time{}) {
+		resp.LastSeenAt = agent.LastSeenAt.Format(time.RFC3339)
+		resp.Alive = agent.IsAlive()
+	}
+
+	if agent.IsRegistered() {
+		caps := agent.Capabilities
+		resp.Capabilities = &caps
+	}
+
+	for _, task := range activeTasks {
+		resp.ActiveTasks = append(resp.ActiveTasks, TaskResp{ID: task.ID, Type: tasks.OptionsType(task.Options())})
+	}
+
+	return resp
+}
+
+func (r Response) IsMissing() bool { return len(r.LastSeenAt) == 0 }
+
+func (r Response) HasInstance() bool { return r.Instance != nil }
+
+func (r Responses) NumMissing() int {
+	var num int
+
+	for _, resp := range r {
+		if resp.IsMissing() {
+			num++
+		}
+	}
+
+	return num
+}
+
+type instancesByName []director.Instance
+
+func (s instancesByName) Len() int      { return len(s) }
+func (s instancesByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s instancesByName) Less(i, j int) bool {
+	if s[i].Deployment() != s[j].Deployment() {
+		return s[i].Deployment() < s[j].Deployment()
+	}
+	if s[i].Group() != s[j].Group() {
+		return s[i].Group() < s[j].Group()
+	}
+	return s[i].ID() < s[j].ID()
+}
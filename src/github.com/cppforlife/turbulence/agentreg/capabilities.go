@@ -0,0 +1,74 @@
+package agentreg
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// Capabilities are reported by an agent when it registers with the API
+type Capabilities struct {
+	Version string
+
+	TaskTypes []string
+	Tools     []string
+
+	// Supported task types that agent is configured to refuse
+	DisallowedTaskTypes []string `json:",omitempty"`
+
+	// Older agents would execute dry run tasks for real
+	DryRun bool
+
+	// Set by agents that do not run on BOSH VMs
+	Host *Host `json:",omitempty"`
+}
+
+// Host describes where agent that does not run on a BOSH VM
+// belongs so that it could be selected by incidents
+type Host struct {
+	Deployment string
+	Group      string
+	AZ         string `json:",omitempty"`
+}
+
+func (c Capabilities) CanRun(taskOpts tasks.Options) error {
+	// Kill task is executed by the API and does not involve agents
+	if _, ok := taskOpts.(tasks.KillOptions); ok {
+		return nil
+	}
+
+	taskType := tasks.OptionsType(taskOpts)
+
+	if c.includes(c.DisallowedTaskTypes, taskType) {
+		return bosherr.Errorf("Agent does not allow task type '%s'", taskType)
+	}
+
+	if !c.includes(c.TaskTypes, taskType) {
+		return bosherr.Errorf("Agent (version '%s') does not support task type '%s'", c.Version, taskType)
+	}
+
+	var missingTools []string
+
+	for _, tool := range tasks.RequiredTools(taskOpts) {
+		if !c.includes(c.Tools, tool) {
+			missingTools = append(missingTools, tool)
+		}
+	}
+
+	if len(missingTools) > 0 {
+		return bosherr.Errorf("Agent is missing '%s'", strings.Join(missingTools, "', '"))
+	}
+
+	return nil
+}
+
+func (Capabilities) includes(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package agentreg_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Capabilities", func() {
+	caps := Capabilities{
+		Version:   "1",
+		TaskTypes: []string{"Stress", "Firewall", "KillProcess"},
+		Tools:     []string{"stress", "pkill"},
+	}
+
+	It("allows tasks with supported type and available tools", func() {
+		Expect(caps.CanRun(tasks.StressOptions{NumCPUWorkers: 1})).ToNot(HaveOccurred())
+		Expect(caps.CanRun(tasks.KillProcessOptions{ProcessName: "name"})).ToNot(HaveOccurred())
+	})
+
+	It("allows kill tasks since they do not involve agents", func() {
+		Expect(Capabilities{}.CanRun(tasks.KillOptions{})).ToNot(HaveOccurred())
+	})
+
+	It("rejects unsupported task types", func() {
+		err := caps.CanRun(tasks.ControlNetOptions{Delay: "50ms"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("does not support task type 'ControlNet'"))
+	})
+
+	It("rejects task types disallowed by agent configuration", func() {
+		caps := Capabilities{TaskTypes: []string{"Stress", "Shutdown"}, DisallowedTaskTypes: []string{"Shutdown"}}
+
+		err := caps.CanRun(tasks.ShutdownOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("does not allow task type 'Shutdown'"))
+	})
+
+	It("rejects tasks with missing tools", func() {
+		err := caps.CanRun(tasks.FirewallOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("missing 'iptables'"))
+
+		err = caps.CanRun(tasks.KillProcessOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("missing 'monit', 'kill'"))
+	})
+})
@@ -0,0 +1,58 @@
+package agentreg
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"github.com/cppforlife/turbulence/director"
+)
+
+// HostsDirector includes hosts with agents that do not run on BOSH VMs
+// (e.g. bastions or external databases) as instances of a BOSH Director
+type HostsDirector struct {
+	director.Director
+	agentsRepo Repo
+}
+
+func NewHostsDirector(director director.Director, agentsRepo Repo) HostsDirector {
+	return HostsDirector{director, agentsRepo}
+}
+
+func (d HostsDirector) AllInstances() ([]director.Instance, error) {
+	instances, err := d.Director.AllInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := d.agentsRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, agent := range agents {
+		// Hosts that went away are not known to any Director
+		if agent.Capabilities.Host != nil && agent.IsAlive() {
+			instances = append(instances, HostInstance{agent.ID, *agent.Capabilities.Host})
+		}
+	}
+
+	return instances, nil
+}
+
+type HostInstance struct {
+	agentID string
+	host    Host
+}
+
+var _ director.Instance = HostInstance{}
+
+func (i HostInstance) ID() string         { return i.agentID }
+func (i HostInstance) Group() string      { return i.host.Group }
+func (i HostInstance) Deployment() string { return i.host.Deployment }
+func (i HostInstance) AZ() string         { return i.host.AZ }
+
+func (i HostInstance) AgentID() string { return i.agentID }
+func (i HostInstance) HasVM() bool     { return true }
+
+func (i HostInstance) DeleteVM() error {
+	return bosherr.Errorf("Cannot delete host '%s' since it is not a BOSH VM", i.agentID)
+}
@@ -0,0 +1,38 @@
+package agentreg_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/director"
+)
+
+type noInstancesDirector struct {
+	director.Director
+}
+
+func (noInstancesDirector) AllInstances() ([]director.Instance, error) { return nil, nil }
+
+var _ = Describe("HostsDirector", func() {
+	It("includes alive agents that registered as hosts", func() {
+		repo := NewRepo(boshlog.NewLogger(boshlog.LevelNone))
+
+		err := repo.Register("db-1", Capabilities{Host: &Host{Deployment: "external", Group: "db"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = repo.Register("bosh-vm", Capabilities{})
+		Expect(err).ToNot(HaveOccurred())
+
+		instances, err := NewHostsDirector(noInstancesDirector{}, repo).AllInstances()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instances).To(HaveLen(1))
+
+		Expect(instances[0].AgentID()).To(Equal("db-1"))
+		Expect(instances[0].Deployment()).To(Equal("external"))
+		Expect(instances[0].Group()).To(Equal("db"))
+		Expect(instances[0].DeleteVM()).To(HaveOccurred())
+	})
+})
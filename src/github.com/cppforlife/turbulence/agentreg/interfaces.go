@@ -0,0 +1,10 @@
+package agentreg
+
+type Repo interface {
+	Register(string, Capabilities) error
+	Seen(string) error
+
+	// Find only returns agents that registered their capabilities
+	Find(string) (Agent, bool, error)
+	ListAll() ([]Agent, error)
+}
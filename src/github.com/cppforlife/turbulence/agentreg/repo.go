@@ -0,0 +1,95 @@
+package agentreg
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type repo struct {
+	agents     map[string]Agent
+	agentsLock sync.RWMutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewRepo(logger boshlog.Logger) Repo {
+	return &repo{
+		agents: map[string]Agent{},
+
+		logTag: "agentreg.repo",
+		logger: logger,
+	}
+}
+
+func (r *repo) Register(agentID string, caps Capabilities) error {
+	if len(agentID) == 0 {
+		return bosherr.Error("Must provide non-empty agent ID")
+	}
+
+	r.agentsLock.Lock()
+	defer r.agentsLock.Unlock()
+
+	agent := r.agents[agentID]
+	agent.ID = agentID
+	agent.Capabilities = caps
+	agent.RegisteredAt = time.Now().UTC()
+	agent.LastSeenAt = agent.RegisteredAt
+
+	r.agents[agentID] = agent
+
+	r.logger.Debug(r.logTag, "Registered agent '%s' with capabilities '%#v'", agentID, caps)
+
+	return nil
+}
+
+func (r *repo) Seen(agentID string) error {
+	if len(agentID) == 0 {
+		return bosherr.Error("Must provide non-empty agent ID")
+	}
+
+	r.agentsLock.Lock()
+	defer r.agentsLock.Unlock()
+
+	agent := r.agents[agentID]
+	agent.ID = agentID
+	agent.LastSeenAt = time.Now().UTC()
+
+	r.agents[agentID] = agent
+
+	return nil
+}
+
+func (r *repo) Find(agentID string) (Agent, bool, error) {
+	r.agentsLock.RLock()
+	defer r.agentsLock.RUnlock()
+
+	agent, found := r.agents[agentID]
+
+	return agent, found && agent.IsRegistered(), nil
+}
+
+func (r *repo) ListAll() ([]Agent, error) {
+	r.agentsLock.RLock()
+	defer r.agentsLock.RUnlock()
+
+	var agents []Agent
+
+	for _, agent := range r.agents {
+		agents = append(agents, agent)
+	}
+
+	sort.Sort(agentsByID(agents))
+
+	return agents, nil
+}
+
+type agentsByID []Agent
+
+func (s agentsByID) Len() int           { return len(s) }
+func (s agentsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s agentsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
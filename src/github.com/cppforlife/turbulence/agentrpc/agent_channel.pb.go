@@ -0,0 +1,1584 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: agent_channel.proto
+
+/*
+Package agentrpc is a generated protocol buffer package.
+
+It is generated from these files:
+
+	agent_channel.proto
+
+It has these top-level messages:
+
+	Request
+	Reply
+	Hello
+	Register
+	Capabilities
+	Host
+	Poll
+	PollUpdate
+	Adjustment
+	Progress
+	Result
+	TaskError
+	Task
+	Options
+	NoopOptions
+	KillOptions
+	KillProcessOptions
+	StressOptions
+	ControlNetOptions
+	FirewallOptions
+	FillDiskOptions
+	ShutdownOptions
+	RampOptions
+	ContainerTarget
+*/
+package agentrpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Request struct {
+	Id uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	// Types that are valid to be assigned to Request:
+	//	*Request_Hello
+	//	*Request_Register
+	//	*Request_Poll
+	//	*Request_Result
+	//	*Request_Progress
+	Request isRequest_Request `protobuf_oneof:"request"`
+}
+
+func (m *Request) Reset()                    { *m = Request{} }
+func (m *Request) String() string            { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()               {}
+func (*Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type isRequest_Request interface{ isRequest_Request() }
+
+type Request_Hello struct {
+	Hello *Hello `protobuf:"bytes,2,opt,name=hello,oneof"`
+}
+type Request_Register struct {
+	Register *Register `protobuf:"bytes,3,opt,name=register,oneof"`
+}
+type Request_Poll struct {
+	Poll *Poll `protobuf:"bytes,4,opt,name=poll,oneof"`
+}
+type Request_Result struct {
+	Result *Result `protobuf:"bytes,5,opt,name=result,oneof"`
+}
+type Request_Progress struct {
+	Progress *Progress `protobuf:"bytes,6,opt,name=progress,oneof"`
+}
+
+func (*Request_Hello) isRequest_Request()    {}
+func (*Request_Register) isRequest_Request() {}
+func (*Request_Poll) isRequest_Request()     {}
+func (*Request_Result) isRequest_Request()   {}
+func (*Request_Progress) isRequest_Request() {}
+
+func (m *Request) GetRequest() isRequest_Request {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *Request) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Request) GetHello() *Hello {
+	if x, ok := m.GetRequest().(*Request_Hello); ok {
+		return x.Hello
+	}
+	return nil
+}
+
+func (m *Request) GetRegister() *Register {
+	if x, ok := m.GetRequest().(*Request_Register); ok {
+		return x.Register
+	}
+	return nil
+}
+
+func (m *Request) GetPoll() *Poll {
+	if x, ok := m.GetRequest().(*Request_Poll); ok {
+		return x.Poll
+	}
+	return nil
+}
+
+func (m *Request) GetResult() *Result {
+	if x, ok := m.GetRequest().(*Request_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+func (m *Request) GetProgress() *Progress {
+	if x, ok := m.GetRequest().(*Request_Progress); ok {
+		return x.Progress
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*Request) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _Request_OneofMarshaler, _Request_OneofUnmarshaler, _Request_OneofSizer, []interface{}{
+		(*Request_Hello)(nil),
+		(*Request_Register)(nil),
+		(*Request_Poll)(nil),
+		(*Request_Result)(nil),
+		(*Request_Progress)(nil),
+	}
+}
+
+func _Request_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*Request)
+	// request
+	switch x := m.Request.(type) {
+	case *Request_Hello:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Hello); err != nil {
+			return err
+		}
+	case *Request_Register:
+		b.EncodeVarint(3<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Register); err != nil {
+			return err
+		}
+	case *Request_Poll:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Poll); err != nil {
+			return err
+		}
+	case *Request_Result:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Result); err != nil {
+			return err
+		}
+	case *Request_Progress:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Progress); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("Request.Request has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _Request_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*Request)
+	switch tag {
+	case 2: // request.hello
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Hello)
+		err := b.DecodeMessage(msg)
+		m.Request = &Request_Hello{msg}
+		return true, err
+	case 3: // request.register
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Register)
+		err := b.DecodeMessage(msg)
+		m.Request = &Request_Register{msg}
+		return true, err
+	case 4: // request.poll
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Poll)
+		err := b.DecodeMessage(msg)
+		m.Request = &Request_Poll{msg}
+		return true, err
+	case 5: // request.result
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Result)
+		err := b.DecodeMessage(msg)
+		m.Request = &Request_Result{msg}
+		return true, err
+	case 6: // request.progress
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Progress)
+		err := b.DecodeMessage(msg)
+		m.Request = &Request_Progress{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _Request_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*Request)
+	// request
+	switch x := m.Request.(type) {
+	case *Request_Hello:
+		s := proto.Size(x.Hello)
+		n += proto.SizeVarint(2<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Request_Register:
+		s := proto.Size(x.Register)
+		n += proto.SizeVarint(3<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Request_Poll:
+		s := proto.Size(x.Poll)
+		n += proto.SizeVarint(4<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Request_Result:
+		s := proto.Size(x.Result)
+		n += proto.SizeVarint(5<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Request_Progress:
+		s := proto.Size(x.Progress)
+		n += proto.SizeVarint(6<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+type Reply struct {
+	Id uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	// Request could not be processed; stream stays open
+	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// Only set in reply to Poll
+	PollUpdate *PollUpdate `protobuf:"bytes,3,opt,name=poll_update,json=pollUpdate" json:"poll_update,omitempty"`
+}
+
+func (m *Reply) Reset()                    { *m = Reply{} }
+func (m *Reply) String() string            { return proto.CompactTextString(m) }
+func (*Reply) ProtoMessage()               {}
+func (*Reply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Reply) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Reply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Reply) GetPollUpdate() *PollUpdate {
+	if m != nil {
+		return m.PollUpdate
+	}
+	return nil
+}
+
+// Hello must be the first request on the stream and names agent
+// that all later requests are made on behalf of
+type Hello struct {
+	AgentId string `protobuf:"bytes,1,opt,name=agent_id,json=agentId" json:"agent_id,omitempty"`
+}
+
+func (m *Hello) Reset()                    { *m = Hello{} }
+func (m *Hello) String() string            { return proto.CompactTextString(m) }
+func (*Hello) ProtoMessage()               {}
+func (*Hello) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *Hello) GetAgentId() string {
+	if m != nil {
+		return m.AgentId
+	}
+	return ""
+}
+
+// Register reports agent's capabilities; agents re-register periodically
+type Register struct {
+	Capabilities *Capabilities `protobuf:"bytes,1,opt,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *Register) Reset()                    { *m = Register{} }
+func (m *Register) String() string            { return proto.CompactTextString(m) }
+func (*Register) ProtoMessage()               {}
+func (*Register) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *Register) GetCapabilities() *Capabilities {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type Capabilities struct {
+	Version             string   `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	TaskTypes           []string `protobuf:"bytes,2,rep,name=task_types,json=taskTypes" json:"task_types,omitempty"`
+	Tools               []string `protobuf:"bytes,3,rep,name=tools" json:"tools,omitempty"`
+	DisallowedTaskTypes []string `protobuf:"bytes,5,rep,name=disallowed_task_types,json=disallowedTaskTypes" json:"disallowed_task_types,omitempty"`
+	DryRun              bool     `protobuf:"varint,4,opt,name=dry_run,json=dryRun" json:"dry_run,omitempty"`
+	// Set when agent does not run on a BOSH VM
+	Host *Host `protobuf:"bytes,6,opt,name=host" json:"host,omitempty"`
+}
+
+func (m *Capabilities) Reset()                    { *m = Capabilities{} }
+func (m *Capabilities) String() string            { return proto.CompactTextString(m) }
+func (*Capabilities) ProtoMessage()               {}
+func (*Capabilities) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *Capabilities) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *Capabilities) GetTaskTypes() []string {
+	if m != nil {
+		return m.TaskTypes
+	}
+	return nil
+}
+
+func (m *Capabilities) GetTools() []string {
+	if m != nil {
+		return m.Tools
+	}
+	return nil
+}
+
+func (m *Capabilities) GetDisallowedTaskTypes() []string {
+	if m != nil {
+		return m.DisallowedTaskTypes
+	}
+	return nil
+}
+
+func (m *Capabilities) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *Capabilities) GetHost() *Host {
+	if m != nil {
+		return m.Host
+	}
+	return nil
+}
+
+type Host struct {
+	Deployment string `protobuf:"bytes,1,opt,name=deployment" json:"deployment,omitempty"`
+	Group      string `protobuf:"bytes,2,opt,name=group" json:"group,omitempty"`
+	Az         string `protobuf:"bytes,3,opt,name=az" json:"az,omitempty"`
+}
+
+func (m *Host) Reset()                    { *m = Host{} }
+func (m *Host) String() string            { return proto.CompactTextString(m) }
+func (*Host) ProtoMessage()               {}
+func (*Host) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *Host) GetDeployment() string {
+	if m != nil {
+		return m.Deployment
+	}
+	return ""
+}
+
+func (m *Host) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *Host) GetAz() string {
+	if m != nil {
+		return m.Az
+	}
+	return ""
+}
+
+// Poll waits until there are new tasks or stop and adjustment requests for agent's tasks
+type Poll struct {
+	StoppedTaskIds     []string         `protobuf:"bytes,1,rep,name=stopped_task_ids,json=stoppedTaskIds" json:"stopped_task_ids,omitempty"`
+	AdjustmentVersions map[string]int64 `protobuf:"bytes,2,rep,name=adjustment_versions,json=adjustmentVersions" json:"adjustment_versions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	RunningTaskIds     []string         `protobuf:"bytes,3,rep,name=running_task_ids,json=runningTaskIds" json:"running_task_ids,omitempty"`
+}
+
+func (m *Poll) Reset()                    { *m = Poll{} }
+func (m *Poll) String() string            { return proto.CompactTextString(m) }
+func (*Poll) ProtoMessage()               {}
+func (*Poll) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *Poll) GetStoppedTaskIds() []string {
+	if m != nil {
+		return m.StoppedTaskIds
+	}
+	return nil
+}
+
+func (m *Poll) GetAdjustmentVersions() map[string]int64 {
+	if m != nil {
+		return m.AdjustmentVersions
+	}
+	return nil
+}
+
+func (m *Poll) GetRunningTaskIds() []string {
+	if m != nil {
+		return m.RunningTaskIds
+	}
+	return nil
+}
+
+type PollUpdate struct {
+	Tasks          []*Task       `protobuf:"bytes,1,rep,name=tasks" json:"tasks,omitempty"`
+	StoppedTaskIds []string      `protobuf:"bytes,2,rep,name=stopped_task_ids,json=stoppedTaskIds" json:"stopped_task_ids,omitempty"`
+	Adjustments    []*Adjustment `protobuf:"bytes,3,rep,name=adjustments" json:"adjustments,omitempty"`
+}
+
+func (m *PollUpdate) Reset()                    { *m = PollUpdate{} }
+func (m *PollUpdate) String() string            { return proto.CompactTextString(m) }
+func (*PollUpdate) ProtoMessage()               {}
+func (*PollUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *PollUpdate) GetTasks() []*Task {
+	if m != nil {
+		return m.Tasks
+	}
+	return nil
+}
+
+func (m *PollUpdate) GetStoppedTaskIds() []string {
+	if m != nil {
+		return m.StoppedTaskIds
+	}
+	return nil
+}
+
+func (m *PollUpdate) GetAdjustments() []*Adjustment {
+	if m != nil {
+		return m.Adjustments
+	}
+	return nil
+}
+
+type Adjustment struct {
+	TaskId  string   `protobuf:"bytes,1,opt,name=task_id,json=taskId" json:"task_id,omitempty"`
+	Version int64    `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	Options *Options `protobuf:"bytes,3,opt,name=options" json:"options,omitempty"`
+}
+
+func (m *Adjustment) Reset()                    { *m = Adjustment{} }
+func (m *Adjustment) String() string            { return proto.CompactTextString(m) }
+func (*Adjustment) ProtoMessage()               {}
+func (*Adjustment) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Adjustment) GetTaskId() string {
+	if m != nil {
+		return m.TaskId
+	}
+	return ""
+}
+
+func (m *Adjustment) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Adjustment) GetOptions() *Options {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+type Progress struct {
+	TaskId string `protobuf:"bytes,1,opt,name=task_id,json=taskId" json:"task_id,omitempty"`
+	// Empty when only sending a heartbeat
+	State string `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	// Output captured since previous progress request
+	Output string `protobuf:"bytes,3,opt,name=output" json:"output,omitempty"`
+}
+
+func (m *Progress) Reset()                    { *m = Progress{} }
+func (m *Progress) String() string            { return proto.CompactTextString(m) }
+func (*Progress) ProtoMessage()               {}
+func (*Progress) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Progress) GetTaskId() string {
+	if m != nil {
+		return m.TaskId
+	}
+	return ""
+}
+
+func (m *Progress) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Progress) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+type Result struct {
+	TaskId string `protobuf:"bytes,1,opt,name=task_id,json=taskId" json:"task_id,omitempty"`
+	// Not set if task succeeded
+	Error *TaskError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *Result) Reset()                    { *m = Result{} }
+func (m *Result) String() string            { return proto.CompactTextString(m) }
+func (*Result) ProtoMessage()               {}
+func (*Result) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *Result) GetTaskId() string {
+	if m != nil {
+		return m.TaskId
+	}
+	return ""
+}
+
+func (m *Result) GetError() *TaskError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type TaskError struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+	// Errors reported by older agents are not categorized
+	Category string `protobuf:"bytes,2,opt,name=category" json:"category,omitempty"`
+	// Last command that failed while task was executing (if any)
+	Cmd        string `protobuf:"bytes,3,opt,name=cmd" json:"cmd,omitempty"`
+	ExitStatus int64  `protobuf:"varint,4,opt,name=exit_status,json=exitStatus" json:"exit_status,omitempty"`
+	Output     string `protobuf:"bytes,5,opt,name=output" json:"output,omitempty"`
+}
+
+func (m *TaskError) Reset()                    { *m = TaskError{} }
+func (m *TaskError) String() string            { return proto.CompactTextString(m) }
+func (*TaskError) ProtoMessage()               {}
+func (*TaskError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *TaskError) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *TaskError) GetCategory() string {
+	if m != nil {
+		return m.Category
+	}
+	return ""
+}
+
+func (m *TaskError) GetCmd() string {
+	if m != nil {
+		return m.Cmd
+	}
+	return ""
+}
+
+func (m *TaskError) GetExitStatus() int64 {
+	if m != nil {
+		return m.ExitStatus
+	}
+	return 0
+}
+
+func (m *TaskError) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+type Task struct {
+	Id      string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Options *Options `protobuf:"bytes,2,opt,name=options" json:"options,omitempty"`
+	// Task must not change the system; commands are only reported
+	DryRun bool `protobuf:"varint,3,opt,name=dry_run,json=dryRun" json:"dry_run,omitempty"`
+}
+
+func (m *Task) Reset()                    { *m = Task{} }
+func (m *Task) String() string            { return proto.CompactTextString(m) }
+func (*Task) ProtoMessage()               {}
+func (*Task) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *Task) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Task) GetOptions() *Options {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *Task) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+type Options struct {
+	// Types that are valid to be assigned to Options:
+	//	*Options_Noop
+	//	*Options_Kill
+	//	*Options_KillProcess
+	//	*Options_Stress
+	//	*Options_ControlNet
+	//	*Options_Firewall
+	//	*Options_FillDisk
+	//	*Options_Shutdown
+	Options isOptions_Options `protobuf_oneof:"options"`
+}
+
+func (m *Options) Reset()                    { *m = Options{} }
+func (m *Options) String() string            { return proto.CompactTextString(m) }
+func (*Options) ProtoMessage()               {}
+func (*Options) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+type isOptions_Options interface{ isOptions_Options() }
+
+type Options_Noop struct {
+	Noop *NoopOptions `protobuf:"bytes,1,opt,name=noop,oneof"`
+}
+type Options_Kill struct {
+	Kill *KillOptions `protobuf:"bytes,2,opt,name=kill,oneof"`
+}
+type Options_KillProcess struct {
+	KillProcess *KillProcessOptions `protobuf:"bytes,3,opt,name=kill_process,json=killProcess,oneof"`
+}
+type Options_Stress struct {
+	Stress *StressOptions `protobuf:"bytes,4,opt,name=stress,oneof"`
+}
+type Options_ControlNet struct {
+	ControlNet *ControlNetOptions `protobuf:"bytes,5,opt,name=control_net,json=controlNet,oneof"`
+}
+type Options_Firewall struct {
+	Firewall *FirewallOptions `protobuf:"bytes,6,opt,name=firewall,oneof"`
+}
+type Options_FillDisk struct {
+	FillDisk *FillDiskOptions `protobuf:"bytes,7,opt,name=fill_disk,json=fillDisk,oneof"`
+}
+type Options_Shutdown struct {
+	Shutdown *ShutdownOptions `protobuf:"bytes,8,opt,name=shutdown,oneof"`
+}
+
+func (*Options_Noop) isOptions_Options()        {}
+func (*Options_Kill) isOptions_Options()        {}
+func (*Options_KillProcess) isOptions_Options() {}
+func (*Options_Stress) isOptions_Options()      {}
+func (*Options_ControlNet) isOptions_Options()  {}
+func (*Options_Firewall) isOptions_Options()    {}
+func (*Options_FillDisk) isOptions_Options()    {}
+func (*Options_Shutdown) isOptions_Options()    {}
+
+func (m *Options) GetOptions() isOptions_Options {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *Options) GetNoop() *NoopOptions {
+	if x, ok := m.GetOptions().(*Options_Noop); ok {
+		return x.Noop
+	}
+	return nil
+}
+
+func (m *Options) GetKill() *KillOptions {
+	if x, ok := m.GetOptions().(*Options_Kill); ok {
+		return x.Kill
+	}
+	return nil
+}
+
+func (m *Options) GetKillProcess() *KillProcessOptions {
+	if x, ok := m.GetOptions().(*Options_KillProcess); ok {
+		return x.KillProcess
+	}
+	return nil
+}
+
+func (m *Options) GetStress() *StressOptions {
+	if x, ok := m.GetOptions().(*Options_Stress); ok {
+		return x.Stress
+	}
+	return nil
+}
+
+func (m *Options) GetControlNet() *ControlNetOptions {
+	if x, ok := m.GetOptions().(*Options_ControlNet); ok {
+		return x.ControlNet
+	}
+	return nil
+}
+
+func (m *Options) GetFirewall() *FirewallOptions {
+	if x, ok := m.GetOptions().(*Options_Firewall); ok {
+		return x.Firewall
+	}
+	return nil
+}
+
+func (m *Options) GetFillDisk() *FillDiskOptions {
+	if x, ok := m.GetOptions().(*Options_FillDisk); ok {
+		return x.FillDisk
+	}
+	return nil
+}
+
+func (m *Options) GetShutdown() *ShutdownOptions {
+	if x, ok := m.GetOptions().(*Options_Shutdown); ok {
+		return x.Shutdown
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*Options) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _Options_OneofMarshaler, _Options_OneofUnmarshaler, _Options_OneofSizer, []interface{}{
+		(*Options_Noop)(nil),
+		(*Options_Kill)(nil),
+		(*Options_KillProcess)(nil),
+		(*Options_Stress)(nil),
+		(*Options_ControlNet)(nil),
+		(*Options_Firewall)(nil),
+		(*Options_FillDisk)(nil),
+		(*Options_Shutdown)(nil),
+	}
+}
+
+func _Options_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*Options)
+	// options
+	switch x := m.Options.(type) {
+	case *Options_Noop:
+		b.EncodeVarint(1<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Noop); err != nil {
+			return err
+		}
+	case *Options_Kill:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Kill); err != nil {
+			return err
+		}
+	case *Options_KillProcess:
+		b.EncodeVarint(3<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.KillProcess); err != nil {
+			return err
+		}
+	case *Options_Stress:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Stress); err != nil {
+			return err
+		}
+	case *Options_ControlNet:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ControlNet); err != nil {
+			return err
+		}
+	case *Options_Firewall:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Firewall); err != nil {
+			return err
+		}
+	case *Options_FillDisk:
+		b.EncodeVarint(7<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.FillDisk); err != nil {
+			return err
+		}
+	case *Options_Shutdown:
+		b.EncodeVarint(8<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Shutdown); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("Options.Options has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _Options_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*Options)
+	switch tag {
+	case 1: // options.noop
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(NoopOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_Noop{msg}
+		return true, err
+	case 2: // options.kill
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(KillOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_Kill{msg}
+		return true, err
+	case 3: // options.kill_process
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(KillProcessOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_KillProcess{msg}
+		return true, err
+	case 4: // options.stress
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(StressOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_Stress{msg}
+		return true, err
+	case 5: // options.control_net
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ControlNetOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_ControlNet{msg}
+		return true, err
+	case 6: // options.firewall
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(FirewallOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_Firewall{msg}
+		return true, err
+	case 7: // options.fill_disk
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(FillDiskOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_FillDisk{msg}
+		return true, err
+	case 8: // options.shutdown
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ShutdownOptions)
+		err := b.DecodeMessage(msg)
+		m.Options = &Options_Shutdown{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _Options_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*Options)
+	// options
+	switch x := m.Options.(type) {
+	case *Options_Noop:
+		s := proto.Size(x.Noop)
+		n += proto.SizeVarint(1<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_Kill:
+		s := proto.Size(x.Kill)
+		n += proto.SizeVarint(2<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_KillProcess:
+		s := proto.Size(x.KillProcess)
+		n += proto.SizeVarint(3<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_Stress:
+		s := proto.Size(x.Stress)
+		n += proto.SizeVarint(4<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_ControlNet:
+		s := proto.Size(x.ControlNet)
+		n += proto.SizeVarint(5<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_Firewall:
+		s := proto.Size(x.Firewall)
+		n += proto.SizeVarint(6<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_FillDisk:
+		s := proto.Size(x.FillDisk)
+		n += proto.SizeVarint(7<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Options_Shutdown:
+		s := proto.Size(x.Shutdown)
+		n += proto.SizeVarint(8<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+type NoopOptions struct {
+	Stoppable bool `protobuf:"varint,1,opt,name=stoppable" json:"stoppable,omitempty"`
+}
+
+func (m *NoopOptions) Reset()                    { *m = NoopOptions{} }
+func (m *NoopOptions) String() string            { return proto.CompactTextString(m) }
+func (*NoopOptions) ProtoMessage()               {}
+func (*NoopOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *NoopOptions) GetStoppable() bool {
+	if m != nil {
+		return m.Stoppable
+	}
+	return false
+}
+
+type KillOptions struct {
+}
+
+func (m *KillOptions) Reset()                    { *m = KillOptions{} }
+func (m *KillOptions) String() string            { return proto.CompactTextString(m) }
+func (*KillOptions) ProtoMessage()               {}
+func (*KillOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+type KillProcessOptions struct {
+	ProcessName          string           `protobuf:"bytes,1,opt,name=process_name,json=processName" json:"process_name,omitempty"`
+	MonitoredProcessName string           `protobuf:"bytes,2,opt,name=monitored_process_name,json=monitoredProcessName" json:"monitored_process_name,omitempty"`
+	Target               *ContainerTarget `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+}
+
+func (m *KillProcessOptions) Reset()                    { *m = KillProcessOptions{} }
+func (m *KillProcessOptions) String() string            { return proto.CompactTextString(m) }
+func (*KillProcessOptions) ProtoMessage()               {}
+func (*KillProcessOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *KillProcessOptions) GetProcessName() string {
+	if m != nil {
+		return m.ProcessName
+	}
+	return ""
+}
+
+func (m *KillProcessOptions) GetMonitoredProcessName() string {
+	if m != nil {
+		return m.MonitoredProcessName
+	}
+	return ""
+}
+
+func (m *KillProcessOptions) GetTarget() *ContainerTarget {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+type StressOptions struct {
+	Timeout           string       `protobuf:"bytes,1,opt,name=timeout" json:"timeout,omitempty"`
+	NumCpuWorkers     int64        `protobuf:"varint,2,opt,name=num_cpu_workers,json=numCpuWorkers" json:"num_cpu_workers,omitempty"`
+	NumIoWorkers      int64        `protobuf:"varint,3,opt,name=num_io_workers,json=numIoWorkers" json:"num_io_workers,omitempty"`
+	NumMemoryWorkers  int64        `protobuf:"varint,4,opt,name=num_memory_workers,json=numMemoryWorkers" json:"num_memory_workers,omitempty"`
+	MemoryWorkerBytes string       `protobuf:"bytes,5,opt,name=memory_worker_bytes,json=memoryWorkerBytes" json:"memory_worker_bytes,omitempty"`
+	NumHddWorkers     int64        `protobuf:"varint,6,opt,name=num_hdd_workers,json=numHddWorkers" json:"num_hdd_workers,omitempty"`
+	HddWorkerBytes    string       `protobuf:"bytes,7,opt,name=hdd_worker_bytes,json=hddWorkerBytes" json:"hdd_worker_bytes,omitempty"`
+	Ramp              *RampOptions `protobuf:"bytes,8,opt,name=ramp" json:"ramp,omitempty"`
+}
+
+func (m *StressOptions) Reset()                    { *m = StressOptions{} }
+func (m *StressOptions) String() string            { return proto.CompactTextString(m) }
+func (*StressOptions) ProtoMessage()               {}
+func (*StressOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *StressOptions) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func (m *StressOptions) GetNumCpuWorkers() int64 {
+	if m != nil {
+		return m.NumCpuWorkers
+	}
+	return 0
+}
+
+func (m *StressOptions) GetNumIoWorkers() int64 {
+	if m != nil {
+		return m.NumIoWorkers
+	}
+	return 0
+}
+
+func (m *StressOptions) GetNumMemoryWorkers() int64 {
+	if m != nil {
+		return m.NumMemoryWorkers
+	}
+	return 0
+}
+
+func (m *StressOptions) GetMemoryWorkerBytes() string {
+	if m != nil {
+		return m.MemoryWorkerBytes
+	}
+	return ""
+}
+
+func (m *StressOptions) GetNumHddWorkers() int64 {
+	if m != nil {
+		return m.NumHddWorkers
+	}
+	return 0
+}
+
+func (m *StressOptions) GetHddWorkerBytes() string {
+	if m != nil {
+		return m.HddWorkerBytes
+	}
+	return ""
+}
+
+func (m *StressOptions) GetRamp() *RampOptions {
+	if m != nil {
+		return m.Ramp
+	}
+	return nil
+}
+
+type ControlNetOptions struct {
+	Timeout         string           `protobuf:"bytes,1,opt,name=timeout" json:"timeout,omitempty"`
+	Delay           string           `protobuf:"bytes,2,opt,name=delay" json:"delay,omitempty"`
+	DelayVariation  string           `protobuf:"bytes,3,opt,name=delay_variation,json=delayVariation" json:"delay_variation,omitempty"`
+	Loss            string           `protobuf:"bytes,4,opt,name=loss" json:"loss,omitempty"`
+	LossCorrelation string           `protobuf:"bytes,5,opt,name=loss_correlation,json=lossCorrelation" json:"loss_correlation,omitempty"`
+	Ramp            *RampOptions     `protobuf:"bytes,6,opt,name=ramp" json:"ramp,omitempty"`
+	Target          *ContainerTarget `protobuf:"bytes,7,opt,name=target" json:"target,omitempty"`
+}
+
+func (m *ControlNetOptions) Reset()                    { *m = ControlNetOptions{} }
+func (m *ControlNetOptions) String() string            { return proto.CompactTextString(m) }
+func (*ControlNetOptions) ProtoMessage()               {}
+func (*ControlNetOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *ControlNetOptions) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func (m *ControlNetOptions) GetDelay() string {
+	if m != nil {
+		return m.Delay
+	}
+	return ""
+}
+
+func (m *ControlNetOptions) GetDelayVariation() string {
+	if m != nil {
+		return m.DelayVariation
+	}
+	return ""
+}
+
+func (m *ControlNetOptions) GetLoss() string {
+	if m != nil {
+		return m.Loss
+	}
+	return ""
+}
+
+func (m *ControlNetOptions) GetLossCorrelation() string {
+	if m != nil {
+		return m.LossCorrelation
+	}
+	return ""
+}
+
+func (m *ControlNetOptions) GetRamp() *RampOptions {
+	if m != nil {
+		return m.Ramp
+	}
+	return nil
+}
+
+func (m *ControlNetOptions) GetTarget() *ContainerTarget {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+type FirewallOptions struct {
+	Timeout        string           `protobuf:"bytes,1,opt,name=timeout" json:"timeout,omitempty"`
+	BlockBoshAgent bool             `protobuf:"varint,2,opt,name=block_bosh_agent,json=blockBoshAgent" json:"block_bosh_agent,omitempty"`
+	Target         *ContainerTarget `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+}
+
+func (m *FirewallOptions) Reset()                    { *m = FirewallOptions{} }
+func (m *FirewallOptions) String() string            { return proto.CompactTextString(m) }
+func (*FirewallOptions) ProtoMessage()               {}
+func (*FirewallOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *FirewallOptions) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func (m *FirewallOptions) GetBlockBoshAgent() bool {
+	if m != nil {
+		return m.BlockBoshAgent
+	}
+	return false
+}
+
+func (m *FirewallOptions) GetTarget() *ContainerTarget {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+type FillDiskOptions struct {
+	Persistent bool `protobuf:"varint,1,opt,name=persistent" json:"persistent,omitempty"`
+	Ephemeral  bool `protobuf:"varint,2,opt,name=ephemeral" json:"ephemeral,omitempty"`
+	Temporary  bool `protobuf:"varint,3,opt,name=temporary" json:"temporary,omitempty"`
+}
+
+func (m *FillDiskOptions) Reset()                    { *m = FillDiskOptions{} }
+func (m *FillDiskOptions) String() string            { return proto.CompactTextString(m) }
+func (*FillDiskOptions) ProtoMessage()               {}
+func (*FillDiskOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *FillDiskOptions) GetPersistent() bool {
+	if m != nil {
+		return m.Persistent
+	}
+	return false
+}
+
+func (m *FillDiskOptions) GetEphemeral() bool {
+	if m != nil {
+		return m.Ephemeral
+	}
+	return false
+}
+
+func (m *FillDiskOptions) GetTemporary() bool {
+	if m != nil {
+		return m.Temporary
+	}
+	return false
+}
+
+type ShutdownOptions struct {
+	Reboot bool   `protobuf:"varint,1,opt,name=reboot" json:"reboot,omitempty"`
+	Force  bool   `protobuf:"varint,2,opt,name=force" json:"force,omitempty"`
+	Crash  bool   `protobuf:"varint,3,opt,name=crash" json:"crash,omitempty"`
+	Sysrq  string `protobuf:"bytes,4,opt,name=sysrq" json:"sysrq,omitempty"`
+}
+
+func (m *ShutdownOptions) Reset()                    { *m = ShutdownOptions{} }
+func (m *ShutdownOptions) String() string            { return proto.CompactTextString(m) }
+func (*ShutdownOptions) ProtoMessage()               {}
+func (*ShutdownOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *ShutdownOptions) GetReboot() bool {
+	if m != nil {
+		return m.Reboot
+	}
+	return false
+}
+
+func (m *ShutdownOptions) GetForce() bool {
+	if m != nil {
+		return m.Force
+	}
+	return false
+}
+
+func (m *ShutdownOptions) GetCrash() bool {
+	if m != nil {
+		return m.Crash
+	}
+	return false
+}
+
+func (m *ShutdownOptions) GetSysrq() string {
+	if m != nil {
+		return m.Sysrq
+	}
+	return ""
+}
+
+type RampOptions struct {
+	Duration     string `protobuf:"bytes,1,opt,name=duration" json:"duration,omitempty"`
+	Steps        int64  `protobuf:"varint,2,opt,name=steps" json:"steps,omitempty"`
+	StartPercent int64  `protobuf:"varint,3,opt,name=start_percent,json=startPercent" json:"start_percent,omitempty"`
+	Down         bool   `protobuf:"varint,4,opt,name=down" json:"down,omitempty"`
+}
+
+func (m *RampOptions) Reset()                    { *m = RampOptions{} }
+func (m *RampOptions) String() string            { return proto.CompactTextString(m) }
+func (*RampOptions) ProtoMessage()               {}
+func (*RampOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *RampOptions) GetDuration() string {
+	if m != nil {
+		return m.Duration
+	}
+	return ""
+}
+
+func (m *RampOptions) GetSteps() int64 {
+	if m != nil {
+		return m.Steps
+	}
+	return 0
+}
+
+func (m *RampOptions) GetStartPercent() int64 {
+	if m != nil {
+		return m.StartPercent
+	}
+	return 0
+}
+
+func (m *RampOptions) GetDown() bool {
+	if m != nil {
+		return m.Down
+	}
+	return false
+}
+
+// ContainerTarget sets exactly one way of finding container
+type ContainerTarget struct {
+	Pid    int64  `protobuf:"varint,1,opt,name=pid" json:"pid,omitempty"`
+	Cgroup string `protobuf:"bytes,2,opt,name=cgroup" json:"cgroup,omitempty"`
+	Label  string `protobuf:"bytes,3,opt,name=label" json:"label,omitempty"`
+}
+
+func (m *ContainerTarget) Reset()                    { *m = ContainerTarget{} }
+func (m *ContainerTarget) String() string            { return proto.CompactTextString(m) }
+func (*ContainerTarget) ProtoMessage()               {}
+func (*ContainerTarget) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ContainerTarget) GetPid() int64 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+func (m *ContainerTarget) GetCgroup() string {
+	if m != nil {
+		return m.Cgroup
+	}
+	return ""
+}
+
+func (m *ContainerTarget) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Request)(nil), "agentrpc.Request")
+	proto.RegisterType((*Reply)(nil), "agentrpc.Reply")
+	proto.RegisterType((*Hello)(nil), "agentrpc.Hello")
+	proto.RegisterType((*Register)(nil), "agentrpc.Register")
+	proto.RegisterType((*Capabilities)(nil), "agentrpc.Capabilities")
+	proto.RegisterType((*Host)(nil), "agentrpc.Host")
+	proto.RegisterType((*Poll)(nil), "agentrpc.Poll")
+	proto.RegisterType((*PollUpdate)(nil), "agentrpc.PollUpdate")
+	proto.RegisterType((*Adjustment)(nil), "agentrpc.Adjustment")
+	proto.RegisterType((*Progress)(nil), "agentrpc.Progress")
+	proto.RegisterType((*Result)(nil), "agentrpc.Result")
+	proto.RegisterType((*TaskError)(nil), "agentrpc.TaskError")
+	proto.RegisterType((*Task)(nil), "agentrpc.Task")
+	proto.RegisterType((*Options)(nil), "agentrpc.Options")
+	proto.RegisterType((*NoopOptions)(nil), "agentrpc.NoopOptions")
+	proto.RegisterType((*KillOptions)(nil), "agentrpc.KillOptions")
+	proto.RegisterType((*KillProcessOptions)(nil), "agentrpc.KillProcessOptions")
+	proto.RegisterType((*StressOptions)(nil), "agentrpc.StressOptions")
+	proto.RegisterType((*ControlNetOptions)(nil), "agentrpc.ControlNetOptions")
+	proto.RegisterType((*FirewallOptions)(nil), "agentrpc.FirewallOptions")
+	proto.RegisterType((*FillDiskOptions)(nil), "agentrpc.FillDiskOptions")
+	proto.RegisterType((*ShutdownOptions)(nil), "agentrpc.ShutdownOptions")
+	proto.RegisterType((*RampOptions)(nil), "agentrpc.RampOptions")
+	proto.RegisterType((*ContainerTarget)(nil), "agentrpc.ContainerTarget")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for AgentChannel service
+
+type AgentChannelClient interface {
+	// Agent sends Hello first; API replies to each request with a Reply
+	// carrying the same request ID (replies may come out of order)
+	Connect(ctx context.Context, opts ...grpc.CallOption) (AgentChannel_ConnectClient, error)
+}
+
+type agentChannelClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAgentChannelClient(cc *grpc.ClientConn) AgentChannelClient {
+	return &agentChannelClient{cc}
+}
+
+func (c *agentChannelClient) Connect(ctx context.Context, opts ...grpc.CallOption) (AgentChannel_ConnectClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_AgentChannel_serviceDesc.Streams[0], c.cc, "/agentrpc.AgentChannel/Connect", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentChannelConnectClient{stream}
+	return x, nil
+}
+
+type AgentChannel_ConnectClient interface {
+	Send(*Request) error
+	Recv() (*Reply, error)
+	grpc.ClientStream
+}
+
+type agentChannelConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentChannelConnectClient) Send(m *Request) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *agentChannelConnectClient) Recv() (*Reply, error) {
+	m := new(Reply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for AgentChannel service
+
+type AgentChannelServer interface {
+	// Agent sends Hello first; API replies to each request with a Reply
+	// carrying the same request ID (replies may come out of order)
+	Connect(AgentChannel_ConnectServer) error
+}
+
+func RegisterAgentChannelServer(s *grpc.Server, srv AgentChannelServer) {
+	s.RegisterService(&_AgentChannel_serviceDesc, srv)
+}
+
+func _AgentChannel_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentChannelServer).Connect(&agentChannelConnectServer{stream})
+}
+
+type AgentChannel_ConnectServer interface {
+	Send(*Reply) error
+	Recv() (*Request, error)
+	grpc.ServerStream
+}
+
+type agentChannelConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentChannelConnectServer) Send(m *Reply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *agentChannelConnectServer) Recv() (*Request, error) {
+	m := new(Request)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _AgentChannel_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "agentrpc.AgentChannel",
+	HandlerType: (*AgentChannelServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _AgentChannel_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "agent_channel.proto",
+}
+
+func init() { proto.RegisterFile("agent_channel.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 1502 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0x5b, 0x6f, 0x14, 0xc9,
+	0x15, 0xf6, 0xdc, 0x67, 0xce, 0xd8, 0x33, 0x76, 0xd9, 0xc0, 0x40, 0x48, 0x42, 0x3a, 0x08, 0x4c,
+	0x88, 0x2c, 0x70, 0x6e, 0x88, 0x87, 0x48, 0xb6, 0x03, 0x32, 0x0a, 0x21, 0xa6, 0x20, 0x20, 0x45,
+	0x91, 0x5a, 0x3d, 0xdd, 0xc5, 0x4c, 0x67, 0xaa, 0xbb, 0x9a, 0xaa, 0x6a, 0xcc, 0xf0, 0x9a, 0x97,
+	0x44, 0xca, 0xe3, 0xfe, 0x80, 0xfd, 0x45, 0xab, 0xd5, 0xfe, 0x9c, 0x7d, 0x5a, 0x9d, 0xaa, 0xea,
+	0xcb, 0x8c, 0xb9, 0xac, 0xf6, 0x69, 0xea, 0x7c, 0xe7, 0xab, 0xaa, 0x73, 0xaf, 0x69, 0xd8, 0x0d,
+	0x66, 0x2c, 0xd5, 0x7e, 0x38, 0x0f, 0xd2, 0x94, 0xf1, 0x83, 0x4c, 0x0a, 0x2d, 0x48, 0xdf, 0x80,
+	0x32, 0x0b, 0xbd, 0xff, 0x34, 0xa1, 0x47, 0xd9, 0xdb, 0x9c, 0x29, 0x4d, 0x46, 0xd0, 0x8c, 0xa3,
+	0x49, 0xe3, 0x46, 0x63, 0xbf, 0x4d, 0x9b, 0x71, 0x44, 0x6e, 0x43, 0x67, 0xce, 0x38, 0x17, 0x93,
+	0xe6, 0x8d, 0xc6, 0xfe, 0xf0, 0x70, 0x7c, 0x50, 0xec, 0x3a, 0x38, 0x45, 0xf8, 0x74, 0x83, 0x5a,
+	0x3d, 0xb9, 0x07, 0x7d, 0xc9, 0x66, 0xb1, 0xd2, 0x4c, 0x4e, 0x5a, 0x86, 0x4b, 0x2a, 0x2e, 0x75,
+	0x9a, 0xd3, 0x0d, 0x5a, 0xb2, 0xc8, 0x4d, 0x68, 0x67, 0x82, 0xf3, 0x49, 0xdb, 0xb0, 0x47, 0x15,
+	0xfb, 0x4c, 0x70, 0x7e, 0xba, 0x41, 0x8d, 0x96, 0xfc, 0x06, 0xba, 0x92, 0xa9, 0x9c, 0xeb, 0x49,
+	0xc7, 0xf0, 0xb6, 0xeb, 0xa7, 0x22, 0x7e, 0xba, 0x41, 0x1d, 0x03, 0x6d, 0xc8, 0xa4, 0x98, 0x49,
+	0xa6, 0xd4, 0xa4, 0xbb, 0x6e, 0xc3, 0x99, 0xd3, 0xa0, 0x0d, 0x05, 0xeb, 0x78, 0x00, 0x3d, 0x69,
+	0x3d, 0xf7, 0x22, 0xe8, 0x50, 0x96, 0xf1, 0xe5, 0x85, 0x10, 0xec, 0x41, 0x87, 0x49, 0x29, 0xa4,
+	0x09, 0xc1, 0x80, 0x5a, 0x81, 0xfc, 0x01, 0x86, 0x68, 0x9f, 0x9f, 0x67, 0x51, 0xa0, 0x99, 0x73,
+	0x79, 0x6f, 0xd5, 0x89, 0x7f, 0x18, 0x1d, 0x85, 0xac, 0x5c, 0x7b, 0x1e, 0x74, 0x4c, 0xe0, 0xc8,
+	0x55, 0xb0, 0x09, 0xf0, 0xdd, 0x5d, 0x03, 0xda, 0x33, 0xf2, 0x93, 0xc8, 0x7b, 0x0c, 0xfd, 0x22,
+	0x60, 0xe4, 0x21, 0x6c, 0x86, 0x41, 0x16, 0x4c, 0x63, 0x1e, 0xeb, 0x98, 0x29, 0x43, 0x1d, 0x1e,
+	0x5e, 0xae, 0xee, 0x39, 0xa9, 0x69, 0xe9, 0x0a, 0xd7, 0xfb, 0xa6, 0x01, 0x9b, 0x75, 0x35, 0x99,
+	0x40, 0xef, 0x1d, 0x93, 0x2a, 0x16, 0x69, 0x71, 0xa5, 0x13, 0xc9, 0xcf, 0x01, 0x74, 0xa0, 0x16,
+	0xbe, 0x5e, 0x66, 0x4c, 0x4d, 0x9a, 0x37, 0x5a, 0xfb, 0x03, 0x3a, 0x40, 0xe4, 0x25, 0x02, 0x18,
+	0x02, 0x2d, 0x04, 0x57, 0x93, 0x96, 0xd1, 0x58, 0x81, 0x1c, 0xc2, 0xa5, 0x28, 0x56, 0x01, 0xe7,
+	0xe2, 0x9c, 0x45, 0x7e, 0x6d, 0x7f, 0xc7, 0xb0, 0x76, 0x2b, 0xe5, 0xcb, 0xf2, 0xa4, 0x2b, 0xd0,
+	0x8b, 0xe4, 0xd2, 0x97, 0x79, 0x6a, 0xf2, 0xde, 0xa7, 0xdd, 0x48, 0x2e, 0x69, 0x9e, 0x12, 0x0f,
+	0xda, 0x73, 0xa1, 0xf4, 0xa4, 0xbb, 0x5e, 0x0d, 0xa7, 0x42, 0x69, 0x6a, 0x74, 0xde, 0x53, 0x68,
+	0xa3, 0x44, 0x7e, 0x01, 0x10, 0xb1, 0x8c, 0x8b, 0x65, 0xc2, 0x52, 0xed, 0x5c, 0xa9, 0x21, 0x68,
+	0xee, 0x4c, 0x8a, 0x3c, 0x2b, 0x32, 0x66, 0x04, 0xcc, 0x6b, 0xf0, 0xc1, 0x24, 0x6a, 0x40, 0x9b,
+	0xc1, 0x07, 0xef, 0xfb, 0x06, 0xb4, 0x31, 0x4b, 0x64, 0x1f, 0xb6, 0x95, 0x16, 0x59, 0x56, 0x38,
+	0x11, 0x47, 0x18, 0x67, 0x74, 0x61, 0xe4, 0x70, 0xb4, 0xff, 0x49, 0xa4, 0xc8, 0x6b, 0xd8, 0x0d,
+	0xa2, 0x7f, 0xe7, 0x4a, 0xe3, 0x35, 0xbe, 0x0b, 0x9e, 0x8d, 0xd7, 0xf0, 0xf0, 0xd6, 0x6a, 0xf2,
+	0x0f, 0x8e, 0x4a, 0xe6, 0x2b, 0x47, 0x7c, 0x94, 0x6a, 0xb9, 0xa4, 0x24, 0xb8, 0xa0, 0x40, 0x13,
+	0x64, 0x9e, 0xa6, 0x71, 0x3a, 0xab, 0x4c, 0xb0, 0xb1, 0x1e, 0x39, 0xdc, 0x99, 0x70, 0xed, 0x11,
+	0x5c, 0xf9, 0xc4, 0xc1, 0x64, 0x1b, 0x5a, 0x0b, 0xb6, 0x74, 0xf1, 0xc0, 0x25, 0x06, 0xe2, 0x5d,
+	0xc0, 0x73, 0x66, 0x02, 0xd1, 0xa2, 0x56, 0x78, 0xd8, 0x7c, 0xd0, 0xf0, 0xbe, 0x6a, 0x00, 0x54,
+	0x25, 0x4a, 0x6e, 0x42, 0x07, 0xef, 0xb5, 0x7e, 0xaf, 0x84, 0x1f, 0xef, 0xa5, 0x56, 0xf9, 0xd1,
+	0x40, 0x35, 0x3f, 0x1a, 0xa8, 0x3f, 0xc2, 0xb0, 0xf2, 0xd2, 0xba, 0xb2, 0xd2, 0x1d, 0x95, 0x0b,
+	0xb4, 0x4e, 0xf4, 0x38, 0x40, 0xa5, 0xc2, 0x62, 0x71, 0xf7, 0x38, 0xa7, 0xba, 0xda, 0x9c, 0x5f,
+	0x2f, 0x64, 0xeb, 0x59, 0x21, 0x92, 0xbb, 0xd0, 0x13, 0x99, 0x36, 0x59, 0xb1, 0x2d, 0xb9, 0x53,
+	0x5d, 0xfa, 0x77, 0xab, 0xa0, 0x05, 0xc3, 0x7b, 0x0e, 0xfd, 0x62, 0x2a, 0x7c, 0xfa, 0xae, 0x3d,
+	0xe8, 0x28, 0x8d, 0x2d, 0xee, 0x8a, 0xc9, 0x08, 0xe4, 0x32, 0x74, 0x45, 0xae, 0xb3, 0x5c, 0xbb,
+	0x82, 0x72, 0x92, 0xf7, 0x14, 0xba, 0x76, 0x2c, 0x7d, 0xfa, 0xc0, 0x3b, 0xf5, 0x79, 0x32, 0x3c,
+	0xdc, 0x5d, 0x8d, 0xf5, 0x23, 0x54, 0xb9, 0x21, 0xe3, 0xfd, 0xbf, 0x01, 0x83, 0x12, 0x44, 0xaf,
+	0x13, 0xa6, 0x54, 0x30, 0x63, 0x45, 0xfb, 0x3a, 0x91, 0x5c, 0x83, 0x7e, 0x18, 0x68, 0x36, 0x13,
+	0x72, 0xe9, 0xcc, 0x2c, 0x65, 0xac, 0x8a, 0x30, 0x89, 0x9c, 0x99, 0xb8, 0x24, 0xbf, 0x84, 0x21,
+	0x7b, 0x1f, 0x6b, 0x1f, 0x3d, 0xc9, 0x95, 0xe9, 0xc3, 0x16, 0x05, 0x84, 0x5e, 0x18, 0xa4, 0xe6,
+	0x5c, 0x67, 0xc5, 0xb9, 0x7f, 0x41, 0x1b, 0xad, 0xa9, 0x4d, 0xc8, 0x81, 0x99, 0x90, 0xb5, 0xa0,
+	0x37, 0xbf, 0x14, 0xf4, 0xfa, 0x04, 0x68, 0xd5, 0x27, 0x80, 0xf7, 0x6d, 0x0b, 0x7a, 0x8e, 0x4d,
+	0xee, 0x42, 0x3b, 0x15, 0x22, 0x73, 0xe3, 0xee, 0x52, 0x75, 0xdc, 0x33, 0x21, 0x32, 0x47, 0xc2,
+	0x27, 0x02, 0x49, 0x48, 0x5e, 0xc4, 0x9c, 0x4f, 0x9a, 0xeb, 0xe4, 0xbf, 0xc6, 0x9c, 0xd7, 0xc8,
+	0x48, 0x22, 0x47, 0xb0, 0x89, 0xbf, 0x7e, 0x26, 0x45, 0x88, 0xef, 0x84, 0xad, 0x92, 0xeb, 0xab,
+	0x9b, 0xce, 0xac, 0xb2, 0xda, 0x3b, 0x5c, 0x54, 0x28, 0xb9, 0x0f, 0x5d, 0xa5, 0xcd, 0x23, 0x63,
+	0x9f, 0xae, 0x2b, 0xd5, 0xe6, 0x17, 0x5a, 0xae, 0xec, 0x73, 0x44, 0xf2, 0x67, 0x18, 0x86, 0x22,
+	0xd5, 0x52, 0x70, 0x3f, 0x65, 0xc5, 0x53, 0xf6, 0xb3, 0xda, 0x14, 0xb7, 0xca, 0x67, 0x4c, 0x57,
+	0x7b, 0x21, 0x2c, 0x41, 0xf2, 0x27, 0xe8, 0xbf, 0x89, 0x25, 0x3b, 0x0f, 0x38, 0x77, 0x13, 0xf2,
+	0x6a, 0xb5, 0xf9, 0xb1, 0xd3, 0x54, 0x5b, 0x4b, 0x32, 0x79, 0x00, 0x83, 0x37, 0xe8, 0x6e, 0x14,
+	0xab, 0xc5, 0xa4, 0x77, 0x71, 0x27, 0xe7, 0x7f, 0x89, 0xd5, 0x62, 0x65, 0xa7, 0x85, 0xf0, 0x4a,
+	0x35, 0xcf, 0x75, 0x24, 0xce, 0xd3, 0x49, 0x7f, 0x7d, 0xe3, 0x0b, 0xa7, 0xa9, 0x6d, 0x2c, 0xc8,
+	0xf8, 0xa6, 0x16, 0x0d, 0x76, 0x17, 0x86, 0xb5, 0x84, 0x91, 0xeb, 0x30, 0x30, 0x73, 0x22, 0x98,
+	0x72, 0x5b, 0xc2, 0x7d, 0x5a, 0x01, 0xde, 0x16, 0x0c, 0x6b, 0x09, 0xf3, 0xbe, 0x6e, 0x00, 0xb9,
+	0x98, 0x0b, 0xf2, 0x2b, 0xd8, 0x74, 0xa9, 0xf3, 0xd3, 0x20, 0x29, 0x3a, 0x61, 0xe8, 0xb0, 0x67,
+	0x41, 0xc2, 0xc8, 0xef, 0xe1, 0x72, 0x22, 0xd2, 0x58, 0x0b, 0xc9, 0x22, 0x7f, 0x85, 0x6c, 0x7b,
+	0x63, 0xaf, 0xd4, 0x9e, 0xd5, 0x76, 0xdd, 0x87, 0xae, 0x0e, 0xe4, 0x8c, 0xe9, 0x49, 0x6b, 0xdd,
+	0x5b, 0xcc, 0x4e, 0x10, 0xa7, 0x4c, 0xbe, 0x34, 0x04, 0xea, 0x88, 0xde, 0x77, 0x4d, 0xd8, 0x5a,
+	0xc9, 0x38, 0xb6, 0xa8, 0x8e, 0x13, 0x26, 0xf2, 0xe2, 0x59, 0x2a, 0x44, 0x72, 0x0b, 0xc6, 0x69,
+	0x9e, 0xf8, 0x61, 0x96, 0xfb, 0xe7, 0x42, 0x2e, 0x98, 0x54, 0x6e, 0x74, 0x6d, 0xa5, 0x79, 0x72,
+	0x92, 0xe5, 0xaf, 0x2d, 0x48, 0x6e, 0xc2, 0x08, 0x79, 0xb1, 0x28, 0x69, 0x2d, 0x43, 0xdb, 0x4c,
+	0xf3, 0xe4, 0x89, 0x28, 0x58, 0xbf, 0x05, 0x82, 0xac, 0x84, 0x25, 0x42, 0x2e, 0x4b, 0xa6, 0xed,
+	0xe4, 0xed, 0x34, 0x4f, 0xfe, 0x66, 0x14, 0x05, 0xfb, 0x00, 0x76, 0x57, 0x98, 0xfe, 0x74, 0xa9,
+	0xcd, 0x33, 0x8d, 0x16, 0xee, 0x24, 0x35, 0xee, 0x31, 0x2a, 0x0a, 0x5b, 0xe7, 0x51, 0x54, 0x1e,
+	0xdd, 0x2d, 0x6d, 0x3d, 0x8d, 0xa2, 0xe2, 0xdc, 0x7d, 0xd8, 0xae, 0x38, 0xee, 0xd0, 0x9e, 0x39,
+	0x74, 0x34, 0x8f, 0xa2, 0xfa, 0x89, 0x77, 0xa0, 0x2d, 0x83, 0x24, 0x9b, 0xf4, 0xd7, 0x5b, 0x94,
+	0x06, 0x49, 0x51, 0x1e, 0xd4, 0x50, 0xbc, 0xff, 0x35, 0x61, 0xe7, 0x42, 0x3b, 0x7c, 0x26, 0xb0,
+	0x7b, 0xd0, 0x89, 0x18, 0x0f, 0x8a, 0xc1, 0x67, 0x05, 0x72, 0x1b, 0xc6, 0x66, 0xe1, 0xbf, 0x0b,
+	0x64, 0x1c, 0xe0, 0x19, 0x6e, 0x02, 0x8e, 0x0c, 0xfc, 0xaa, 0x40, 0x09, 0x81, 0x36, 0x17, 0xae,
+	0x95, 0x07, 0xd4, 0xac, 0xc9, 0x1d, 0xd8, 0xc6, 0x5f, 0x3f, 0x14, 0x52, 0x32, 0x6e, 0x77, 0xdb,
+	0x60, 0x8d, 0x11, 0x3f, 0xa9, 0xe0, 0xd2, 0xb1, 0xee, 0x17, 0x1d, 0xab, 0x15, 0x58, 0xef, 0xc7,
+	0x16, 0xd8, 0x7f, 0x1b, 0x30, 0x5e, 0xeb, 0xee, 0xcf, 0x44, 0x62, 0x1f, 0xb6, 0xa7, 0x5c, 0x84,
+	0x0b, 0x7f, 0x2a, 0xd4, 0xdc, 0x37, 0x87, 0x9b, 0xa0, 0xf4, 0xe9, 0xc8, 0xe0, 0xc7, 0x42, 0xcd,
+	0x8f, 0x10, 0xfd, 0x29, 0xb5, 0x9e, 0xa0, 0x25, 0x2b, 0xd3, 0x02, 0xff, 0x86, 0x65, 0xf8, 0xec,
+	0x2a, 0x5d, 0xfc, 0x0d, 0xeb, 0xd3, 0x1a, 0x82, 0xed, 0xce, 0xb2, 0x39, 0x4b, 0x98, 0x0c, 0xb8,
+	0x33, 0xa4, 0x02, 0x50, 0xab, 0x59, 0x92, 0x09, 0x19, 0xc8, 0xa5, 0x7b, 0x09, 0x2a, 0xc0, 0x5b,
+	0xc0, 0x78, 0x6d, 0xc6, 0xe0, 0xab, 0x24, 0xd9, 0x54, 0x88, 0xe2, 0x2a, 0x27, 0x61, 0x01, 0xbc,
+	0x11, 0x32, 0x64, 0xee, 0x0a, 0x2b, 0x20, 0x1a, 0xca, 0x40, 0xcd, 0xdd, 0xd1, 0x56, 0x40, 0x54,
+	0x2d, 0x95, 0x7c, 0xeb, 0xd2, 0x6d, 0x05, 0xef, 0x3d, 0x0c, 0x6b, 0xe9, 0xc2, 0xd7, 0x34, 0xca,
+	0xa5, 0x4d, 0xbb, 0x0d, 0x71, 0x29, 0x9b, 0x03, 0x34, 0xcb, 0x8a, 0xe6, 0xb5, 0x02, 0xf9, 0x35,
+	0x6c, 0x29, 0x1d, 0x48, 0xed, 0x67, 0x4c, 0x86, 0x18, 0x0c, 0xd7, 0xb3, 0x06, 0x3c, 0xb3, 0x18,
+	0x56, 0x9a, 0x19, 0xa6, 0xf6, 0x7f, 0xaf, 0x59, 0x7b, 0xcf, 0x61, 0xbc, 0x16, 0x70, 0x7c, 0xaf,
+	0x33, 0xf7, 0xba, 0xb6, 0x28, 0x2e, 0xd1, 0xf1, 0xb0, 0xfe, 0x7f, 0xd6, 0x49, 0x68, 0x0b, 0x0f,
+	0xa6, 0x8c, 0xbb, 0xca, 0xb6, 0xc2, 0xe1, 0x11, 0x6c, 0x9a, 0x24, 0x9f, 0xd8, 0xaf, 0x3d, 0x72,
+	0x1f, 0x7a, 0x27, 0x22, 0x4d, 0x59, 0xa8, 0xc9, 0x4e, 0xfd, 0xdb, 0xc9, 0x7c, 0xf5, 0x5c, 0x1b,
+	0xd7, 0xa1, 0x8c, 0x2f, 0xf7, 0x1b, 0xf7, 0x1a, 0xc7, 0xf0, 0xcf, 0xf2, 0xe3, 0x70, 0xda, 0x35,
+	0x5f, 0x8b, 0xbf, 0xfb, 0x61, 0x00, 0x40, 0x0a, 0xc8, 0x19, 0x44, 0x0e, 0x00, 0x00,
+}
@@ -0,0 +1,247 @@
+package agentrpc
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// Client talks to the API over a single agent channel stream;
+// stream is reopened by the next request after it breaks
+type Client struct {
+	client  AgentChannelClient
+	agentID string
+	timeout time.Duration
+
+	stream *clientStream
+	lock   sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewClient expects replies within timeout which must be longer than how long API holds polls
+func NewClient(conn *grpc.ClientConn, agentID string, timeout time.Duration, logger boshlog.Logger) *Client {
+	return &Client{
+		client:  NewAgentChannelClient(conn),
+		agentID: agentID,
+		timeout: timeout,
+
+		logTag: "agentrpc.Client",
+		logger: logger,
+	}
+}
+
+// Register and other requests are made on behalf of agent that client was built for
+func (c *Client) Register(agentID string, caps agentreg.Capabilities) error {
+	_, err := c.request(&Request{Request: &Request_Register{&Register{Capabilities: NewCapabilities(caps)}}})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Registering agent '%s'", agentID)
+	}
+
+	return nil
+}
+
+func (c *Client) PollTasks(agentID string, req tasks.PollRequest) (tasks.PollResponse, error) {
+	reply, err := c.request(&Request{Request: &Request_Poll{NewPoll(req)}})
+	if err != nil {
+		return tasks.PollResponse{}, bosherr.WrapErrorf(err, "Polling tasks '%s'", agentID)
+	}
+
+	return reply.GetPollUpdate().PollResponse()
+}
+
+func (c *Client) RecordTaskProgress(taskID string, req tasks.ProgressRequest) error {
+	_, err := c.request(&Request{Request: &Request_Progress{NewProgress(taskID, req)}})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Updating task '%s' progress", taskID)
+	}
+
+	return nil
+}
+
+func (c *Client) RecordTaskResult(taskID string, err error) error {
+	_, err = c.request(&Request{Request: &Request_Result{NewResult(taskID, err)}})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Updating task '%s'", taskID)
+	}
+
+	return nil
+}
+
+func (c *Client) request(req *Request) (*Reply, error) {
+	stream, err := c.openStream()
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.request(req, c.timeout)
+}
+
+// openStream returns current stream unless it broke
+func (c *Client) openStream() (*clientStream, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stream != nil && !c.stream.isBroken() {
+		return c.stream, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	grpcStream, err := c.client.Connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, bosherr.WrapError(err, "Opening agent channel")
+	}
+
+	stream := &clientStream{
+		stream:  grpcStream,
+		cancel:  cancel,
+		pending: map[uint64]chan *Reply{},
+		logTag:  c.logTag,
+		logger:  c.logger,
+	}
+
+	go stream.receive()
+
+	_, err = stream.request(&Request{Request: &Request_Hello{&Hello{AgentId: c.agentID}}}, c.timeout)
+	if err != nil {
+		stream.close(err)
+		return nil, bosherr.WrapError(err, "Opening agent channel")
+	}
+
+	c.logger.Debug(c.logTag, "Opened agent channel for agent '%s'", c.agentID)
+
+	c.stream = stream
+
+	return stream, nil
+}
+
+type clientStream struct {
+	stream AgentChannel_ConnectClient
+	cancel context.CancelFunc
+
+	sendLock sync.Mutex
+
+	nextID  uint64
+	pending map[uint64]chan *Reply
+	err     error // set once stream breaks
+	lock    sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func (s *clientStream) request(req *Request, timeout time.Duration) (*Reply, error) {
+	replyCh := make(chan *Reply, 1)
+
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return nil, s.err
+	}
+	s.nextID++
+	req.Id = s.nextID
+	s.pending[req.Id] = replyCh
+	s.lock.Unlock()
+
+	s.sendLock.Lock()
+	err := s.stream.Send(req)
+	s.sendLock.Unlock()
+
+	if err != nil {
+		s.close(bosherr.WrapError(err, "Sending request"))
+	}
+
+	select {
+	case reply, ok := <-replyCh:
+		if !ok {
+			return nil, s.brokenErr()
+		}
+
+		if len(reply.Error) > 0 {
+			return nil, bosherr.Error(reply.Error)
+		}
+
+		return reply, nil
+
+	case <-time.After(timeout):
+		// Stream is likely stuck (e.g. API went away without closing connection)
+		s.close(bosherr.Errorf("Timed out waiting for reply after %s", timeout))
+		return nil, s.brokenErr()
+	}
+}
+
+func (s *clientStream) receive() {
+	for {
+		reply, err := s.stream.Recv()
+		if err != nil {
+			s.close(bosherr.WrapError(err, "Receiving reply"))
+			return
+		}
+
+		s.lock.Lock()
+		replyCh, found := s.pending[reply.Id]
+		delete(s.pending, reply.Id)
+		s.lock.Unlock()
+
+		if !found {
+			s.logger.Debug(s.logTag, "Dropping reply to unknown request '%d'", reply.Id)
+			continue
+		}
+
+		replyCh <- reply
+	}
+}
+
+// close fails pending requests; only first error is kept
+func (s *clientStream) close(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return
+	}
+
+	s.err = err
+	s.cancel()
+
+	for id, replyCh := range s.pending {
+		close(replyCh)
+		delete(s.pending, id)
+	}
+}
+
+func (s *clientStream) isBroken() bool {
+	return s.brokenErr() != nil
+}
+
+func (s *clientStream) brokenErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+// BasicAuthCredentials sends shared credentials with each stream;
+// not used by agents that authenticate with client certificates
+type BasicAuthCredentials struct {
+	Username string
+	Password string
+}
+
+func (c BasicAuthCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	creds := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+	return map[string]string{"authorization": "Basic " + creds}, nil
+}
+
+func (BasicAuthCredentials) RequireTransportSecurity() bool { return true }
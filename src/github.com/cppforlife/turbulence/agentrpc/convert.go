@@ -0,0 +1,377 @@
+package agentrpc
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+func NewCapabilities(caps agentreg.Capabilities) *Capabilities {
+	msg := &Capabilities{
+		Version:             caps.Version,
+		TaskTypes:           caps.TaskTypes,
+		Tools:               caps.Tools,
+		DisallowedTaskTypes: caps.DisallowedTaskTypes,
+		DryRun:              caps.DryRun,
+	}
+
+	if caps.Host != nil {
+		msg.Host = &Host{Deployment: caps.Host.Deployment, Group: caps.Host.Group, Az: caps.Host.AZ}
+	}
+
+	return msg
+}
+
+func (m *Capabilities) AgentCapabilities() agentreg.Capabilities {
+	caps := agentreg.Capabilities{
+		Version:             m.GetVersion(),
+		TaskTypes:           m.GetTaskTypes(),
+		Tools:               m.GetTools(),
+		DisallowedTaskTypes: m.GetDisallowedTaskTypes(),
+		DryRun:              m.GetDryRun(),
+	}
+
+	if host := m.GetHost(); host != nil {
+		caps.Host = &agentreg.Host{Deployment: host.GetDeployment(), Group: host.GetGroup(), AZ: host.GetAz()}
+	}
+
+	return caps
+}
+
+func NewPoll(req tasks.PollRequest) *Poll {
+	msg := &Poll{
+		RunningTaskIds: req.RunningTaskIDs,
+		StoppedTaskIds: req.StoppedTaskIDs,
+	}
+
+	if len(req.AdjustmentVersions) > 0 {
+		msg.AdjustmentVersions = map[string]int64{}
+
+		for taskID, version := range req.AdjustmentVersions {
+			msg.AdjustmentVersions[taskID] = int64(version)
+		}
+	}
+
+	return msg
+}
+
+// PollRequest always reports running tasks since all agents that use the channel do
+func (m *Poll) PollRequest() tasks.PollRequest {
+	req := tasks.PollRequest{
+		RunningTaskIDs: append([]string{}, m.GetRunningTaskIds()...),
+		StoppedTaskIDs: m.GetStoppedTaskIds(),
+	}
+
+	if len(m.GetAdjustmentVersions()) > 0 {
+		req.AdjustmentVersions = map[string]int{}
+
+		for taskID, version := range m.GetAdjustmentVersions() {
+			req.AdjustmentVersions[taskID] = int(version)
+		}
+	}
+
+	return req
+}
+
+func NewPollUpdate(resp tasks.PollResponse) (*PollUpdate, error) {
+	msg := &PollUpdate{StoppedTaskIds: resp.StoppedTaskIDs}
+
+	for _, task := range resp.Tasks {
+		taskMsg, err := NewTask(task)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Tasks = append(msg.Tasks, taskMsg)
+	}
+
+	for _, adj := range resp.Adjustments {
+		opts, err := newSingleOptions(adj.Options)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Converting adjustment of task '%s'", adj.TaskID)
+		}
+
+		msg.Adjustments = append(msg.Adjustments, &Adjustment{TaskId: adj.TaskID, Version: int64(adj.Version), Options: opts})
+	}
+
+	return msg, nil
+}
+
+func (m *PollUpdate) PollResponse() (tasks.PollResponse, error) {
+	resp := tasks.PollResponse{StoppedTaskIDs: m.GetStoppedTaskIds()}
+
+	for _, taskMsg := range m.GetTasks() {
+		task, err := taskMsg.Task()
+		if err != nil {
+			return resp, err
+		}
+
+		resp.Tasks = append(resp.Tasks, task)
+	}
+
+	for _, adjMsg := range m.GetAdjustments() {
+		opts, err := adjMsg.GetOptions().TaskOptions()
+		if err != nil {
+			return resp, bosherr.WrapErrorf(err, "Converting adjustment of task '%s'", adjMsg.TaskId)
+		}
+
+		resp.Adjustments = append(resp.Adjustments, tasks.Adjustment{
+			TaskID:  adjMsg.TaskId,
+			Version: int(adjMsg.Version),
+			Options: tasks.OptionsSlice{opts},
+		})
+	}
+
+	return resp, nil
+}
+
+func NewProgress(taskID string, req tasks.ProgressRequest) *Progress {
+	return &Progress{TaskId: taskID, State: req.State, Output: req.Output}
+}
+
+func (m *Progress) ProgressRequest() tasks.ProgressRequest {
+	return tasks.ProgressRequest{State: m.GetState(), Output: m.GetOutput()}
+}
+
+// NewResult categorizes errors that are not task errors as execution errors
+func NewResult(taskID string, err error) *Result {
+	msg := &Result{TaskId: taskID}
+
+	if err != nil {
+		taskErr, ok := err.(tasks.TaskError)
+		if !ok {
+			taskErr = tasks.TaskError{Category: tasks.ErrorCategoryExecution, Message: err.Error()}
+		}
+
+		msg.Error = &TaskError{
+			Message:    taskErr.Message,
+			Category:   taskErr.Category,
+			Cmd:        taskErr.Cmd,
+			ExitStatus: int64(taskErr.ExitStatus),
+			Output:     taskErr.Output,
+		}
+	}
+
+	return msg
+}
+
+func (m *Result) ResultRequest() tasks.ResultRequest {
+	var req tasks.ResultRequest
+
+	if errMsg := m.GetError(); errMsg != nil {
+		req.Error = errMsg.Message
+
+		if len(errMsg.Category) > 0 {
+			req.TaskError = &tasks.TaskError{
+				Category:   errMsg.Category,
+				Message:    errMsg.Message,
+				Cmd:        errMsg.Cmd,
+				ExitStatus: int(errMsg.ExitStatus),
+				Output:     errMsg.Output,
+			}
+		}
+	}
+
+	return req
+}
+
+func NewTask(task tasks.Task) (*Task, error) {
+	opts, err := newSingleOptions(task.Optionss)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Converting task '%s'", task.ID)
+	}
+
+	return &Task{Id: task.ID, Options: opts, DryRun: task.DryRun}, nil
+}
+
+func (m *Task) Task() (tasks.Task, error) {
+	opts, err := m.GetOptions().TaskOptions()
+	if err != nil {
+		return tasks.Task{}, bosherr.WrapErrorf(err, "Converting task '%s'", m.Id)
+	}
+
+	return tasks.Task{ID: m.Id, Optionss: tasks.OptionsSlice{opts}, DryRun: m.DryRun}, nil
+}
+
+// newSingleOptions converts options of a task; tasks (and adjustments) carry exactly one
+func newSingleOptions(optss tasks.OptionsSlice) (*Options, error) {
+	if len(optss) != 1 {
+		return nil, bosherr.Errorf("Expected exactly one task options but found %d", len(optss))
+	}
+
+	return NewOptions(optss[0])
+}
+
+func NewOptions(taskOpts tasks.Options) (*Options, error) {
+	switch o := taskOpts.(type) {
+	case tasks.NoopOptions:
+		return &Options{Options: &Options_Noop{&NoopOptions{Stoppable: o.Stoppable}}}, nil
+
+	case tasks.KillOptions:
+		return &Options{Options: &Options_Kill{&KillOptions{}}}, nil
+
+	case tasks.KillProcessOptions:
+		return &Options{Options: &Options_KillProcess{&KillProcessOptions{
+			ProcessName:          o.ProcessName,
+			MonitoredProcessName: o.MonitoredProcessName,
+			Target:               newContainerTarget(o.Target),
+		}}}, nil
+
+	case tasks.StressOptions:
+		return &Options{Options: &Options_Stress{&StressOptions{
+			Timeout:           o.Timeout,
+			NumCpuWorkers:     int64(o.NumCPUWorkers),
+			NumIoWorkers:      int64(o.NumIOWorkers),
+			NumMemoryWorkers:  int64(o.NumMemoryWorkers),
+			MemoryWorkerBytes: o.MemoryWorkerBytes,
+			NumHddWorkers:     int64(o.NumHDDWorkers),
+			HddWorkerBytes:    o.HDDWorkerBytes,
+			Ramp:              newRampOptions(o.Ramp),
+		}}}, nil
+
+	case tasks.ControlNetOptions:
+		return &Options{Options: &Options_ControlNet{&ControlNetOptions{
+			Timeout:         o.Timeout,
+			Delay:           o.Delay,
+			DelayVariation:  o.DelayVariation,
+			Loss:            o.Loss,
+			LossCorrelation: o.LossCorrelation,
+			Ramp:            newRampOptions(o.Ramp),
+			Target:          newContainerTarget(o.Target),
+		}}}, nil
+
+	case tasks.FirewallOptions:
+		return &Options{Options: &Options_Firewall{&FirewallOptions{
+			Timeout:        o.Timeout,
+			BlockBoshAgent: o.BlockBOSHAgent,
+			Target:         newContainerTarget(o.Target),
+		}}}, nil
+
+	case tasks.FillDiskOptions:
+		return &Options{Options: &Options_FillDisk{&FillDiskOptions{
+			Persistent: o.Persistent,
+			Ephemeral:  o.Ephemeral,
+			Temporary:  o.Temporary,
+		}}}, nil
+
+	case tasks.ShutdownOptions:
+		return &Options{Options: &Options_Shutdown{&ShutdownOptions{
+			Reboot: o.Reboot,
+			Force:  o.Force,
+			Crash:  o.Crash,
+			Sysrq:  o.Sysrq,
+		}}}, nil
+
+	default:
+		return nil, bosherr.Errorf("Unknown task type '%T'", taskOpts)
+	}
+}
+
+// TaskOptions fails for options added to the channel after this agent or API was built
+func (m *Options) TaskOptions() (tasks.Options, error) {
+	switch o := m.GetOptions().(type) {
+	case *Options_Noop:
+		return tasks.NoopOptions{Stoppable: o.Noop.Stoppable}, nil
+
+	case *Options_Kill:
+		return tasks.KillOptions{}, nil
+
+	case *Options_KillProcess:
+		return tasks.KillProcessOptions{
+			ProcessName:          o.KillProcess.ProcessName,
+			MonitoredProcessName: o.KillProcess.MonitoredProcessName,
+			Target:               o.KillProcess.Target.containerTarget(),
+		}, nil
+
+	case *Options_Stress:
+		return tasks.StressOptions{
+			Timeout:           o.Stress.Timeout,
+			NumCPUWorkers:     int(o.Stress.NumCpuWorkers),
+			NumIOWorkers:      int(o.Stress.NumIoWorkers),
+			NumMemoryWorkers:  int(o.Stress.NumMemoryWorkers),
+			MemoryWorkerBytes: o.Stress.MemoryWorkerBytes,
+			NumHDDWorkers:     int(o.Stress.NumHddWorkers),
+			HDDWorkerBytes:    o.Stress.HddWorkerBytes,
+			Ramp:              o.Stress.Ramp.rampOptions(),
+		}, nil
+
+	case *Options_ControlNet:
+		return tasks.ControlNetOptions{
+			Timeout:         o.ControlNet.Timeout,
+			Delay:           o.ControlNet.Delay,
+			DelayVariation:  o.ControlNet.DelayVariation,
+			Loss:            o.ControlNet.Loss,
+			LossCorrelation: o.ControlNet.LossCorrelation,
+			Ramp:            o.ControlNet.Ramp.rampOptions(),
+			Target:          o.ControlNet.Target.containerTarget(),
+		}, nil
+
+	case *Options_Firewall:
+		return tasks.FirewallOptions{
+			Timeout:        o.Firewall.Timeout,
+			BlockBOSHAgent: o.Firewall.BlockBoshAgent,
+			Target:         o.Firewall.Target.containerTarget(),
+		}, nil
+
+	case *Options_FillDisk:
+		return tasks.FillDiskOptions{
+			Persistent: o.FillDisk.Persistent,
+			Ephemeral:  o.FillDisk.Ephemeral,
+			Temporary:  o.FillDisk.Temporary,
+		}, nil
+
+	case *Options_Shutdown:
+		return tasks.ShutdownOptions{
+			Reboot: o.Shutdown.Reboot,
+			Force:  o.Shutdown.Force,
+			Crash:  o.Shutdown.Crash,
+			Sysrq:  o.Shutdown.Sysrq,
+		}, nil
+
+	default:
+		return nil, bosherr.Error("Unknown task type")
+	}
+}
+
+func newRampOptions(opts *tasks.RampOptions) *RampOptions {
+	if opts == nil {
+		return nil
+	}
+
+	return &RampOptions{
+		Duration:     opts.Duration,
+		Steps:        int64(opts.Steps),
+		StartPercent: int64(opts.StartPercent),
+		Down:         opts.Down,
+	}
+}
+
+func (m *RampOptions) rampOptions() *tasks.RampOptions {
+	if m == nil {
+		return nil
+	}
+
+	return &tasks.RampOptions{
+		Duration:     m.Duration,
+		Steps:        int(m.Steps),
+		StartPercent: int(m.StartPercent),
+		Down:         m.Down,
+	}
+}
+
+func newContainerTarget(target *tasks.ContainerTarget) *ContainerTarget {
+	if target == nil {
+		return nil
+	}
+
+	return &ContainerTarget{Pid: int64(target.PID), Cgroup: target.Cgroup, Label: target.Label}
+}
+
+func (m *ContainerTarget) containerTarget() *tasks.ContainerTarget {
+	if m == nil {
+		return nil
+	}
+
+	return &tasks.ContainerTarget{PID: int(m.Pid), Cgroup: m.Cgroup, Label: m.Label}
+}
@@ -0,0 +1,141 @@
+package agentrpc_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	. "github.com/cppforlife/turbulence/agentrpc"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Conversion", func() {
+	ramp := &tasks.RampOptions{Duration: "1m", Steps: 3, StartPercent: 10, Down: true}
+
+	allOptions := []tasks.Options{
+		tasks.NoopOptions{Stoppable: true},
+		tasks.KillOptions{},
+		tasks.KillProcessOptions{
+			ProcessName: "post.*", MonitoredProcessName: "pg*", Target: &tasks.ContainerTarget{PID: 123},
+		},
+		tasks.StressOptions{
+			Timeout: "10m", NumCPUWorkers: 1, NumIOWorkers: 2, NumMemoryWorkers: 3, MemoryWorkerBytes: "1G",
+			NumHDDWorkers: 4, HDDWorkerBytes: "2G", Ramp: ramp,
+		},
+		tasks.ControlNetOptions{
+			Timeout: "5m", Delay: "50ms", DelayVariation: "10ms", Loss: "20%", LossCorrelation: "75%", Ramp: ramp,
+			Target: &tasks.ContainerTarget{Cgroup: "/garden/handle"},
+		},
+		tasks.FirewallOptions{
+			Timeout: "1m", BlockBOSHAgent: true, Target: &tasks.ContainerTarget{Label: "*-app-guid-*"},
+		},
+		tasks.FillDiskOptions{Persistent: true, Ephemeral: true, Temporary: true},
+		tasks.ShutdownOptions{Reboot: true, Force: true, Crash: true, Sysrq: "b"},
+	}
+
+	It("converts all task options both ways", func() {
+		for _, opts := range allOptions {
+			msg, err := NewOptions(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			converted, err := msg.TaskOptions()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(converted).To(Equal(opts))
+		}
+	})
+
+	It("does not categorize errors reported without category", func() {
+		req := (&Result{TaskId: "task1", Error: &TaskError{Message: "fake-err"}}).ResultRequest()
+		Expect(req).To(Equal(tasks.ResultRequest{Error: "fake-err"}))
+	})
+
+	It("keeps optional ramp and target unset", func() {
+		msg, err := NewOptions(tasks.ControlNetOptions{Delay: "50ms"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg.GetControlNet().Ramp).To(BeNil())
+		Expect(msg.GetControlNet().Target).To(BeNil())
+
+		converted, err := msg.TaskOptions()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted).To(Equal(tasks.ControlNetOptions{Delay: "50ms"}))
+	})
+
+	It("rejects options that are not known", func() {
+		_, err := (&Options{}).TaskOptions()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unknown task type"))
+	})
+
+	It("converts poll updates both ways", func() {
+		resp := tasks.PollResponse{
+			Tasks: []tasks.Task{
+				{ID: "task1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}, DryRun: true},
+				{ID: "task2", Optionss: tasks.OptionsSlice{tasks.StressOptions{NumCPUWorkers: 1}}},
+			},
+			StoppedTaskIDs: []string{"task3"},
+			Adjustments: []tasks.Adjustment{
+				{TaskID: "task2", Version: 2, Options: tasks.OptionsSlice{tasks.StressOptions{NumCPUWorkers: 2}}},
+			},
+		}
+
+		msg, err := NewPollUpdate(resp)
+		Expect(err).ToNot(HaveOccurred())
+
+		converted, err := msg.PollResponse()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted).To(Equal(resp))
+	})
+
+	It("requires tasks to carry exactly one options", func() {
+		_, err := NewTask(tasks.Task{ID: "task1"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected exactly one task options"))
+	})
+
+	It("converts poll requests both ways", func() {
+		req := tasks.PollRequest{
+			RunningTaskIDs:     []string{"task2"},
+			StoppedTaskIDs:     []string{"task1"},
+			AdjustmentVersions: map[string]int{"task2": 3},
+		}
+		Expect(NewPoll(req).PollRequest()).To(Equal(req))
+	})
+
+	It("always reports running tasks in poll requests", func() {
+		req := NewPoll(tasks.PollRequest{StoppedTaskIDs: []string{"task1"}}).PollRequest()
+		Expect(req).To(Equal(tasks.PollRequest{RunningTaskIDs: []string{}, StoppedTaskIDs: []string{"task1"}}))
+	})
+
+	It("converts progress", func() {
+		req := tasks.ProgressRequest{State: "running", Output: "out"}
+		Expect(NewProgress("task1", req).ProgressRequest()).To(Equal(req))
+	})
+
+	It("converts results with categorized and uncategorized errors", func() {
+		taskErr := tasks.TaskError{Category: tasks.ErrorCategoryRevertFailed, Message: "msg", Cmd: "tc", ExitStatus: 2, Output: "out"}
+
+		req := NewResult("task1", taskErr).ResultRequest()
+		Expect(req.Err()).To(Equal(taskErr))
+		Expect(req.Error).To(Equal("msg"))
+
+		req = NewResult("task1", errors.New("fake-err")).ResultRequest()
+		Expect(req.Err()).To(Equal(tasks.TaskError{Category: tasks.ErrorCategoryExecution, Message: "fake-err"}))
+
+		Expect(NewResult("task1", nil).ResultRequest()).To(Equal(tasks.ResultRequest{}))
+	})
+
+	It("converts capabilities both ways", func() {
+		caps := agentreg.Capabilities{
+			Version:             "1",
+			TaskTypes:           []string{"Noop", "Stress"},
+			Tools:               []string{"tc"},
+			DisallowedTaskTypes: []string{"Stress"},
+			DryRun:              true,
+			Host:                &agentreg.Host{Deployment: "dep", Group: "db", AZ: "z1"},
+		}
+
+		Expect(NewCapabilities(caps).AgentCapabilities()).To(Equal(caps))
+	})
+})
@@ -0,0 +1,230 @@
+package agentrpc
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// Server handles agent channel streams the same way
+// controllers handle JSON over HTTPS agent API
+type Server struct {
+	tasksRepo  tasks.Repo
+	agentsRepo agentreg.Repo
+
+	pollTimeout time.Duration
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewServer(tasksRepo tasks.Repo, agentsRepo agentreg.Repo, pollTimeout time.Duration, logger boshlog.Logger) Server {
+	return Server{
+		tasksRepo:  tasksRepo,
+		agentsRepo: agentsRepo,
+
+		pollTimeout: pollTimeout,
+
+		logTag: "agentrpc.Server",
+		logger: logger,
+	}
+}
+
+func (s Server) Connect(stream AgentChannel_ConnectServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	hello := req.GetHello()
+	if hello == nil || len(hello.AgentId) == 0 {
+		return grpc.Errorf(codes.InvalidArgument, "Expected first request to name agent")
+	}
+
+	// Agents with client certificates can only act on their own behalf
+	identity := s.identity(stream.Context())
+
+	if len(identity) > 0 && identity != hello.AgentId {
+		return grpc.Errorf(codes.PermissionDenied, "Agent '%s' cannot act as agent '%s'", identity, hello.AgentId)
+	}
+
+	conn := &connection{server: s, agentID: hello.AgentId, identity: identity, stream: stream}
+
+	s.logger.Debug(s.logTag, "Agent '%s' connected", conn.agentID)
+
+	conn.reply(req.Id, nil, nil)
+
+	// Replies must not be sent once stream is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// Poll is held until there is something for the agent
+		// while progress and results keep coming in
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			conn.handle(req)
+		}()
+	}
+}
+
+// identity returns common name of a verified client certificate;
+// empty if agents authenticate with shared credentials
+func (Server) identity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return ""
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+type connection struct {
+	server Server
+
+	agentID  string
+	identity string
+
+	stream   AgentChannel_ConnectServer
+	sendLock sync.Mutex
+}
+
+func (c *connection) handle(req *Request) {
+	switch {
+	case req.GetHello() != nil:
+		c.reply(req.Id, nil, bosherr.Errorf("Stream already belongs to agent '%s'", c.agentID))
+
+	case req.GetRegister() != nil:
+		caps := req.GetRegister().GetCapabilities().AgentCapabilities()
+		c.reply(req.Id, nil, c.server.agentsRepo.Register(c.agentID, caps))
+
+	case req.GetPoll() != nil:
+		update, err := c.poll(req.GetPoll())
+		c.reply(req.Id, update, err)
+
+	case req.GetProgress() != nil:
+		progress := req.GetProgress()
+
+		err := c.checkTask(progress.TaskId)
+		if err == nil {
+			err = c.server.tasksRepo.UpdateProgress(progress.TaskId, progress.ProgressRequest())
+		}
+
+		c.reply(req.Id, nil, err)
+
+	case req.GetResult() != nil:
+		result := req.GetResult()
+
+		err := c.checkTask(result.TaskId)
+		if err == nil {
+			err = c.server.tasksRepo.Update(result.TaskId, result.ResultRequest())
+		}
+
+		c.reply(req.Id, nil, err)
+
+	default:
+		c.reply(req.Id, nil, bosherr.Error("Unknown request"))
+	}
+}
+
+func (c *connection) poll(poll *Poll) (*PollUpdate, error) {
+	err := c.server.agentsRepo.Seen(c.agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.server.tasksRepo.Poll(c.agentID, poll.PollRequest(), c.server.pollTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Agent was connected while waiting
+	err = c.server.agentsRepo.Seen(c.agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPollUpdate(resp)
+}
+
+// checkTask returns an error unless task was consumed by the agent
+func (c *connection) checkTask(taskID string) error {
+	if len(c.identity) == 0 {
+		return nil
+	}
+
+	agentID, found, err := c.server.tasksRepo.FetchAgentID(taskID)
+	if err != nil {
+		return err
+	}
+
+	if !found || agentID != c.identity {
+		return bosherr.Errorf("Agent '%s' is not executing task '%s'", c.identity, taskID)
+	}
+
+	return nil
+}
+
+func (c *connection) reply(id uint64, update *PollUpdate, err error) {
+	reply := &Reply{Id: id, PollUpdate: update}
+
+	if err != nil {
+		reply.Error = err.Error()
+	}
+
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+
+	err = c.stream.Send(reply)
+	if err != nil {
+		c.server.logger.Error(c.server.logTag, "Failed replying to agent '%s': %s", c.agentID, err)
+	}
+}
+
+// NewBasicAuthInterceptor requires streams to carry shared credentials;
+// used when agents do not authenticate with client certificates
+func NewBasicAuthInterceptor(username, password string) grpc.StreamServerInterceptor {
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromContext(ss.Context())
+
+		for _, actual := range md["authorization"] {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(actual)), []byte(expected)) == 1 {
+				return handler(srv, ss)
+			}
+		}
+
+		return grpc.Errorf(codes.Unauthenticated, "Expected valid credentials")
+	}
+}
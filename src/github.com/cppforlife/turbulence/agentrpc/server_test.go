@@ -0,0 +1,315 @@
+package agentrpc_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	. "github.com/cppforlife/turbulence/agentrpc"
+	"github.com/cppforlife/turbulence/storage"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		tasksRepo  tasks.Repo
+		agentsRepo agentreg.Repo
+		logger     boshlog.Logger
+
+		listener net.Listener
+		server   *grpc.Server
+		conns    []*grpc.ClientConn
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		tasksRepo, err = tasks.NewRepo(tasks.DefaultRetention, storage.NewMemoryStore(), logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		agentsRepo = agentreg.NewRepo(logger)
+
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		conns = nil
+	})
+
+	AfterEach(func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		server.Stop()
+	})
+
+	serve := func(opts ...grpc.ServerOption) {
+		server = grpc.NewServer(opts...)
+		RegisterAgentChannelServer(server, NewServer(tasksRepo, agentsRepo, time.Second, logger))
+		go server.Serve(listener)
+	}
+
+	newClient := func(agentID string, opts ...grpc.DialOption) *Client {
+		conn, err := grpc.Dial(listener.Addr().String(), append(opts, grpc.WithInsecure())...)
+		Expect(err).ToNot(HaveOccurred())
+
+		conns = append(conns, conn)
+
+		return NewClient(conn, agentID, 5*time.Second, logger)
+	}
+
+	It("registers agent and delivers tasks, progress and results over single stream", func() {
+		serve()
+
+		client := newClient("agent1")
+
+		err := client.Register("agent1", agentreg.Capabilities{Version: "1", TaskTypes: []string{"Noop"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		agent, found, err := agentsRepo.Find("agent1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(agent.Capabilities.TaskTypes).To(Equal([]string{"Noop"}))
+
+		task := tasks.Task{ID: "task1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}
+
+		go tasksRepo.QueueAndWait("agent1", []tasks.Task{task}, tasks.DefaultDeliveryTimeout)
+
+		resp, err := client.PollTasks("agent1", tasks.PollRequest{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Tasks).To(Equal([]tasks.Task{task}))
+
+		err = client.RecordTaskProgress("task1", tasks.ProgressRequest{State: "running", Output: "out"})
+		Expect(err).ToNot(HaveOccurred())
+
+		progress, err := tasksRepo.FetchProgress("task1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(progress.State).To(Equal("running"))
+		Expect(progress.Output).To(Equal("out"))
+
+		taskErr := tasks.TaskError{Category: tasks.ErrorCategoryExecution, Message: "fake-err"}
+
+		err = client.RecordTaskResult("task1", taskErr)
+		Expect(err).ToNot(HaveOccurred())
+
+		result, err := tasksRepo.Wait("task1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Err()).To(Equal(taskErr))
+	})
+
+	It("returns errors from API without breaking stream", func() {
+		serve()
+
+		client := newClient("agent1")
+
+		err := client.RecordTaskResult("", nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Must provide non-empty task ID"))
+
+		err = client.Register("agent1", agentreg.Capabilities{Version: "1"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("holds polls while serving other requests", func() {
+		serve()
+
+		client := newClient("agent1")
+
+		polled := make(chan error, 1)
+
+		go func() {
+			_, err := client.PollTasks("agent1", tasks.PollRequest{})
+			polled <- err
+		}()
+
+		err := client.Register("agent1", agentreg.Capabilities{Version: "1"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(polled).ToNot(Receive())
+
+		Eventually(polled, 3*time.Second).Should(Receive(BeNil()))
+	})
+
+	Describe("basic auth", func() {
+		BeforeEach(func() {
+			serve(grpc.StreamInterceptor(NewBasicAuthInterceptor("user", "pass")))
+		})
+
+		It("accepts streams with valid credentials", func() {
+			client := newClient("agent1", grpc.WithPerRPCCredentials(insecureCreds{BasicAuthCredentials{"user", "pass"}}))
+
+			err := client.Register("agent1", agentreg.Capabilities{Version: "1"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects streams without credentials", func() {
+			client := newClient("agent1")
+
+			err := client.Register("agent1", agentreg.Capabilities{Version: "1"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected valid credentials"))
+		})
+
+		It("rejects streams with wrong credentials", func() {
+			client := newClient("agent1", grpc.WithPerRPCCredentials(insecureCreds{BasicAuthCredentials{"user", "wrong"}}))
+
+			err := client.Register("agent1", agentreg.Capabilities{Version: "1"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected valid credentials"))
+
+			_, found, err := agentsRepo.Find("agent1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("client certificates", func() {
+		var (
+			ca       *testCA
+			serverCA *x509.CertPool
+		)
+
+		BeforeEach(func() {
+			ca = newTestCA()
+			serverCA = x509.NewCertPool()
+			serverCA.AddCert(ca.cert)
+
+			tlsConfig := &tls.Config{
+				Certificates: []tls.Certificate{ca.issue("127.0.0.1")},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    serverCA,
+			}
+
+			serve(grpc.Creds(credentials.NewTLS(tlsConfig)))
+		})
+
+		newTLSClient := func(agentID, commonName string) *Client {
+			tlsConfig := &tls.Config{
+				Certificates: []tls.Certificate{ca.issue(commonName)},
+				RootCAs:      serverCA,
+				ServerName:   "127.0.0.1",
+			}
+
+			conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+			Expect(err).ToNot(HaveOccurred())
+
+			conns = append(conns, conn)
+
+			return NewClient(conn, agentID, 5*time.Second, logger)
+		}
+
+		It("allows agents to act on their own behalf", func() {
+			client := newTLSClient("agent1", "agent1")
+
+			err := client.Register("agent1", agentreg.Capabilities{Version: "1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			task := tasks.Task{ID: "task1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}
+
+			go tasksRepo.QueueAndWait("agent1", []tasks.Task{task}, tasks.DefaultDeliveryTimeout)
+
+			resp, err := client.PollTasks("agent1", tasks.PollRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Tasks).To(Equal([]tasks.Task{task}))
+
+			err = client.RecordTaskResult("task1", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects streams opened on behalf of other agents", func() {
+			client := newTLSClient("agent2", "agent1")
+
+			err := client.Register("agent2", agentreg.Capabilities{Version: "1"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Agent 'agent1' cannot act as agent 'agent2'"))
+
+			_, found, err := agentsRepo.Find("agent2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("rejects results of tasks executed by other agents", func() {
+			task := tasks.Task{ID: "task1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}
+
+			go tasksRepo.QueueAndWait("agent2", []tasks.Task{task}, tasks.DefaultDeliveryTimeout)
+
+			_, err := newTLSClient("agent2", "agent2").PollTasks("agent2", tasks.PollRequest{})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = newTLSClient("agent1", "agent1").RecordTaskResult("task1", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Agent 'agent1' is not executing task 'task1'"))
+		})
+	})
+})
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns certificate usable by servers (for 127.0.0.1) and clients
+func (ca *testCA) issue(commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	Expect(err).ToNot(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// insecureCreds allows sending credentials in tests without TLS
+type insecureCreds struct{ BasicAuthCredentials }
+
+func (insecureCreds) RequireTransportSecurity() bool { return false }
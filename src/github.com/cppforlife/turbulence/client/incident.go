@@ -21,6 +21,11 @@ func (i IncidentImpl) Wait() {
 	}
 }
 
+func (i IncidentImpl) Stop() {
+	err := i.client.StopIncident(i.id)
+	panicIfErr(err, "stop incident")
+}
+
 func (i IncidentImpl) TasksOfType(example tasks.Options) []Task {
 	var ts []Task
 
@@ -74,3 +79,14 @@ func (c Client) GetIncident(id string) (incident.Response, error) {
 
 	return resp, nil
 }
+
+func (c Client) StopIncident(id string) error {
+	var resp incident.Response
+
+	err := c.clientRequest.Post(fmt.Sprintf("/api/v1/incidents/%s/stop", id), nil, &resp)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Stopping incident")
+	}
+
+	return nil
+}
@@ -16,6 +16,9 @@ type Turbulence interface {
 type Incident interface {
 	Wait() // todo add timeout?
 
+	// Stop stops all running tasks and cancels tasks not yet picked up by agents
+	Stop()
+
 	TasksOfType(tasks.Options) []Task
 	HasTaskErrors() bool
 
@@ -31,9 +34,17 @@ var _ Incident = IncidentImpl{}
 type Task interface {
 	Stop()
 
+	// Adjust applies new options to a running task
+	// (only Control Net and Stress tasks can be adjusted)
+	Adjust(tasks.Options)
+
 	Instance() Instance
 	Error() string
 
+	// ErrorDetails returns nil if task did not fail
+	// or agent does not report structured errors
+	ErrorDetails() *tasks.TaskError
+
 	ExecutionStartedAt() time.Time
 	ExecutionCompletedAt() *time.Time
 }
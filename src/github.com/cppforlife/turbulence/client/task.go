@@ -22,6 +22,11 @@ func (t TaskImpl) Stop() {
 	panicIfErr(err, "stop task")
 }
 
+func (t TaskImpl) Adjust(opts tasks.Options) {
+	err := t.client.AdjustTask(t.id, opts)
+	panicIfErr(err, "adjust task")
+}
+
 func (t TaskImpl) Instance() Instance {
 	resp := t.fetch()
 	return Instance{
@@ -36,6 +41,10 @@ func (t TaskImpl) Error() string {
 	return t.fetch().Error
 }
 
+func (t TaskImpl) ErrorDetails() *tasks.TaskError {
+	return t.fetch().ErrorDetails
+}
+
 func (t TaskImpl) ExecutionStartedAt() time.Time {
 	t1, err := time.Parse(time.RFC3339, t.fetch().ExecutionStartedAt)
 	panicIfErr(err, "parse incident's execution start time")
@@ -84,3 +93,17 @@ func (c Client) StopTask(id string) error {
 
 	return nil
 }
+
+func (c Client) AdjustTask(id string, opts tasks.Options) error {
+	var resp interface{}
+
+	path := fmt.Sprintf("/api/v1/agent_tasks/%s/state", id)
+	req := tasks.StateRequest{Options: tasks.OptionsSlice{opts}}
+
+	err := c.clientRequest.Post(path, req, &resp)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Adjusting task")
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	mart "github.com/go-martini/martini"
+	martrend "github.com/martini-contrib/render"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// AgentIdentity is an agent ID proven by a client certificate;
+// empty if agents authenticate with shared credentials
+type AgentIdentity string
+
+// AgentIdentityHandler maps agent identity based on common name of a verified client certificate
+func AgentIdentityHandler(c mart.Context, req *http.Request) {
+	var identity AgentIdentity
+
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		identity = AgentIdentity(req.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}
+
+	c.Map(identity)
+}
+
+func (i AgentIdentity) IsAgent(agentID string) bool {
+	return len(i) == 0 || string(i) == agentID
+}
+
+// checkAgent renders an error unless agent is acting on its own behalf
+func (i AgentIdentity) checkAgent(r martrend.Render, agentID string) bool {
+	if !i.IsAgent(agentID) {
+		r.JSON(403, map[string]string{"error": fmt.Sprintf("Agent '%s' cannot act as agent '%s'", i, agentID)})
+		return false
+	}
+
+	return true
+}
+
+// checkTask renders an error unless task was consumed by the agent
+func (i AgentIdentity) checkTask(r martrend.Render, tasksRepo tasks.Repo, taskID string) bool {
+	if len(i) == 0 {
+		return true
+	}
+
+	agentID, found, err := tasksRepo.FetchAgentID(taskID)
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return false
+	}
+
+	if !found || agentID != string(i) {
+		r.JSON(403, map[string]string{"error": fmt.Sprintf("Agent '%s' is not executing task '%s'", i, taskID)})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	mart "github.com/go-martini/martini"
+	martrend "github.com/martini-contrib/render"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/director"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+type AgentsController struct {
+	agentsRepo agentreg.Repo
+	tasksRepo  tasks.Repo
+	director   director.Director
+
+	indexTmpl string
+	errorTmpl string
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewAgentsController(
+	agentsRepo agentreg.Repo,
+	tasksRepo tasks.Repo,
+	director director.Director,
+	logger boshlog.Logger,
+) AgentsController {
+	return AgentsController{
+		agentsRepo: agentsRepo,
+		tasksRepo:  tasksRepo,
+		director:   director,
+
+		indexTmpl: "agents/index",
+		errorTmpl: "error",
+
+		logTag: "AgentsController",
+		logger: logger,
+	}
+}
+
+type AgentsPage struct {
+	Agents agentreg.Responses
+}
+
+func (c AgentsController) Index(r martrend.Render) {
+	resps, err := c.responses()
+	if err != nil {
+		r.HTML(500, c.errorTmpl, err)
+		return
+	}
+
+	r.HTML(200, c.indexTmpl, AgentsPage{resps})
+}
+
+func (c AgentsController) APIIndex(r martrend.Render) {
+	resps, err := c.responses()
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	r.JSON(200, resps)
+}
+
+func (c AgentsController) APIRegister(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
+	if !identity.checkAgent(r, params["id"]) {
+		return
+	}
+
+	var caps agentreg.Capabilities
+
+	err := json.NewDecoder(req.Body).Decode(&caps)
+	if err != nil {
+		r.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = c.agentsRepo.Register(params["id"], caps)
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	r.JSON(200, nil)
+}
+
+func (c AgentsController) responses() (agentreg.Responses, error) {
+	instances, err := c.director.AllInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := c.agentsRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	activeTasks := map[string][]tasks.Task{}
+
+	for _, agent := range agents {
+		activeTasks[agent.ID], err = c.tasksRepo.ListActive(agent.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return agentreg.NewResponses(instances, agents, activeTasks), nil
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/director"
 	"github.com/cppforlife/turbulence/incident"
 	"github.com/cppforlife/turbulence/scheduledinc"
 	"github.com/cppforlife/turbulence/tasks"
@@ -12,6 +14,7 @@ type FactoryRepos interface {
 	IncidentsRepo() incident.Repo
 	ScheduledIncidentsRepo() scheduledinc.Repo
 	TasksRepo() tasks.Repo
+	AgentsRepo() agentreg.Repo
 }
 
 type Factory struct {
@@ -19,18 +22,23 @@ type Factory struct {
 	IncidentsController          IncidentsController
 	ScheduledIncidentsController ScheduledIncidentsController
 	TasksController              TasksController
+	AgentsController             AgentsController
 }
 
-func NewFactory(r FactoryRepos, logger boshlog.Logger) (Factory, error) {
+func NewFactory(r FactoryRepos, dir director.Director, logger boshlog.Logger) (Factory, error) {
 	isRepo := r.IncidentsRepo()
 	sisRepo := r.ScheduledIncidentsRepo()
 	arRepo := r.TasksRepo()
+	agRepo := r.AgentsRepo()
+
+	preflight := incident.NewPreflight(dir, agRepo, logger)
 
 	factory := Factory{
 		HomeController:               NewHomeController(isRepo, sisRepo, logger),
-		IncidentsController:          NewIncidentsController(isRepo, logger),
+		IncidentsController:          NewIncidentsController(isRepo, preflight, logger),
 		ScheduledIncidentsController: NewScheduledIncidentsController(sisRepo, logger),
-		TasksController:              NewTasksController(arRepo, logger),
+		TasksController:              NewTasksController(arRepo, agRepo, logger),
+		AgentsController:             NewAgentsController(agRepo, arRepo, dir, logger),
 	}
 
 	return factory, nil
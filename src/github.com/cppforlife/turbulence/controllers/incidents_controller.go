@@ -13,6 +13,7 @@ import (
 
 type IncidentsController struct {
 	incidentsRepo incident.Repo
+	preflight     incident.Preflight
 
 	indexTmpl string
 	showTmpl  string
@@ -24,10 +25,12 @@ type IncidentsController struct {
 
 func NewIncidentsController(
 	incidentsRepo incident.Repo,
+	preflight incident.Preflight,
 	logger boshlog.Logger,
 ) IncidentsController {
 	return IncidentsController{
 		incidentsRepo: incidentsRepo,
+		preflight:     preflight,
 
 		indexTmpl: "incidents/index",
 		showTmpl:  "incidents/show",
@@ -71,17 +74,36 @@ func (c IncidentsController) APICreate(req *http.Request, r martrend.Render) {
 
 	err := json.NewDecoder(req.Body).Decode(&incidentReq)
 	if err != nil {
-		r.JSON(500, map[string]string{"error": err.Error()})
+		r.JSON(400, map[string]string{"error": err.Error()})
 		return
 	}
 
+	err = incidentReq.Validate()
+	if err != nil {
+		renderInvalidRequest(r, err)
+		return
+	}
+
+	warnings, err := c.preflight.Check(incidentReq)
+	if err != nil {
+		renderInvalidRequest(r, err)
+		return
+	}
+
+	for _, warning := range warnings {
+		c.logger.Info(c.logTag, "Preflight warning: %s", warning)
+	}
+
 	incid, err := c.incidentsRepo.Create(incidentReq)
 	if err != nil {
 		r.JSON(500, map[string]string{"error": err.Error()})
 		return
 	}
 
-	r.JSON(200, incident.NewResponse(incid))
+	resp := incident.NewResponse(incid)
+	resp.Warnings = warnings
+
+	r.JSON(200, resp)
 }
 
 func (c IncidentsController) Read(req *http.Request, r martrend.Render, params mart.Params) {
@@ -113,3 +135,45 @@ func (c IncidentsController) APIRead(req *http.Request, r martrend.Render, param
 
 	r.JSON(200, incident.NewResponse(incid))
 }
+
+func (c IncidentsController) Stop(req *http.Request, r martrend.Render, params mart.Params) {
+	incid, err := c.incidentsRepo.Read(params["id"])
+	if err != nil {
+		code := 500
+		if _, ok := err.(incident.IncidentNotFoundError); ok {
+			code = 404
+		}
+
+		r.HTML(code, c.errorTmpl, err)
+		return
+	}
+
+	err = incid.Stop()
+	if err != nil {
+		r.HTML(500, c.errorTmpl, err)
+		return
+	}
+
+	r.Redirect("/incidents/"+incid.ID(), 303)
+}
+
+func (c IncidentsController) APIStop(req *http.Request, r martrend.Render, params mart.Params) {
+	incid, err := c.incidentsRepo.Read(params["id"])
+	if err != nil {
+		code := 500
+		if _, ok := err.(incident.IncidentNotFoundError); ok {
+			code = 404
+		}
+
+		r.JSON(code, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = incid.Stop()
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	r.JSON(200, incident.NewResponse(incid))
+}
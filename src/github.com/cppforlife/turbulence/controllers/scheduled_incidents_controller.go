@@ -71,7 +71,13 @@ func (c ScheduledIncidentsController) APICreate(req *http.Request, r martrend.Re
 
 	err := json.NewDecoder(req.Body).Decode(&siReq)
 	if err != nil {
-		r.JSON(500, map[string]string{"error": err.Error()})
+		r.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = siReq.Validate()
+	if err != nil {
+		renderInvalidRequest(r, err)
 		return
 	}
 
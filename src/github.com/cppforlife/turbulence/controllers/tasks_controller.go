@@ -3,16 +3,22 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	mart "github.com/go-martini/martini"
 	martrend "github.com/martini-contrib/render"
 
+	"github.com/cppforlife/turbulence/agentreg"
 	"github.com/cppforlife/turbulence/tasks"
 )
 
+// Agents are expected to use longer client timeout
+const AgentPollTimeout = 30 * time.Second
+
 type TasksController struct {
-	tasksRepo tasks.Repo
+	tasksRepo  tasks.Repo
+	agentsRepo agentreg.Repo
 
 	logTag string
 	logger boshlog.Logger
@@ -20,29 +26,98 @@ type TasksController struct {
 
 func NewTasksController(
 	tasksRepo tasks.Repo,
+	agentsRepo agentreg.Repo,
 	logger boshlog.Logger,
 ) TasksController {
 	return TasksController{
-		tasksRepo: tasksRepo,
+		tasksRepo:  tasksRepo,
+		agentsRepo: agentsRepo,
 
 		logTag: "TasksController",
 		logger: logger,
 	}
 }
 
-func (c TasksController) APIConsume(req *http.Request, r martrend.Render, params mart.Params) {
+func (c TasksController) APIConsume(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
 	// agentID := req.URL.Query().Get("agent_id") todo use query string
 
-	tasks, err := c.tasksRepo.Consume(params["id"])
+	if !identity.checkAgent(r, params["id"]) {
+		return
+	}
+
+	err := c.agentsRepo.Seen(params["id"])
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	consumedTasks, err := c.tasksRepo.Consume(params["id"])
 	if err != nil {
 		r.JSON(500, map[string]string{"error": err.Error()})
 		return
 	}
 
-	r.JSON(200, tasks)
+	supportedTasks := []tasks.Task{}
+
+	for _, task := range consumedTasks {
+		// Agents that use this endpoint do not know about dry run and would execute tasks for real
+		if task.DryRun {
+			taskErr := tasks.TaskError{Category: tasks.ErrorCategoryValidation, Message: "Agent does not support dry run"}
+
+			err := c.tasksRepo.Update(task.ID, tasks.ResultRequest{Error: taskErr.Error(), TaskError: &taskErr})
+			if err != nil {
+				c.logger.Error(c.logTag, "Failed to reject dry run task '%s': %s", task.ID, err)
+			}
+
+			continue
+		}
+
+		supportedTasks = append(supportedTasks, task)
+	}
+
+	r.JSON(200, supportedTasks)
 }
 
-func (c TasksController) APIReadState(req *http.Request, r martrend.Render, params mart.Params) {
+func (c TasksController) APIPoll(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
+	if !identity.checkAgent(r, params["id"]) {
+		return
+	}
+
+	var pollReq tasks.PollRequest
+
+	err := json.NewDecoder(req.Body).Decode(&pollReq)
+	if err != nil {
+		r.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = c.agentsRepo.Seen(params["id"])
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	resp, err := c.tasksRepo.Poll(params["id"], pollReq, AgentPollTimeout)
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Agent was connected while waiting
+	err = c.agentsRepo.Seen(params["id"])
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	r.JSON(200, resp)
+}
+
+func (c TasksController) APIReadState(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
+	if !identity.checkTask(r, c.tasksRepo, params["id"]) {
+		return
+	}
+
 	state, err := c.tasksRepo.FetchState(params["id"])
 	if err != nil {
 		r.JSON(500, map[string]string{"error": err.Error()})
@@ -63,14 +138,22 @@ func (c TasksController) APIUpdateState(req *http.Request, r martrend.Render, pa
 
 	err = c.tasksRepo.UpdateState(params["id"], stateReq)
 	if err != nil {
-		r.JSON(500, map[string]string{"error": err.Error()})
+		if _, ok := err.(tasks.ValidationError); ok {
+			renderInvalidRequest(r, err)
+		} else {
+			r.JSON(500, map[string]string{"error": err.Error()})
+		}
 		return
 	}
 
 	r.JSON(200, nil)
 }
 
-func (c TasksController) APIUpdate(req *http.Request, r martrend.Render, params mart.Params) {
+func (c TasksController) APIUpdate(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
+	if !identity.checkTask(r, c.tasksRepo, params["id"]) {
+		return
+	}
+
 	var resultReq tasks.ResultRequest
 
 	err := json.NewDecoder(req.Body).Decode(&resultReq)
@@ -87,3 +170,25 @@ func (c TasksController) APIUpdate(req *http.Request, r martrend.Render, params
 
 	r.JSON(200, nil)
 }
+
+func (c TasksController) APIUpdateProgress(req *http.Request, r martrend.Render, params mart.Params, identity AgentIdentity) {
+	if !identity.checkTask(r, c.tasksRepo, params["id"]) {
+		return
+	}
+
+	var progressReq tasks.ProgressRequest
+
+	err := json.NewDecoder(req.Body).Decode(&progressReq)
+	if err != nil {
+		r.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = c.tasksRepo.UpdateProgress(params["id"], progressReq)
+	if err != nil {
+		r.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	r.JSON(200, nil)
+}
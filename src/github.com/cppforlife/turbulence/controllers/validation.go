@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	martrend "github.com/martini-contrib/render"
+
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+func renderInvalidRequest(r martrend.Render, err error) {
+	resp := map[string]interface{}{"error": err.Error()}
+
+	if valErr, ok := err.(tasks.ValidationError); ok {
+		resp["fields"] = valErr.Fields
+	}
+
+	r.JSON(400, resp)
+}
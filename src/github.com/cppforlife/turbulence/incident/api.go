@@ -13,6 +13,48 @@ import (
 type Request struct {
 	Tasks    tasks.OptionsSlice
 	Selector selector.Request
+
+	// Agents only report what they would do without changing the system
+	DryRun bool `json:",omitempty"`
+
+	// How long agents have to pick up their tasks (default 30s)
+	DeliveryTimeout string `json:",omitempty"`
+
+	// Keep tasks queued until agents show up (e.g. after VM reboot or deploy)
+	// or Deadline passes, instead of failing after DeliveryTimeout
+	KeepQueued bool `json:",omitempty"`
+
+	// Measured from incident start (e.g. 2h); required when tasks are kept queued
+	Deadline string `json:",omitempty"`
+}
+
+func (r Request) Validate() error {
+	return tasks.MergeValidationErrors(
+		tasks.NewValidationErrorWithPrefix("Tasks", r.Tasks.Validate()),
+		r.validateDuration("DeliveryTimeout", r.DeliveryTimeout),
+		r.validateDuration("Deadline", r.Deadline),
+		r.validateDeadline(),
+	)
+}
+
+func (Request) validateDuration(field, val string) error {
+	if len(val) == 0 {
+		return nil
+	}
+
+	if dur, err := time.ParseDuration(val); err != nil || dur <= 0 {
+		return tasks.NewValidationError(field, fmt.Sprintf("must be a positive duration suffixed with s,m,h (got '%s')", val))
+	}
+
+	return nil
+}
+
+func (r Request) validateDeadline() error {
+	if r.KeepQueued && len(r.Deadline) == 0 {
+		return tasks.NewValidationError("Deadline", "must be specified when 'KeepQueued' is set")
+	}
+
+	return nil
 }
 
 type Response struct {
@@ -22,12 +64,20 @@ type Response struct {
 
 	Tasks    tasks.OptionsSlice
 	Selector selector.Request
+	DryRun   bool `json:",omitempty"`
+
+	DeliveryTimeout string `json:",omitempty"`
+	KeepQueued      bool   `json:",omitempty"`
+	Deadline        string `json:",omitempty"`
 
 	ExecutionStartedAt   string
 	ExecutionCompletedAt string
 
 	Events []reporter.EventResponse
 
+	// Only included when incident is created
+	Warnings []string `json:",omitempty"`
+
 	description string
 }
 
@@ -63,6 +113,11 @@ func NewResponse(incident Incident) Response {
 
 		Tasks:    incident.Tasks,
 		Selector: incident.Selector,
+		DryRun:   incident.DryRun,
+
+		DeliveryTimeout: incident.DeliveryTimeout,
+		KeepQueued:      incident.KeepQueued,
+		Deadline:        incident.Deadline,
 
 		ExecutionStartedAt:   incident.ExecutionStartedAt().Format(time.RFC3339),
 		ExecutionCompletedAt: completedAt,
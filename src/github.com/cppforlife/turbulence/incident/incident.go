@@ -2,8 +2,10 @@ package incident
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
 	"github.com/cppforlife/turbulence/director"
@@ -18,16 +20,25 @@ type Incident struct {
 	tasksRepo  tasks.Repo
 	updateFunc func(Incident) error
 
-	id string
+	id        string
+	createdAt time.Time
 
 	Tasks    tasks.OptionsSlice
 	Selector selector.Request
+	DryRun   bool
+
+	DeliveryTimeout string
+	KeepQueued      bool
+	Deadline        string
 
 	executionStartedAt   time.Time
 	executionCompletedAt time.Time
 
 	events *reporter.Events
 
+	// Shared by copies of the incident so that its execution notices Stop
+	stopped *stopFlag
+
 	logTag string
 	logger boshlog.Logger
 }
@@ -38,6 +49,52 @@ func (i Incident) Events() *reporter.Events { return i.events }
 func (i Incident) ExecutionStartedAt() time.Time   { return i.executionStartedAt }
 func (i Incident) ExecutionCompletedAt() time.Time { return i.executionCompletedAt }
 
+// Stop cancels tasks that agents have not picked up yet and asks agents to stop running tasks;
+// tasks that are not queued yet (e.g. instances are still being selected) are not queued at all.
+// Kill tasks cannot be stopped once they are executed via the Director
+func (i Incident) Stop() error {
+	i.logger.Debug(i.logTag, "Stopping incident '%s'", i.id)
+
+	i.stopped.Set()
+
+	for _, event := range i.events.Events() {
+		if !event.IsAction() || len(event.ID) == 0 || !event.ExecutionCompletedAt.IsZero() {
+			continue
+		}
+
+		err := i.stopTask(event.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i Incident) stopTask(taskID string) error {
+	// Cancel before stopping so that tasks cannot be picked up in between
+	err := i.tasksRepo.Cancel(taskID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Cancelling task '%s'", taskID)
+	}
+
+	err = i.tasksRepo.UpdateState(taskID, tasks.StateRequest{Stop: true})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Stopping task '%s'", taskID)
+	}
+
+	return nil
+}
+
+func (i Incident) hasIncompleteEvents() bool {
+	for _, event := range i.events.Events() {
+		if event.ExecutionCompletedAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
 func (i Incident) HasKillTask() bool {
 	for _, task := range i.Tasks {
 		if _, ok := task.(tasks.KillOptions); ok {
@@ -58,7 +115,7 @@ func (i Incident) TaskTypes() []string {
 }
 
 func (i Incident) ShortDescription() (string, error) {
-	b, err := json.Marshal(Request{i.Tasks, i.Selector})
+	b, err := json.Marshal(i.request())
 	if err != nil {
 		return "", err
 	}
@@ -67,10 +124,56 @@ func (i Incident) ShortDescription() (string, error) {
 }
 
 func (i Incident) Description() (string, error) {
-	b, err := json.MarshalIndent(Request{i.Tasks, i.Selector}, "", "    ")
+	b, err := json.MarshalIndent(i.request(), "", "    ")
 	if err != nil {
 		return "", err
 	}
 
 	return string(b), nil
 }
+
+func (i Incident) request() Request {
+	return Request{
+		Tasks:    i.Tasks,
+		Selector: i.Selector,
+		DryRun:   i.DryRun,
+
+		DeliveryTimeout: i.DeliveryTimeout,
+		KeepQueued:      i.KeepQueued,
+		Deadline:        i.Deadline,
+	}
+}
+
+// deliveryTimeout returns how long agents have to pick up tasks queued now
+func (i Incident) deliveryTimeout() time.Duration {
+	// Durations are validated when incident is created
+	if i.KeepQueued {
+		deadline, _ := time.ParseDuration(i.Deadline)
+		return i.executionStartedAt.Add(deadline).Sub(time.Now().UTC())
+	}
+
+	if len(i.DeliveryTimeout) > 0 {
+		timeout, _ := time.ParseDuration(i.DeliveryTimeout)
+		return timeout
+	}
+
+	return tasks.DefaultDeliveryTimeout
+}
+
+type stopFlag struct {
+	stopped bool
+	lock    sync.RWMutex
+}
+
+func (f *stopFlag) Set() {
+	f.lock.Lock()
+	f.stopped = true
+	f.lock.Unlock()
+}
+
+func (f *stopFlag) IsSet() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.stopped
+}
@@ -1,7 +1,7 @@
 package incident
 
 import (
-	"errors"
+	"fmt"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -26,9 +26,33 @@ func (i Incident) Execute() error {
 
 	i.executeTasks()
 
+	return i.completeExecution()
+}
+
+// resume waits for events that were not completed before API server restarted
+func (i Incident) resume() {
+	i.logger.Debug(i.logTag, "Resuming incident '%s'", i.id)
+
+	for _, event := range i.events.Events() {
+		if event.ExecutionCompletedAt.IsZero() {
+			go i.waitForTask(event)
+		}
+	}
+
+	err := i.completeExecution()
+	if err != nil {
+		i.logger.Error(i.logTag, "Resumed incident '%s' failed: %s", i.id, err.Error())
+	}
+}
+
+func (i Incident) completeExecution() error {
 	// Serialize updates to the incident and events
 	for r := range i.events.Results() {
-		r.Event.MarkError(r.Error)
+		if r.Progress != nil {
+			r.Event.MarkProgress(*r.Progress)
+		} else {
+			r.Event.MarkError(r.Error)
+		}
 		i.update()
 	}
 
@@ -43,6 +67,12 @@ func (i Incident) Execute() error {
 	return i.events.FirstError()
 }
 
+// errStoppedBeforeQueued fails events of tasks that were not queued since incident was stopped
+var errStoppedBeforeQueued = tubtasks.TaskError{
+	Category: tubtasks.ErrorCategoryCancelled,
+	Message:  "Incident was stopped before tasks were queued",
+}
+
 func (i Incident) executeTasks() {
 	event := i.events.Add(reporter.Event{Type: reporter.EventTypeFind})
 	instances, err := i.director.AllInstances()
@@ -100,6 +130,7 @@ func (i Incident) executeNonKillTasks(eventTpl reporter.Event, instance director
 		task := tubtasks.Task{
 			ID:       event.ID,
 			Optionss: []tubtasks.Options{taskOpts}, // todo change to singular
+			DryRun:   i.DryRun,
 		}
 
 		tasks = append(tasks, task)
@@ -107,37 +138,123 @@ func (i Incident) executeNonKillTasks(eventTpl reporter.Event, instance director
 	}
 
 	go func() {
-		err := i.tasksRepo.QueueAndWait(instance.AgentID(), tasks)
+		// Checked after events were added so that Stop either sees events or is noticed here
+		if i.stopped.IsSet() {
+			for _, event := range events {
+				i.events.RegisterResult(reporter.EventResult{Event: event, Error: errStoppedBeforeQueued})
+			}
+			return
+		}
+
+		for _, event := range events {
+			i.events.RegisterProgress(event, reporter.EventProgress{State: reporter.EventStatePendingDelivery})
+		}
+
+		err := i.tasksRepo.QueueAndWait(instance.AgentID(), tasks, i.deliveryTimeout())
 		if err != nil {
 			i.logger.Error(i.logTag, "Failed to queue/wait for agent '%s': %s", instance.AgentID(), err.Error())
 
 			for _, event := range events {
-				i.events.RegisterResult(reporter.EventResult{event, err})
+				i.events.RegisterResult(reporter.EventResult{Event: event, Error: err})
 			}
 
 			return
 		}
 
 		for _, event := range events {
-			go func() {
-				req, err := i.tasksRepo.Wait(event.ID)
-				if err == nil && len(req.Error) > 0 {
-					err = errors.New(req.Error) // todo better error reporting?
+			i.events.RegisterProgress(event, reporter.EventProgress{State: reporter.EventStateDelivered})
+			go i.waitForTask(event)
+		}
+
+		// Stop may have been requested right before tasks were queued
+		if i.stopped.IsSet() {
+			for _, event := range events {
+				err := i.stopTask(event.ID)
+				if err != nil {
+					i.logger.Error(i.logTag, "Failed to stop task '%s': %s", event.ID, err.Error())
 				}
-				i.events.RegisterResult(reporter.EventResult{event, err})
-			}()
+			}
 		}
 	}()
 }
 
+func (i Incident) waitForTask(event *reporter.Event) {
+	doneCh := make(chan struct{})
+	watchDoneCh := make(chan struct{})
+
+	go func() {
+		i.watchTaskProgress(event, doneCh)
+		close(watchDoneCh)
+	}()
+
+	req, err := i.tasksRepo.Wait(event.ID)
+	if err == nil {
+		err = req.Err()
+	}
+
+	// Progress must not be registered after the result
+	close(doneCh)
+	<-watchDoneCh
+
+	i.events.RegisterResult(reporter.EventResult{Event: event, Error: err})
+}
+
+func (i Incident) watchTaskProgress(event *reporter.Event, doneCh chan struct{}) {
+	var lastHeartbeatAt time.Time
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		var done bool
+
+		select {
+		case <-ticker.C:
+		case <-doneCh:
+			done = true // pick up progress sent right before the result
+		}
+
+		progress, err := i.tasksRepo.FetchProgress(event.ID)
+		if err != nil {
+			i.logger.Error(i.logTag, "Failed to fetch task '%s' progress: %s", event.ID, err.Error())
+		} else if !progress.HeartbeatAt.Equal(lastHeartbeatAt) {
+			lastHeartbeatAt = progress.HeartbeatAt
+
+			i.events.RegisterProgress(event, reporter.EventProgress{
+				State:       progress.State,
+				HeartbeatAt: progress.HeartbeatAt,
+				Output:      progress.Output,
+			})
+		}
+
+		if done {
+			return
+		}
+	}
+}
+
 func (i Incident) killInstance(eventTpl reporter.Event, instance director.Instance) {
 	eventTpl.Type = tubtasks.OptionsType(tubtasks.KillOptions{})
 
 	event := i.events.Add(eventTpl)
 
 	go func() {
+		if i.stopped.IsSet() {
+			i.events.RegisterResult(reporter.EventResult{Event: event, Error: errStoppedBeforeQueued})
+			return
+		}
+
+		if i.DryRun {
+			output := fmt.Sprintf("Would delete VM of instance '%s/%s' (deployment '%s')\n",
+				instance.Group(), instance.ID(), instance.Deployment())
+
+			i.events.RegisterProgress(event, reporter.EventProgress{HeartbeatAt: time.Now().UTC(), Output: output})
+			i.events.RegisterResult(reporter.EventResult{Event: event})
+			return
+		}
+
 		err := instance.DeleteVM()
-		i.events.RegisterResult(reporter.EventResult{event, err})
+		i.events.RegisterResult(reporter.EventResult{Event: event, Error: err})
 	}()
 }
 
@@ -0,0 +1,90 @@
+package incident_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/director"
+	. "github.com/cppforlife/turbulence/incident"
+	"github.com/cppforlife/turbulence/incident/reporter"
+	"github.com/cppforlife/turbulence/storage"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Incident", func() {
+	var (
+		fakeDirector *blockingDirector
+		tasksRepo    tasks.Repo
+		repo         Repo
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		store := storage.NewMemoryStore()
+
+		fakeDirector = &blockingDirector{called: make(chan struct{}), release: make(chan struct{})}
+
+		tasksRepo, err = tasks.NewRepo(tasks.DefaultRetention, store, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		repo, err = NewRepo(fakeuuid.NewFakeGenerator(), noopNotifier{}, reporter.NewLogger(logger), fakeDirector, tasksRepo, store, logger)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not queue tasks when stopped before they are delivered", func() {
+		incid, err := repo.Create(Request{Tasks: tasks.OptionsSlice{tasks.NoopOptions{}}})
+		Expect(err).ToNot(HaveOccurred())
+
+		executed := make(chan error, 1)
+
+		go func() { executed <- incid.Execute() }()
+
+		// Stop while instances are still being found
+		Eventually(fakeDirector.called).Should(BeClosed())
+		Expect(incid.Stop()).To(Succeed())
+		close(fakeDirector.release)
+
+		var execErr error
+		Eventually(executed).Should(Receive(&execErr))
+
+		events := incid.Events().Events()
+		Expect(events).To(HaveLen(3))
+		Expect(events[2].Type).To(Equal("Noop"))
+		Expect(events[2].TaskError()).ToNot(BeNil())
+		Expect(events[2].TaskError().Category).To(Equal(tasks.ErrorCategoryCancelled))
+		Expect(execErr).To(Equal(events[2].Error))
+
+		consumed, err := tasksRepo.Consume("agent1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(consumed).To(BeEmpty())
+	})
+})
+
+// blockingDirector returns single instance once released
+type blockingDirector struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDirector) AllInstances() ([]director.Instance, error) {
+	close(d.called)
+	<-d.release
+	return []director.Instance{fakeInstance{}}, nil
+}
+
+func (d *blockingDirector) SubmitEvent(director.EventOpts) error { return nil }
+
+type fakeInstance struct{}
+
+func (fakeInstance) ID() string         { return "inst1" }
+func (fakeInstance) Group() string      { return "db" }
+func (fakeInstance) Deployment() string { return "dep" }
+func (fakeInstance) AZ() string         { return "z1" }
+func (fakeInstance) AgentID() string    { return "agent1" }
+func (fakeInstance) HasVM() bool        { return true }
+func (fakeInstance) DeleteVM() error    { return nil }
@@ -0,0 +1,97 @@
+package incident
+
+import (
+	"fmt"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/director"
+	"github.com/cppforlife/turbulence/incident/selector"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+// Preflight checks that agents on instances that may be selected by an incident
+// are able to run its tasks before any of the instances are affected.
+type Preflight struct {
+	director   director.Director
+	agentsRepo agentreg.Repo
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewPreflight(director director.Director, agentsRepo agentreg.Repo, logger boshlog.Logger) Preflight {
+	return Preflight{
+		director:   director,
+		agentsRepo: agentsRepo,
+
+		logTag: "incident.Preflight",
+		logger: logger,
+	}
+}
+
+// Check returns warnings for instances that could not be checked
+// and validation error for instances that are known to be unable to run tasks.
+func (p Preflight) Check(req Request) ([]string, error) {
+	if (Incident{Tasks: req.Tasks}).HasKillTask() {
+		return nil, nil // only VMs are deleted
+	}
+
+	instances, err := p.director.AllInstances()
+	if err != nil {
+		p.logger.Error(p.logTag, "Failed to find instances: %s", err)
+		return []string{fmt.Sprintf("Skipped preflight checks since instances could not be found: %s", err)}, nil
+	}
+
+	var candidates []selector.Instance
+
+	for _, inst := range instances {
+		candidates = append(candidates, inst)
+	}
+
+	// Limits are random hence check all instances that could be selected
+	candidates, err = req.Selector.WithoutLimits().AsSelector().Select(candidates)
+	if err != nil {
+		return nil, tasks.NewValidationErrorWithPrefix("Selector", err)
+	}
+
+	var warnings []string
+	var errs []error
+
+	for _, cand := range candidates {
+		inst := cand.(director.Instance)
+		instDesc := fmt.Sprintf("instance '%s/%s' (deployment '%s')", inst.Group(), inst.ID(), inst.Deployment())
+
+		agent, found, err := p.agentsRepo.Find(inst.AgentID())
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			if req.DryRun {
+				msg := fmt.Sprintf("cannot be checked on %s since its agent has not registered with the API", instDesc)
+				errs = append(errs, tasks.NewValidationError("DryRun", msg))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("Agent on %s has not registered with the API", instDesc))
+			}
+			continue
+		}
+
+		if req.DryRun && !agent.Capabilities.DryRun {
+			msg := fmt.Sprintf("is not supported by agent (version '%s') on %s", agent.Capabilities.Version, instDesc)
+			errs = append(errs, tasks.NewValidationError("DryRun", msg))
+			continue
+		}
+
+		for i, taskOpts := range req.Tasks {
+			err := agent.Capabilities.CanRun(taskOpts)
+			if err != nil {
+				msg := fmt.Sprintf("cannot run on %s: %s", instDesc, err)
+				errs = append(errs, tasks.NewValidationError(fmt.Sprintf("Tasks[%d]", i), msg))
+			}
+		}
+	}
+
+	return warnings, tasks.MergeValidationErrors(errs...)
+}
@@ -0,0 +1,42 @@
+package incident
+
+import (
+	"time"
+
+	"github.com/cppforlife/turbulence/incident/reporter"
+)
+
+const storeCollection = "incidents"
+
+// record is how incident is persisted
+type record struct {
+	ID        string
+	CreatedAt time.Time
+
+	Request Request
+
+	ExecutionStartedAt   time.Time
+	ExecutionCompletedAt time.Time
+
+	Events []reporter.EventRecord
+}
+
+func (i Incident) record() record {
+	return record{
+		ID:        i.id,
+		CreatedAt: i.createdAt,
+
+		Request: i.request(),
+
+		ExecutionStartedAt:   i.executionStartedAt,
+		ExecutionCompletedAt: i.executionCompletedAt,
+
+		Events: i.events.Records(),
+	}
+}
+
+type recordsByCreatedAt []record
+
+func (s recordsByCreatedAt) Len() int           { return len(s) }
+func (s recordsByCreatedAt) Less(i, j int) bool { return s[i].CreatedAt.Before(s[j].CreatedAt) }
+func (s recordsByCreatedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
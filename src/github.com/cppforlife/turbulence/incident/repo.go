@@ -1,8 +1,11 @@
 package incident
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/cppforlife/turbulence/director"
 	"github.com/cppforlife/turbulence/incident/reporter"
+	"github.com/cppforlife/turbulence/storage"
 	"github.com/cppforlife/turbulence/tasks"
 )
 
@@ -27,6 +31,7 @@ type repo struct {
 	reporter  reporter.Reporter
 	director  director.Director
 	tasksRepo tasks.Repo
+	store     storage.Store
 
 	incidents     []Incident
 	incidentsLock sync.RWMutex
@@ -40,16 +45,91 @@ func NewRepo(
 	reporter reporter.Reporter,
 	director director.Director,
 	tasksRepo tasks.Repo,
+	store storage.Store,
 	logger boshlog.Logger,
-) Repo {
-	return &repo{
+) (Repo, error) {
+	r := &repo{
 		uuidGen:   uuidGen,
 		notifier:  notifier,
 		reporter:  reporter,
 		director:  director,
 		tasksRepo: tasksRepo,
+		store:     store,
 		logger:    logger,
 	}
+
+	return r, r.load()
+}
+
+// load restores incidents saved before API server restarted;
+// incidents that were still executing are marked as completed
+func (r *repo) load() error {
+	docs, err := r.store.List(storeCollection)
+	if err != nil {
+		return bosherr.WrapError(err, "Loading incidents")
+	}
+
+	var records []record
+
+	for _, doc := range docs {
+		var rec record
+
+		err := json.Unmarshal(doc, &rec)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling incident")
+		}
+
+		records = append(records, rec)
+	}
+
+	sort.Sort(recordsByCreatedAt(records))
+
+	interruptedAt := time.Now().UTC()
+
+	var resumed []Incident
+
+	for _, rec := range records {
+		incident := r.newIncident(rec.ID, rec.Request)
+		incident.createdAt = rec.CreatedAt
+		incident.executionStartedAt = rec.ExecutionStartedAt
+		incident.executionCompletedAt = rec.ExecutionCompletedAt
+		incident.events = reporter.NewEventsFromRecords(
+			r.uuidGen, r.reporter, rec.ID, rec.Events, interruptedAt, r.isTaskActive, r.logger)
+
+		if incident.executionCompletedAt.IsZero() {
+			if incident.hasIncompleteEvents() {
+				resumed = append(resumed, incident)
+			} else {
+				incident.executionCompletedAt = interruptedAt
+
+				err := r.save(incident)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		r.incidents = append(r.incidents, incident)
+	}
+
+	// Resumed incidents update themselves in the list
+	for _, incident := range resumed {
+		go incident.resume()
+	}
+
+	return nil
+}
+
+// isTaskActive returns true if agent may still be executing event's task
+// (active tasks are restored by tasks repo)
+func (r *repo) isTaskActive(rec reporter.EventRecord) bool {
+	if len(rec.ID) == 0 {
+		return false
+	}
+
+	_, found, err := r.tasksRepo.FetchAgentID(rec.ID)
+
+	return err == nil && found
 }
 
 func (r *repo) ListAll() ([]Incident, error) {
@@ -68,7 +148,26 @@ func (r *repo) Create(req Request) (Incident, error) {
 		return Incident{}, bosherr.WrapError(err, "Generating incident ID")
 	}
 
-	incident := Incident{
+	incident := r.newIncident(id, req)
+	incident.createdAt = time.Now().UTC()
+
+	err = r.save(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+
+	r.incidentsLock.Lock()
+	r.incidents = append(r.incidents, incident)
+	r.incidentsLock.Unlock()
+
+	// notified after incidents were unlocked
+	go r.notifier.IncidentWasCreated(incident)
+
+	return incident, nil
+}
+
+func (r *repo) newIncident(id string, req Request) Incident {
+	return Incident{
 		director:   r.director,
 		reporter:   r.reporter,
 		tasksRepo:  r.tasksRepo,
@@ -78,21 +177,18 @@ func (r *repo) Create(req Request) (Incident, error) {
 
 		Tasks:    req.Tasks,
 		Selector: req.Selector,
+		DryRun:   req.DryRun,
+
+		DeliveryTimeout: req.DeliveryTimeout,
+		KeepQueued:      req.KeepQueued,
+		Deadline:        req.Deadline,
 
-		events: reporter.NewEvents(r.uuidGen, r.reporter, id, r.logger),
+		events:  reporter.NewEvents(r.uuidGen, r.reporter, id, r.logger),
+		stopped: &stopFlag{},
 
 		logTag: "incident.Incident",
 		logger: r.logger,
 	}
-
-	r.incidentsLock.Lock()
-	r.incidents = append(r.incidents, incident)
-	r.incidentsLock.Unlock()
-
-	// notified after incidents were unlocked
-	go r.notifier.IncidentWasCreated(incident)
-
-	return incident, nil
 }
 
 func (r *repo) Read(id string) (Incident, error) {
@@ -119,5 +215,14 @@ func (r *repo) update(updatedIncident Incident) error {
 		}
 	}
 
+	return r.save(updatedIncident)
+}
+
+func (r *repo) save(incident Incident) error {
+	err := r.store.Save(storeCollection, incident.ID(), incident.record())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Saving incident '%s'", incident.ID())
+	}
+
 	return nil
 }
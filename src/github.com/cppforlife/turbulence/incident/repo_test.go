@@ -0,0 +1,133 @@
+package incident_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/incident"
+	"github.com/cppforlife/turbulence/incident/reporter"
+	"github.com/cppforlife/turbulence/storage"
+	"github.com/cppforlife/turbulence/tasks"
+)
+
+type noopNotifier struct{}
+
+func (noopNotifier) IncidentWasCreated(Incident) {}
+
+var _ = Describe("Repo", func() {
+	var (
+		dir       string
+		store     storage.Store
+		uuidGen   *fakeuuid.FakeGenerator
+		tasksRepo tasks.Repo
+		newRepo   func() Repo
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		dir, err = ioutil.TempDir("", "turbulence-incidents")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		store = storage.NewFileStore(dir, boshsys.NewOsFileSystem(logger), logger)
+		uuidGen = fakeuuid.NewFakeGenerator()
+
+		tasksRepo, err = tasks.NewRepo(tasks.DefaultRetention, store, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		newRepo = func() Repo {
+			repo, err := NewRepo(uuidGen, noopNotifier{}, reporter.NewLogger(logger), nil, tasksRepo, store, logger)
+			Expect(err).ToNot(HaveOccurred())
+			return repo
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("reloads incidents in order of creation and marks unfinished ones as completed", func() {
+		repo := newRepo()
+
+		for _, id := range []string{"inc1", "inc2"} {
+			uuidGen.GeneratedUUID = id
+
+			_, err := repo.Create(Request{Tasks: tasks.OptionsSlice{tasks.NoopOptions{}}, KeepQueued: true, Deadline: "1h"})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		incidents, err := newRepo().ListAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(incidents).To(HaveLen(2))
+
+		Expect(incidents[0].ID()).To(Equal("inc2"))
+		Expect(incidents[0].Tasks).To(Equal(tasks.OptionsSlice{tasks.NoopOptions{Type: "Noop"}}))
+		Expect(incidents[0].KeepQueued).To(BeTrue())
+		Expect(incidents[0].Deadline).To(Equal("1h"))
+		Expect(incidents[0].ExecutionCompletedAt().IsZero()).To(BeFalse())
+
+		Expect(incidents[1].ID()).To(Equal("inc1"))
+	})
+
+	It("resumes waiting for events whose tasks agents are still executing after restart", func() {
+		startedAt := time.Now().UTC()
+
+		go tasksRepo.QueueAndWait("agent1", []tasks.Task{{ID: "ev1", Optionss: tasks.OptionsSlice{tasks.NoopOptions{}}}}, time.Minute)
+
+		Eventually(func() []tasks.Task {
+			consumed, err := tasksRepo.Consume("agent1")
+			Expect(err).ToNot(HaveOccurred())
+			return consumed
+		}).Should(HaveLen(1))
+
+		err := store.Save("incidents", "inc1", map[string]interface{}{
+			"ID":                 "inc1",
+			"Request":            Request{Tasks: tasks.OptionsSlice{tasks.NoopOptions{}}},
+			"ExecutionStartedAt": startedAt,
+			"Events": []reporter.EventRecord{
+				{ID: "ev1", Type: "Noop", ExecutionStartedAt: startedAt},
+				{ID: "ev2", Type: "Noop", ExecutionStartedAt: startedAt},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		// Restart API server
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		tasksRepo, err = tasks.NewRepo(tasks.DefaultRetention, store, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		repo := newRepo()
+
+		incid, err := repo.Read("inc1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(incid.ExecutionCompletedAt().IsZero()).To(BeTrue())
+
+		events := incid.Events().Events()
+		Expect(events[0].ExecutionCompletedAt.IsZero()).To(BeTrue())
+		Expect(events[1].Error).To(MatchError("API server restarted before event completed"))
+
+		Expect(incid.Stop()).To(Succeed())
+		Expect(tasksRepo.FetchState("ev1")).To(Equal(tasks.State{Stop: true}))
+
+		Expect(tasksRepo.Update("ev1", tasks.ResultRequest{})).To(Succeed())
+
+		Eventually(func() bool {
+			incid, err := repo.Read("inc1")
+			Expect(err).ToNot(HaveOccurred())
+			return incid.ExecutionCompletedAt().IsZero()
+		}).Should(BeFalse())
+
+		Expect(events[0].ExecutionCompletedAt.IsZero()).To(BeFalse())
+		Expect(events[0].Error).ToNot(HaveOccurred())
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"html/template"
 	"time"
+
+	"github.com/cppforlife/turbulence/tasks"
 )
 
 type EventResponse struct {
@@ -17,7 +19,12 @@ type EventResponse struct {
 	ExecutionStartedAt   string
 	ExecutionCompletedAt string
 
-	Error string
+	State       string `json:",omitempty"`
+	HeartbeatAt string `json:",omitempty"`
+	Output      string `json:",omitempty"`
+
+	Error        string
+	ErrorDetails *tasks.TaskError `json:",omitempty"`
 }
 
 type EventInstanceResp struct {
@@ -28,12 +35,16 @@ type EventInstanceResp struct {
 }
 
 func NewEventResponse(event *Event) EventResponse {
-	var completedAt string
+	var completedAt, heartbeatAt string
 
 	if (event.ExecutionCompletedAt != time.Time{}) {
 		completedAt = event.ExecutionCompletedAt.Format(time.RFC3339)
 	}
 
+	if (event.HeartbeatAt != time.Time{}) {
+		heartbeatAt = event.HeartbeatAt.Format(time.RFC3339)
+	}
+
 	return EventResponse{
 		event: event,
 
@@ -50,7 +61,12 @@ func NewEventResponse(event *Event) EventResponse {
 		ExecutionStartedAt:   event.ExecutionStartedAt.Format(time.RFC3339),
 		ExecutionCompletedAt: completedAt,
 
-		Error: event.ErrorStr(),
+		State:       event.State,
+		HeartbeatAt: heartbeatAt,
+		Output:      event.Output,
+
+		Error:        event.ErrorStr(),
+		ErrorDetails: event.TaskError(),
 	}
 }
 
@@ -137,6 +137,18 @@ func (r Datadog) ReportEventExecutionCompletion(incidentID string, e Event) {
 		alertType = "error"
 	}
 
+	tags := r.eventTags(incidentID, e)
+
+	if taskErr := e.TaskError(); taskErr != nil {
+		text = fmt.Sprintf("Error (%s): %s", taskErr.Category, taskErr.Message)
+
+		if len(taskErr.Cmd) > 0 {
+			text += fmt.Sprintf("\nCommand '%s' exited with %d:\n%s", taskErr.Cmd, taskErr.ExitStatus, taskErr.Output)
+		}
+
+		tags = append(tags, "error_category:"+taskErr.Category)
+	}
+
 	event := &datadog.Event{
 		Title: r.eventTitle("Completed", e),
 		Text:  text,
@@ -149,7 +161,7 @@ func (r Datadog) ReportEventExecutionCompletion(incidentID string, e Event) {
 		Aggregation: "",
 		SourceType:  "turbulence-api",
 
-		Tags:     r.eventTags(incidentID, e),
+		Tags:     tags,
 		Resource: "",
 	}
 
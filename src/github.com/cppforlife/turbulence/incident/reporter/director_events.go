@@ -74,18 +74,28 @@ func (r DirectorEvents) ReportEventExecutionCompletion(incidentID string, e Even
 	}
 
 	errorStr := ""
+	context := map[string]interface{}{"incident_id": incidentID}
 
 	if e.Error != nil {
 		errorStr = e.Error.Error()
 	}
 
+	if taskErr := e.TaskError(); taskErr != nil {
+		context["error_category"] = taskErr.Category
+
+		if len(taskErr.Cmd) > 0 {
+			context["error_cmd"] = taskErr.Cmd
+			context["error_exit_status"] = taskErr.ExitStatus
+		}
+	}
+
 	err := r.director.SubmitEvent(director.EventOpts{
 		Action:     "end",
 		ObjectType: "turbulence-event",
 		ObjectName: e.ID,
 		Deployment: e.Instance.Deployment,
 		Instance:   fmt.Sprintf("%s/%s", e.Instance.Group, e.Instance.ID),
-		Context:    map[string]interface{}{"incident_id": incidentID},
+		Context:    context,
 		Error:      errorStr,
 	})
 	r.logErr(err)
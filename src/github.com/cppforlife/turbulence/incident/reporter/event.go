@@ -3,6 +3,10 @@ package reporter
 import (
 	"sync"
 	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"github.com/cppforlife/turbulence/tasks"
 )
 
 const (
@@ -10,6 +14,12 @@ const (
 	EventTypeSelect = "Select"
 )
 
+// States of action events before agent starts reporting task progress
+const (
+	EventStatePendingDelivery = "pending_delivery"
+	EventStateDelivered       = "delivered"
+)
+
 type Event struct {
 	reporter   Reporter
 	incidentID string
@@ -24,9 +34,20 @@ type Event struct {
 	ExecutionStartedAt   time.Time
 	ExecutionCompletedAt time.Time
 
+	// Progress reported by the agent while executing the task
+	State       string
+	HeartbeatAt time.Time
+	Output      string
+
 	Error error
 }
 
+type EventProgress struct {
+	State       string
+	HeartbeatAt time.Time
+	Output      string
+}
+
 type EventInstance struct {
 	ID         string
 	Group      string
@@ -45,6 +66,20 @@ func (e *Event) ErrorStr() string {
 	return ""
 }
 
+// TaskError returns nil if event did not fail with a structured task error
+func (e *Event) TaskError() *tasks.TaskError {
+	if taskErr, ok := e.Error.(tasks.TaskError); ok {
+		return &taskErr
+	}
+	return nil
+}
+
+func (e *Event) MarkProgress(p EventProgress) {
+	e.State = p.State
+	e.HeartbeatAt = p.HeartbeatAt
+	e.Output = p.Output
+}
+
 func (e *Event) MarkError(err error) bool {
 	e.Error = err
 	e.ExecutionCompletedAt = time.Now().UTC()
@@ -56,3 +91,64 @@ func (e *Event) MarkError(err error) bool {
 
 	return err != nil
 }
+
+// EventRecord is how event is persisted
+type EventRecord struct {
+	ID   string
+	Type string
+
+	Instance EventInstance
+
+	ExecutionStartedAt   time.Time
+	ExecutionCompletedAt time.Time
+
+	State       string    `json:",omitempty"`
+	HeartbeatAt time.Time `json:",omitempty"`
+	Output      string    `json:",omitempty"`
+
+	Error     string           `json:",omitempty"`
+	TaskError *tasks.TaskError `json:",omitempty"`
+}
+
+func (e *Event) Record() EventRecord {
+	return EventRecord{
+		ID:   e.ID,
+		Type: e.Type,
+
+		Instance: e.Instance,
+
+		ExecutionStartedAt:   e.ExecutionStartedAt,
+		ExecutionCompletedAt: e.ExecutionCompletedAt,
+
+		State:       e.State,
+		HeartbeatAt: e.HeartbeatAt,
+		Output:      e.Output,
+
+		Error:     e.ErrorStr(),
+		TaskError: e.TaskError(),
+	}
+}
+
+func (r EventRecord) event() Event {
+	event := Event{
+		ID:   r.ID,
+		Type: r.Type,
+
+		Instance: r.Instance,
+
+		ExecutionStartedAt:   r.ExecutionStartedAt,
+		ExecutionCompletedAt: r.ExecutionCompletedAt,
+
+		State:       r.State,
+		HeartbeatAt: r.HeartbeatAt,
+		Output:      r.Output,
+	}
+
+	if r.TaskError != nil {
+		event.Error = *r.TaskError
+	} else if len(r.Error) > 0 {
+		event.Error = bosherr.Error(r.Error)
+	}
+
+	return event
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
@@ -24,6 +25,9 @@ type Events struct {
 type EventResult struct {
 	Event *Event
 	Error error
+
+	// Set for intermediate updates that do not complete the event
+	Progress *EventProgress
 }
 
 func NewEvents(uuidGen boshuuid.Generator, reporter Reporter, incidentID string, logger boshlog.Logger) *Events {
@@ -36,10 +40,60 @@ func NewEvents(uuidGen boshuuid.Generator, reporter Reporter, incidentID string,
 	}
 }
 
+// NewEventsFromRecords restores events of an incident executed before API server restarted;
+// events that did not complete by then are marked as interrupted unless they can be resumed
+// (e.g. agent is still executing their tasks), in which case their results must be registered
+func NewEventsFromRecords(
+	uuidGen boshuuid.Generator,
+	reporter Reporter,
+	incidentID string,
+	records []EventRecord,
+	interruptedAt time.Time,
+	isResumable func(EventRecord) bool,
+	logger boshlog.Logger,
+) *Events {
+	events := NewEvents(uuidGen, reporter, incidentID, logger)
+
+	for _, record := range records {
+		event := record.event()
+		event.incidentID = incidentID
+		event.reporter = reporter
+		event.resultsWg = &events.resultsWg
+
+		if event.ExecutionCompletedAt.IsZero() && isResumable(record) {
+			events.resultsWg.Add(1)
+		} else if event.ExecutionCompletedAt.IsZero() {
+			event.ExecutionCompletedAt = interruptedAt
+
+			if event.Error == nil {
+				event.Error = bosherr.Error("API server restarted before event completed")
+			}
+		}
+
+		events.events = append(events.events, &event)
+	}
+
+	return events
+}
+
+func (e *Events) Records() []EventRecord {
+	var records []EventRecord
+
+	for _, event := range e.Events() {
+		records = append(records, event.Record())
+	}
+
+	return records
+}
+
 func (e *Events) RegisterResult(r EventResult) {
 	e.resultsCh <- r
 }
 
+func (e *Events) RegisterProgress(event *Event, p EventProgress) {
+	e.resultsCh <- EventResult{Event: event, Progress: &p}
+}
+
 func (e *Events) Results() chan EventResult {
 	go func() {
 		e.resultsWg.Wait()
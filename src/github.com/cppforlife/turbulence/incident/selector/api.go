@@ -24,6 +24,36 @@ type IDRequest struct {
 // todo PersistentDisk
 // todo Bootstrap
 
+// WithoutLimits returns request that selects all instances
+// that could possibly be selected by the original request
+func (a Request) WithoutLimits() Request {
+	if a.AZ != nil {
+		req := *a.AZ
+		req.Limit = Limit{}
+		a.AZ = &req
+	}
+
+	if a.Deployment != nil {
+		req := *a.Deployment
+		req.Limit = Limit{}
+		a.Deployment = &req
+	}
+
+	if a.Group != nil {
+		req := *a.Group
+		req.Limit = Limit{}
+		a.Group = &req
+	}
+
+	if a.ID != nil {
+		req := *a.ID
+		req.Limit = Limit{}
+		a.ID = &req
+	}
+
+	return a
+}
+
 func (a Request) AsSelector() Selector {
 	selectors := []Selector{}
 
@@ -81,5 +81,29 @@ var _ = Describe("Limit", func() {
 				out[1].Deployment(),
 			}).To(ConsistOfLen(2, []string{"1-dep1-2", "1-dep1-3-other"}))
 		})
+
+		It("selects all possible instances without limits", func() {
+			str := `{ "Group": { "Name": "group1", "Limit": "1" }, "ID": { "Limit": "1" } }`
+
+			var req Request
+
+			err := json.Unmarshal([]byte(str), &req)
+			Expect(err).ToNot(HaveOccurred())
+
+			in := []Instance{
+				SimpleInstance{id: "id1", group: "group1"},
+				SimpleInstance{id: "id2", group: "group1"},
+				SimpleInstance{id: "id1", group: "group2"},
+			}
+
+			out, err := req.WithoutLimits().AsSelector().Select(in)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(out)).To(Equal(2))
+
+			// Original request is not modified
+			out, err = req.AsSelector().Select(in)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(out)).To(Equal(1))
+		})
 	})
 })
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 
 	"github.com/cppforlife/turbulence/director"
 	"github.com/cppforlife/turbulence/incident/reporter"
+	"github.com/cppforlife/turbulence/storage"
+	"github.com/cppforlife/turbulence/tasks"
 )
 
 type Config struct {
@@ -16,17 +22,45 @@ type Config struct {
 	ListenPort      int
 	AgentListenPort int
 
+	// Agent channel (gRPC) is served on this port when set
+	AgentChannelPort int
+
 	Username string
 	Password string
 
 	CertificatePath string
 	PrivateKeyPath  string
 
+	// When set, agents must present client certificates signed by this CA
+	// with common name matching their agent ID; agents can then only act on their own tasks
+	AgentCACert string
+
+	// Results of finished tasks are only kept in memory for a limited time
+	TaskRetention TaskRetentionConfig
+
+	// Incidents, scheduled incidents and active tasks are kept in memory only by default
+	Storage StorageConfig
+
 	Director director.Config
 
 	Datadog reporter.DatadogConfig
 }
 
+type TaskRetentionConfig struct {
+	TTL         string // e.g. 1h; defaults to 1h
+	MaxFinished int    // defaults to 1000
+}
+
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+type StorageConfig struct {
+	Type string // memory (default) or file
+	Dir  string // required for file storage
+}
+
 func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
 	var config Config
 
@@ -56,6 +90,34 @@ func (c Config) AgentListenAddr() string {
 	return fmt.Sprintf("%s:%d", c.ListenAddress, c.AgentListenPort)
 }
 
+func (c Config) AgentChannelListenAddr() string {
+	return fmt.Sprintf("%s:%d", c.ListenAddress, c.AgentChannelPort)
+}
+
+func (c Config) AgentCACertPool() (*x509.CertPool, error) {
+	if len(c.AgentCACert) == 0 {
+		return nil, nil
+	}
+
+	certPool := x509.NewCertPool()
+
+	if ok := certPool.AppendCertsFromPEM([]byte(c.AgentCACert)); !ok {
+		return nil, bosherr.Error("Invalid 'AgentCACert'")
+	}
+
+	return certPool, nil
+}
+
+// AgentTLSConfig requires agents to present client certificates; nil if not configured
+func (c Config) AgentTLSConfig() (*tls.Config, error) {
+	certPool, err := c.AgentCACertPool()
+	if err != nil || certPool == nil {
+		return nil, err
+	}
+
+	return &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: certPool}, nil
+}
+
 func (c Config) Validate() error {
 	if len(c.ListenAddress) == 0 {
 		return bosherr.Error("Missing 'ListenAddress'")
@@ -85,6 +147,18 @@ func (c Config) Validate() error {
 		return bosherr.Error("Missing 'PrivateKeyPath'")
 	}
 
+	if _, err := c.AgentCACertPool(); err != nil {
+		return err
+	}
+
+	if err := c.Storage.Validate(); err != nil {
+		return bosherr.WrapError(err, "Validating 'Storage' config")
+	}
+
+	if _, err := c.TaskRetention.Retention(); err != nil {
+		return bosherr.WrapError(err, "Validating 'TaskRetention' config")
+	}
+
 	err := c.Director.Validate()
 	if err != nil {
 		return bosherr.WrapError(err, "Validating 'Director' config")
@@ -97,3 +171,53 @@ func (c Config) Validate() error {
 
 	return nil
 }
+
+func (c TaskRetentionConfig) Retention() (tasks.Retention, error) {
+	retention := tasks.DefaultRetention
+
+	if len(c.TTL) > 0 {
+		ttl, err := time.ParseDuration(c.TTL)
+		if err != nil || ttl <= 0 {
+			return retention, bosherr.Errorf("Expected 'TTL' to be a positive duration (e.g. 1h) but was '%s'", c.TTL)
+		}
+
+		retention.TTL = ttl
+	}
+
+	if c.MaxFinished < 0 {
+		return retention, bosherr.Errorf("Expected 'MaxFinished' to be non-negative but was '%d'", c.MaxFinished)
+	}
+
+	if c.MaxFinished > 0 {
+		retention.MaxFinished = c.MaxFinished
+	}
+
+	return retention, nil
+}
+
+func (c StorageConfig) Validate() error {
+	switch c.Type {
+	case "", StorageMemory:
+		return nil
+	case StorageFile:
+		if len(c.Dir) == 0 {
+			return bosherr.Error("Missing 'Dir'")
+		}
+		return nil
+	default:
+		return bosherr.Errorf("Expected 'Type' to be '%s' or '%s' but was '%s'", StorageMemory, StorageFile, c.Type)
+	}
+}
+
+func (c StorageConfig) Store(fs boshsys.FileSystem, logger boshlog.Logger) (storage.Store, error) {
+	if c.Type == StorageFile {
+		err := fs.MkdirAll(c.Dir, 0700)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Creating storage directory '%s'", c.Dir)
+		}
+
+		return storage.NewFileStore(c.Dir, fs, logger), nil
+	}
+
+	return storage.NewMemoryStore(), nil
+}
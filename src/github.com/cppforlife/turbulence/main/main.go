@@ -10,6 +10,8 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 
+	"github.com/cppforlife/turbulence/agentreg"
+	"github.com/cppforlife/turbulence/agentrpc"
 	ctrls "github.com/cppforlife/turbulence/controllers"
 	"github.com/cppforlife/turbulence/director"
 	"github.com/cppforlife/turbulence/incident"
@@ -19,6 +21,8 @@ import (
 
 const mainLogTag = "main"
 
+const taskCollectionInterval = 1 * time.Minute
+
 var (
 	debugOpt      = flag.Bool("debug", false, "Output debug logs")
 	configPathOpt = flag.String("configPath", "", "Path to configuration file")
@@ -62,13 +66,33 @@ func main() {
 
 	go scheduler.Run()
 
-	repos, err := NewRepos(uuidGen, rep, dir, worker, scheduler, logger)
+	agentsRepo := agentreg.NewRepo(logger)
+
+	// Registered hosts without BOSH can be selected alongside BOSH instances
+	dir = agentreg.NewHostsDirector(dir, agentsRepo)
+
+	taskRetention, err := config.TaskRetention.Retention()
+	ensureNoErr(logger, "Failed building task retention", err)
+
+	store, err := config.Storage.Store(fs, logger)
+	ensureNoErr(logger, "Failed building storage", err)
+
+	repos, err := NewRepos(uuidGen, rep, dir, agentsRepo, taskRetention, store, worker, scheduler, logger)
 	ensureNoErr(logger, "Failed building repos", err)
 
-	controllerFactory, err := ctrls.NewFactory(repos, logger)
+	// Retention TTL must apply even if no tasks finish for a while
+	go func() {
+		for range time.Tick(taskCollectionInterval) {
+			repos.TasksRepo().Collect()
+		}
+	}()
+
+	controllerFactory, err := ctrls.NewFactory(repos, dir, logger)
 	ensureNoErr(logger, "Failed building controller factory", err)
 
-	err = Server{config, logger}.RunControllers(controllerFactory)
+	channelServer := agentrpc.NewServer(repos.TasksRepo(), repos.AgentsRepo(), ctrls.AgentPollTimeout, logger)
+
+	err = Server{config, logger}.RunControllers(controllerFactory, channelServer)
 	ensureNoErr(logger, "Running controllers", err)
 }
 
@@ -4,10 +4,12 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 
+	"github.com/cppforlife/turbulence/agentreg"
 	"github.com/cppforlife/turbulence/director"
 	"github.com/cppforlife/turbulence/incident"
 	"github.com/cppforlife/turbulence/incident/reporter"
 	"github.com/cppforlife/turbulence/scheduledinc"
+	"github.com/cppforlife/turbulence/storage"
 	"github.com/cppforlife/turbulence/tasks"
 )
 
@@ -15,37 +17,53 @@ type Repos struct {
 	incidentsRepo          incident.Repo
 	scheduledIncidentsRepo scheduledinc.Repo
 	tasksRepo              tasks.Repo
+	agentsRepo             agentreg.Repo
 }
 
 func NewRepos(
 	uuidGen boshuuid.Generator,
 	reporter reporter.Reporter,
 	director director.Director,
+	agentsRepo agentreg.Repo,
+	taskRetention tasks.Retention,
+	store storage.Store,
 	incidentNotifier incident.RepoNotifier,
 	scheduledIncidentNotifier scheduledinc.RepoNotifier,
 	logger boshlog.Logger,
 ) (Repos, error) {
-	tasksRepo := tasks.NewRepo(logger)
+	tasksRepo, err := tasks.NewRepo(taskRetention, store, logger)
+	if err != nil {
+		return Repos{}, err
+	}
 
-	incidentsRepo := incident.NewRepo(
+	incidentsRepo, err := incident.NewRepo(
 		uuidGen,
 		incidentNotifier,
 		reporter,
 		director,
 		tasksRepo,
+		store,
 		logger,
 	)
+	if err != nil {
+		return Repos{}, err
+	}
 
-	scheduledIncidentsRepo := scheduledinc.NewRepo(
+	scheduledIncidentsRepo, err := scheduledinc.NewRepo(
 		uuidGen,
 		scheduledIncidentNotifier,
 		incidentsRepo,
+		store,
 		logger,
 	)
+	if err != nil {
+		return Repos{}, err
+	}
 
-	return Repos{incidentsRepo, scheduledIncidentsRepo, tasksRepo}, nil
+	return Repos{incidentsRepo, scheduledIncidentsRepo, tasksRepo, agentsRepo}, nil
 }
 
 func (r Repos) IncidentsRepo() incident.Repo              { return r.incidentsRepo }
 func (r Repos) ScheduledIncidentsRepo() scheduledinc.Repo { return r.scheduledIncidentsRepo }
 func (r Repos) TasksRepo() tasks.Repo                     { return r.tasksRepo }
+func (r Repos) AgentsRepo() agentreg.Repo                 { return r.agentsRepo }
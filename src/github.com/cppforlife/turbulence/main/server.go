@@ -1,14 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"html/template"
+	"net"
 	"net/http"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	mart "github.com/go-martini/martini"
 	martauth "github.com/martini-contrib/auth"
 	martrend "github.com/martini-contrib/render"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/cppforlife/turbulence/agentrpc"
 	ctrls "github.com/cppforlife/turbulence/controllers"
 )
 
@@ -17,23 +23,75 @@ type Server struct {
 	logger boshlog.Logger
 }
 
-func (s Server) RunControllers(controllerFactory ctrls.Factory) error {
+func (s Server) RunControllers(controllerFactory ctrls.Factory, channelServer agentrpc.Server) error {
 	operatorM := s.authedMartini()
 	s.addOperatorAPI(operatorM, controllerFactory)
 
-	agentM := s.authedMartini()
+	agentTLSConfig, err := s.config.AgentTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	// Agents with client certificates are not given operator credentials
+	agentM := mart.Classic()
+	if agentTLSConfig == nil {
+		agentM = s.authedMartini()
+	}
 	s.addAgentAPI(agentM, controllerFactory)
 
 	errs := make(chan error, 1)
-	go s.listen(s.config.ListenAddr(), "operator", operatorM, errs)
-	go s.listen(s.config.AgentListenAddr(), "agent", agentM, errs)
+	go s.listen(s.config.ListenAddr(), "operator", operatorM, nil, errs)
+	go s.listen(s.config.AgentListenAddr(), "agent", agentM, agentTLSConfig, errs)
+
+	// Older agents keep using agent API
+	if s.config.AgentChannelPort != 0 {
+		go s.listenChannel(channelServer, agentTLSConfig, errs)
+	}
 
 	return <-errs
 }
 
-func (s Server) listen(addr string, purpose string, m *mart.ClassicMartini, errs chan<- error) {
+func (s Server) listen(addr string, purpose string, m *mart.ClassicMartini, tlsConfig *tls.Config, errs chan<- error) {
 	s.logger.Debug("main.Server", "Starting %s API '%s'", purpose, addr)
-	errs <- http.ListenAndServeTLS(addr, s.config.CertificatePath, s.config.PrivateKeyPath, m)
+	server := &http.Server{Addr: addr, Handler: m, TLSConfig: tlsConfig}
+	errs <- server.ListenAndServeTLS(s.config.CertificatePath, s.config.PrivateKeyPath)
+}
+
+func (s Server) listenChannel(channelServer agentrpc.Server, agentTLSConfig *tls.Config, errs chan<- error) {
+	addr := s.config.AgentChannelListenAddr()
+
+	s.logger.Debug("main.Server", "Starting agent channel '%s'", addr)
+
+	cert, err := tls.LoadX509KeyPair(s.config.CertificatePath, s.config.PrivateKeyPath)
+	if err != nil {
+		errs <- bosherr.WrapError(err, "Loading agent channel certificate")
+		return
+	}
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	// Agents with client certificates are not given operator credentials
+	opts := []grpc.ServerOption{}
+
+	if agentTLSConfig != nil {
+		tlsConfig.ClientAuth = agentTLSConfig.ClientAuth
+		tlsConfig.ClientCAs = agentTLSConfig.ClientCAs
+	} else {
+		opts = append(opts, grpc.StreamInterceptor(agentrpc.NewBasicAuthInterceptor(s.config.Username, s.config.Password)))
+	}
+
+	opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		errs <- bosherr.WrapError(err, "Listening for agent channel")
+		return
+	}
+
+	server := grpc.NewServer(opts...)
+	agentrpc.RegisterAgentChannelServer(server, channelServer)
+
+	errs <- server.Serve(listener)
 }
 
 func (s Server) authedMartini() *mart.ClassicMartini {
@@ -51,9 +109,11 @@ func (s Server) addOperatorAPI(m *mart.ClassicMartini, controllerFactory ctrls.F
 
 	m.Get("/incidents", isController.Index)
 	m.Get("/incidents/:id", isController.Read)
+	m.Post("/incidents/:id/stop", isController.Stop)
 	m.Get("/api/v1/incidents", isController.APIIndex)
 	m.Get("/api/v1/incidents/:id", isController.APIRead)
 	m.Post("/api/v1/incidents", isController.APICreate)
+	m.Post("/api/v1/incidents/:id/stop", isController.APIStop)
 
 	sisController := controllerFactory.ScheduledIncidentsController
 
@@ -64,6 +124,11 @@ func (s Server) addOperatorAPI(m *mart.ClassicMartini, controllerFactory ctrls.F
 	m.Post("/api/v1/scheduled_incidents", sisController.APICreate)
 	m.Delete("/api/v1/scheduled_incidents/:id", sisController.APIDelete)
 
+	agController := controllerFactory.AgentsController
+
+	m.Get("/agents", agController.Index)
+	m.Get("/api/v1/agents", agController.APIIndex)
+
 	// Driver may change desired state of the task so that task ends
 	m.Post("/api/v1/agent_tasks/:id/state", controllerFactory.TasksController.APIUpdateState)
 }
@@ -76,10 +141,19 @@ func (Server) addAgentAPI(m *mart.ClassicMartini, controllerFactory ctrls.Factor
 		IndentJSON: true,
 	}))
 
-	// Agent watches for tasks based on agent ID
+	// Agents with client certificates can only act on their own behalf
+	m.Use(ctrls.AgentIdentityHandler)
+
+	// Agent reports its capabilities so that incidents could be checked before execution
+	m.Post("/api/v1/agents/:id", controllerFactory.AgentsController.APIRegister)
+	// Agent waits for new tasks and for desired state changes of its tasks (long polling)
+	m.Post("/api/v1/agents/:id/poll", controllerFactory.TasksController.APIPoll)
+	// Older agents watch for tasks based on agent ID
 	m.Post("/api/v1/agents/:id/tasks", controllerFactory.TasksController.APIConsume)
-	// Agent watches desired state of the task so that it can end it
+	// Older agents watch desired state of the task so that it can end it
 	m.Get("/api/v1/agent_tasks/:id/state", controllerFactory.TasksController.APIReadState)
+	// While agent executes picked up task, its progress is periodically reported
+	m.Post("/api/v1/agent_tasks/:id/progress", controllerFactory.TasksController.APIUpdateProgress)
 	// Once agent executes picked up task, its result is reported
 	m.Post("/api/v1/agent_tasks/:id", controllerFactory.TasksController.APIUpdate)
 }
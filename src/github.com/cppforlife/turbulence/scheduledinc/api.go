@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/robfig/cron"
+
 	"github.com/cppforlife/turbulence/incident"
+	"github.com/cppforlife/turbulence/tasks"
 )
 
 type Request struct {
@@ -12,6 +15,20 @@ type Request struct {
 	Incident incident.Request
 }
 
+func (r Request) Validate() error {
+	var scheduleErr error
+
+	if len(r.Schedule) == 0 {
+		scheduleErr = tasks.NewValidationError("Schedule", "must be specified")
+	} else if _, err := cron.Parse(r.Schedule); err != nil {
+		scheduleErr = tasks.NewValidationErrorWithPrefix("Schedule", err)
+	}
+
+	incidentErr := tasks.NewValidationErrorWithPrefix("Incident", r.Incident.Validate())
+
+	return tasks.MergeValidationErrors(scheduleErr, incidentErr)
+}
+
 type Response struct {
 	ID string
 
@@ -0,0 +1,28 @@
+package scheduledinc
+
+import (
+	"time"
+
+	"github.com/cppforlife/turbulence/incident"
+)
+
+const storeCollection = "scheduled_incidents"
+
+// record is how scheduled incident is persisted
+type record struct {
+	ID        string
+	CreatedAt time.Time
+
+	Schedule string
+	Incident incident.Request
+}
+
+func (si ScheduledIncident) record() record {
+	return record{ID: si.ID, CreatedAt: si.createdAt, Schedule: si.Schedule, Incident: si.Incident}
+}
+
+type recordsByCreatedAt []record
+
+func (s recordsByCreatedAt) Len() int           { return len(s) }
+func (s recordsByCreatedAt) Less(i, j int) bool { return s[i].CreatedAt.Before(s[j].CreatedAt) }
+func (s recordsByCreatedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
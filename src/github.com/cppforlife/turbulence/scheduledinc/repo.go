@@ -1,14 +1,18 @@
 package scheduledinc
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 
 	"github.com/cppforlife/turbulence/incident"
+	"github.com/cppforlife/turbulence/storage"
 )
 
 func (e NotFoundError) Error() string {
@@ -19,6 +23,7 @@ type repo struct {
 	uuidGen       boshuuid.Generator
 	notifier      RepoNotifier
 	incidentsRepo incident.Repo
+	store         storage.Store
 
 	sis     []ScheduledIncident
 	sisLock sync.RWMutex
@@ -30,14 +35,53 @@ func NewRepo(
 	uuidGen boshuuid.Generator,
 	notifier RepoNotifier,
 	incidentsRepo incident.Repo,
+	store storage.Store,
 	logger boshlog.Logger,
-) Repo {
-	return &repo{
+) (Repo, error) {
+	r := &repo{
 		uuidGen:       uuidGen,
 		notifier:      notifier,
 		incidentsRepo: incidentsRepo,
+		store:         store,
 		logger:        logger,
 	}
+
+	return r, r.load()
+}
+
+// load restores scheduled incidents saved before API server restarted
+// and schedules them again
+func (r *repo) load() error {
+	docs, err := r.store.List(storeCollection)
+	if err != nil {
+		return bosherr.WrapError(err, "Loading scheduled incidents")
+	}
+
+	var records []record
+
+	for _, doc := range docs {
+		var rec record
+
+		err := json.Unmarshal(doc, &rec)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling scheduled incident")
+		}
+
+		records = append(records, rec)
+	}
+
+	sort.Sort(recordsByCreatedAt(records))
+
+	for _, rec := range records {
+		scheduledIncident := r.newScheduledIncident(rec.ID, Request{Schedule: rec.Schedule, Incident: rec.Incident})
+		scheduledIncident.createdAt = rec.CreatedAt
+
+		r.sis = append(r.sis, scheduledIncident)
+
+		r.notifier.ScheduledIncidentWasCreated(scheduledIncident)
+	}
+
+	return nil
 }
 
 func (r *repo) ListAll() ([]ScheduledIncident, error) {
@@ -51,15 +95,12 @@ func (r *repo) Create(req Request) (ScheduledIncident, error) {
 		return ScheduledIncident{}, bosherr.WrapError(err, "Generating scheduled incident ID")
 	}
 
-	scheduledIncident := ScheduledIncident{
-		updateFunc:    r.update,
-		incidentsRepo: r.incidentsRepo,
-		logger:        r.logger,
-
-		ID: uuid,
+	scheduledIncident := r.newScheduledIncident(uuid, req)
+	scheduledIncident.createdAt = time.Now().UTC()
 
-		Schedule: req.Schedule,
-		Incident: req.Incident,
+	err = r.store.Save(storeCollection, uuid, scheduledIncident.record())
+	if err != nil {
+		return ScheduledIncident{}, bosherr.WrapErrorf(err, "Saving scheduled incident '%s'", uuid)
 	}
 
 	r.sisLock.Lock()
@@ -72,6 +113,19 @@ func (r *repo) Create(req Request) (ScheduledIncident, error) {
 	return scheduledIncident, nil
 }
 
+func (r *repo) newScheduledIncident(id string, req Request) ScheduledIncident {
+	return ScheduledIncident{
+		updateFunc:    r.update,
+		incidentsRepo: r.incidentsRepo,
+		logger:        r.logger,
+
+		ID: id,
+
+		Schedule: req.Schedule,
+		Incident: req.Incident,
+	}
+}
+
 func (r *repo) Read(id string) (ScheduledIncident, error) {
 	r.sisLock.Lock()
 	defer r.sisLock.Unlock()
@@ -86,6 +140,11 @@ func (r *repo) Read(id string) (ScheduledIncident, error) {
 }
 
 func (r *repo) Delete(id string) error {
+	err := r.store.Delete(storeCollection, id)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting scheduled incident '%s'", id)
+	}
+
 	var deletedSi ScheduledIncident
 
 	r.sisLock.Lock()
@@ -119,5 +178,5 @@ func (r *repo) update(updated ScheduledIncident) error {
 		}
 	}
 
-	return nil
+	return r.store.Save(storeCollection, updated.ID, updated.record())
 }
@@ -1,6 +1,8 @@
 package scheduledinc
 
 import (
+	"time"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
@@ -12,7 +14,8 @@ type ScheduledIncident struct {
 	incidentsRepo incident.Repo
 	logger        boshlog.Logger
 
-	ID string
+	ID        string
+	createdAt time.Time
 
 	Schedule string
 
@@ -21,7 +21,8 @@ type Scheduler struct {
 
 func NewScheduler(logger boshlog.Logger) *Scheduler {
 	return &Scheduler{
-		cronReset: make(chan struct{}),
+		// Buffered so that changes made during a reset trigger another reset
+		cronReset: make(chan struct{}, 1),
 
 		items: map[string]ScheduledIncident{},
 
@@ -57,7 +58,7 @@ func (s Scheduler) signalCronReset() {
 	case s.cronReset <- struct{}{}:
 		// signalled
 	default:
-		// ignored since already resetting
+		// ignored since reset is already pending
 	}
 }
 
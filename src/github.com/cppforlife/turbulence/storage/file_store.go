@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// fileStore keeps each document in a separate file (e.g. <dir>/incidents/<id>.json)
+type fileStore struct {
+	dir string
+	fs  boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFileStore(dir string, fs boshsys.FileSystem, logger boshlog.Logger) Store {
+	return fileStore{dir: dir, fs: fs, logTag: "storage.fileStore", logger: logger}
+}
+
+func (s fileStore) Save(collection, id string, doc interface{}) error {
+	bytes, err := json.Marshal(doc)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling '%s' document '%s'", collection, id)
+	}
+
+	err = s.fs.MkdirAll(filepath.Join(s.dir, collection), 0700)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating '%s' collection directory", collection)
+	}
+
+	path := s.path(collection, id)
+
+	// Write and rename so that document is never partially written
+	err = s.fs.WriteFile(path+".tmp", bytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing document '%s'", path)
+	}
+
+	err = s.fs.Rename(path+".tmp", path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming document '%s'", path)
+	}
+
+	return nil
+}
+
+func (s fileStore) Delete(collection, id string) error {
+	err := s.fs.RemoveAll(s.path(collection, id))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting '%s' document '%s'", collection, id)
+	}
+
+	return nil
+}
+
+func (s fileStore) List(collection string) ([][]byte, error) {
+	paths, err := s.fs.Glob(filepath.Join(s.dir, collection, "*.json"))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing '%s' documents", collection)
+	}
+
+	var docs [][]byte
+
+	for _, path := range paths {
+		bytes, err := s.fs.ReadFile(path)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading document '%s'", path)
+		}
+
+		docs = append(docs, bytes)
+	}
+
+	return docs, nil
+}
+
+func (s fileStore) path(collection, id string) string {
+	return filepath.Join(s.dir, collection, filepath.Base(id)+".json")
+}
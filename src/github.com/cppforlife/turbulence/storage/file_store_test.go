@@ -0,0 +1,58 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/storage"
+)
+
+var _ = Describe("FileStore", func() {
+	var (
+		dir   string
+		store Store
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		dir, err = ioutil.TempDir("", "turbulence-storage")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		store = NewFileStore(dir, boshsys.NewOsFileSystem(logger), logger)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("saves, replaces and deletes documents in a collection", func() {
+		Expect(store.Save("things", "a", map[string]int{"v": 1})).ToNot(HaveOccurred())
+		Expect(store.Save("things", "a", map[string]int{"v": 2})).ToNot(HaveOccurred())
+		Expect(store.Save("things", "b", map[string]int{"v": 3})).ToNot(HaveOccurred())
+		Expect(store.Save("others", "c", map[string]int{"v": 4})).ToNot(HaveOccurred())
+
+		docs, err := store.List("things")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(ConsistOf([]byte(`{"v":2}`), []byte(`{"v":3}`)))
+
+		Expect(store.Delete("things", "a")).ToNot(HaveOccurred())
+
+		docs, err = store.List("things")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(Equal([][]byte{[]byte(`{"v":3}`)}))
+	})
+
+	It("returns no documents for missing collection", func() {
+		docs, err := store.List("missing")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).To(BeEmpty())
+	})
+})
@@ -0,0 +1,11 @@
+package storage
+
+// Store keeps JSON documents grouped into collections (e.g. incidents)
+// so that repos can be reloaded after API server restarts
+type Store interface {
+	Save(collection, id string, doc interface{}) error
+	Delete(collection, id string) error
+
+	// List returns marshalled documents of a collection in no particular order
+	List(collection string) ([][]byte, error)
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IsAdjustable returns true for task types that can
+// apply new options while they are running
+func IsAdjustable(taskOpts Options) bool {
+	switch taskOpts.(type) {
+	case ControlNetOptions, StressOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateAdjustment checks that options could be applied to a running task
+func ValidateAdjustment(task Task, opts OptionsSlice) error {
+	if len(opts) != 1 {
+		return NewValidationError("Options", "must include exactly one item")
+	}
+
+	taskType := OptionsType(task.Options())
+
+	if OptionsType(opts[0]) != taskType {
+		return NewValidationError("Options[0].Type", fmt.Sprintf("must match task type '%s'", taskType))
+	}
+
+	if !IsAdjustable(opts[0]) {
+		return NewValidationError("Options[0].Type", fmt.Sprintf("'%s' tasks cannot be adjusted", taskType))
+	}
+
+	err := validateFixedFields(task.Options(), opts[0])
+	if err != nil {
+		return NewValidationErrorWithPrefix("Options[0]", err)
+	}
+
+	return NewValidationErrorWithPrefix("Options", opts.Validate())
+}
+
+// validateFixedFields checks that adjustment does not change options that
+// only apply when task starts; such options may be omitted
+func validateFixedFields(taskOpts, adjOpts Options) error {
+	var v validator
+
+	fixed := func(field string, taskVal, adjVal interface{}, omitted bool) {
+		if !omitted && !reflect.DeepEqual(taskVal, adjVal) {
+			v.Add(field, "cannot be adjusted")
+		}
+	}
+
+	switch taskOpts := taskOpts.(type) {
+	case ControlNetOptions:
+		adjOpts := adjOpts.(ControlNetOptions)
+		fixed("Timeout", taskOpts.Timeout, adjOpts.Timeout, len(adjOpts.Timeout) == 0)
+		fixed("Ramp", taskOpts.Ramp, adjOpts.Ramp, adjOpts.Ramp == nil)
+		fixed("Target", taskOpts.Target, adjOpts.Target, adjOpts.Target == nil)
+
+	case StressOptions:
+		adjOpts := adjOpts.(StressOptions)
+		fixed("Timeout", taskOpts.Timeout, adjOpts.Timeout, len(adjOpts.Timeout) == 0)
+		fixed("Ramp", taskOpts.Ramp, adjOpts.Ramp, adjOpts.Ramp == nil)
+	}
+
+	return v.Err()
+}
@@ -0,0 +1,46 @@
+package tasks_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("ValidateAdjustment", func() {
+	task := Task{ID: "task1", Optionss: OptionsSlice{ControlNetOptions{
+		Timeout: "10m",
+		Delay:   "50ms",
+		Ramp:    &RampOptions{Duration: "1m"},
+		Target:  &ContainerTarget{Label: "app-*"},
+	}}}
+
+	It("allows omitting or repeating options that only apply when task starts", func() {
+		err := ValidateAdjustment(task, OptionsSlice{ControlNetOptions{Delay: "500ms"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = ValidateAdjustment(task, OptionsSlice{ControlNetOptions{
+			Timeout: "10m",
+			Delay:   "500ms",
+			Ramp:    &RampOptions{Duration: "1m"},
+			Target:  &ContainerTarget{Label: "app-*"},
+		}})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects changes to options that only apply when task starts", func() {
+		err := ValidateAdjustment(task, OptionsSlice{ControlNetOptions{
+			Timeout: "1h",
+			Delay:   "500ms",
+			Ramp:    &RampOptions{Duration: "5m"},
+			Target:  &ContainerTarget{Label: "other-*"},
+		}})
+		Expect(err).To(MatchError("Invalid request: 'Options[0].Timeout' cannot be adjusted, " +
+			"'Options[0].Ramp' cannot be adjusted, 'Options[0].Target' cannot be adjusted"))
+
+		stressTask := Task{ID: "task2", Optionss: OptionsSlice{StressOptions{NumCPUWorkers: 1}}}
+
+		err = ValidateAdjustment(stressTask, OptionsSlice{StressOptions{Timeout: "5m", NumCPUWorkers: 2}})
+		Expect(err).To(MatchError("Invalid request: 'Options[0].Timeout' cannot be adjusted"))
+	})
+})
@@ -2,6 +2,10 @@ package tasks
 
 type StateRequest struct {
 	Stop bool
+
+	// New options for a running task; only one of them must be provided
+	// and it must be of the same type as the task
+	Options OptionsSlice `json:",omitempty"`
 }
 
 type StateResponse struct {
@@ -9,5 +13,54 @@ type StateResponse struct {
 }
 
 type ResultRequest struct {
-	Error string
+	Error string // kept for older agents and clients
+
+	TaskError *TaskError `json:",omitempty"`
+}
+
+// Err returns TaskError (if any); errors reported by older agents are not categorized
+func (r ResultRequest) Err() error {
+	if r.TaskError != nil {
+		return *r.TaskError
+	}
+
+	if len(r.Error) > 0 {
+		return TaskError{Category: ErrorCategoryExecution, Message: r.Error}
+	}
+
+	return nil
+}
+
+type ProgressRequest struct {
+	State string // empty when only sending a heartbeat
+
+	// Output captured since previous progress request
+	Output string
+}
+
+type PollRequest struct {
+	// Tasks that agent is executing; nil if agent does not report them.
+	// Active tasks that agent is not executing (e.g. lost after agent restarted) are failed
+	RunningTaskIDs []string
+
+	// Tasks that agent already stopped or is in the process of stopping
+	StoppedTaskIDs []string
+
+	// Last adjustment version applied to each task
+	AdjustmentVersions map[string]int `json:",omitempty"`
+}
+
+type PollResponse struct {
+	Tasks          []Task
+	StoppedTaskIDs []string
+
+	Adjustments []Adjustment `json:",omitempty"`
+}
+
+// Adjustment carries new options for a running task;
+// only latest adjustment for a task is kept
+type Adjustment struct {
+	TaskID  string
+	Version int
+	Options OptionsSlice
 }
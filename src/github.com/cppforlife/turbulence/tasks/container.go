@@ -0,0 +1,394 @@
+package tasks
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// ContainerTarget selects a container (e.g. Garden container on a Diego cell)
+// that task applies its fault to instead of the host; only one field may be set
+type ContainerTarget struct {
+	// PID of any process running in the container
+	PID int `json:",omitempty"`
+
+	// Cgroup path of the container (e.g. /garden/0e1a2b3c-...)
+	Cgroup string `json:",omitempty"`
+
+	// Pattern matched against cgroup path elements (e.g. container handle '*-app-guid-*');
+	// must match exactly one container
+	Label string `json:",omitempty"`
+}
+
+func (t ContainerTarget) validate(v *validator) {
+	var set int
+
+	if t.PID != 0 {
+		set++
+	}
+	if len(t.Cgroup) > 0 {
+		set++
+	}
+	if len(t.Label) > 0 {
+		set++
+	}
+
+	if set != 1 {
+		v.Add("Target", "must specify exactly one of 'PID', 'Cgroup' or 'Label'")
+	}
+
+	if t.PID < 0 || t.PID == 1 {
+		v.Add("Target.PID", "must be a PID of a container process (got '%d')", t.PID)
+	}
+
+	if len(t.Cgroup) > 0 && (!strings.HasPrefix(t.Cgroup, "/") || t.Cgroup == "/") {
+		v.Add("Target.Cgroup", "must be an absolute cgroup path (got '%s')", t.Cgroup)
+	}
+
+	if _, err := filepath.Match(t.Label, ""); err != nil {
+		v.Add("Target.Label", "must be a valid pattern (got '%s')", t.Label)
+	}
+}
+
+// resourceKey identifies target when locking resources (e.g. 'cgroup:/garden/abc')
+func (t ContainerTarget) resourceKey() string {
+	switch {
+	case t.PID != 0:
+		return "container:pid:" + strconv.Itoa(t.PID)
+	case len(t.Cgroup) > 0:
+		return "container:cgroup:" + t.Cgroup
+	default:
+		return "container:label:" + t.Label
+	}
+}
+
+// Container is a set of processes that share a cgroup
+type Container struct {
+	Cgroup string
+	PIDs   []int // sorted
+
+	// Init process of the container
+	MainPID int
+
+	// Network namespace of the main process (e.g. net:[4026532285])
+	Netns string
+}
+
+type ContainerFinder struct {
+	fs boshsys.FileSystem
+}
+
+func NewContainerFinder(fs boshsys.FileSystem) ContainerFinder {
+	return ContainerFinder{fs}
+}
+
+// Find looks up container processes via /proc
+func (f ContainerFinder) Find(target ContainerTarget) (Container, error) {
+	cgroups, err := f.processCgroups()
+	if err != nil {
+		return Container{}, err
+	}
+
+	pidsByCgroup := map[string][]int{}
+
+	for pid, cgroup := range cgroups {
+		var key string
+
+		switch {
+		case target.PID != 0:
+			if targetCgroup, found := cgroups[target.PID]; found && cgroup == targetCgroup {
+				key = cgroup
+			}
+
+		case len(target.Cgroup) > 0:
+			if cgroup == target.Cgroup || strings.HasPrefix(cgroup, target.Cgroup+"/") {
+				key = target.Cgroup
+			}
+
+		default:
+			key = f.matchLabel(target.Label, cgroup)
+		}
+
+		if len(key) > 0 {
+			pidsByCgroup[key] = append(pidsByCgroup[key], pid)
+		}
+	}
+
+	if len(pidsByCgroup) == 0 {
+		return Container{}, bosherr.Errorf("Target must match a running container")
+	}
+
+	if len(pidsByCgroup) > 1 {
+		var matched []string
+		for cgroup := range pidsByCgroup {
+			matched = append(matched, cgroup)
+		}
+		sort.Strings(matched)
+
+		return Container{}, bosherr.Errorf("Target must match exactly one container (matched: '%s')",
+			strings.Join(matched, "', '"))
+	}
+
+	var container Container
+
+	for cgroup, pids := range pidsByCgroup {
+		sort.Ints(pids)
+		container = Container{Cgroup: cgroup, PIDs: pids}
+	}
+
+	container.MainPID, err = f.mainPID(container.PIDs)
+	if err != nil {
+		return Container{}, bosherr.WrapErrorf(err, "Finding main process of container '%s'", container.Cgroup)
+	}
+
+	container.Netns, err = f.netns(strconv.Itoa(container.MainPID))
+	if err != nil {
+		return Container{}, err
+	}
+
+	return container, nil
+}
+
+// mainPID returns process that is PID 1 in container's PID namespace or otherwise
+// the oldest process whose parent is not in the container (order of PIDs means nothing)
+func (f ContainerFinder) mainPID(pids []int) (int, error) {
+	inContainer := map[int]struct{}{}
+
+	for _, pid := range pids {
+		inContainer[pid] = struct{}{}
+	}
+
+	var mainPID int
+	var mainStartTime uint64
+
+	for _, pid := range pids {
+		// Process may have exited since it was listed
+		status, err := f.fs.ReadFileString(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			continue
+		}
+
+		ppid, nsPID := f.parseStatus(status)
+
+		if nsPID == 1 {
+			return pid, nil
+		}
+
+		if _, found := inContainer[ppid]; found {
+			continue
+		}
+
+		startTime, err := f.startTime(pid)
+		if err != nil {
+			continue
+		}
+
+		if mainPID == 0 || startTime < mainStartTime {
+			mainPID, mainStartTime = pid, startTime
+		}
+	}
+
+	if mainPID == 0 {
+		return 0, bosherr.Error("Expected to find running process whose parent is outside of container")
+	}
+
+	return mainPID, nil
+}
+
+// parseStatus returns parent PID and PID in innermost PID namespace (0 if process
+// is not in a nested namespace) from /proc/PID/status (e.g. 'PPid:\t10', 'NSpid:\t95\t1')
+func (ContainerFinder) parseStatus(status string) (int, int) {
+	var ppid, nsPID int
+
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 2 && fields[0] == "PPid:":
+			ppid, _ = strconv.Atoi(fields[1])
+		case len(fields) > 2 && fields[0] == "NSpid:":
+			nsPID, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+
+	return ppid, nsPID
+}
+
+// startTime returns process start time in clock ticks since boot (22nd field of /proc/PID/stat)
+func (f ContainerFinder) startTime(pid int) (uint64, error) {
+	stat, err := f.fs.ReadFileString(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Reading stat of process '%d'", pid)
+	}
+
+	// Command name may include spaces and parentheses, e.g. '95 (my (app)) S 10 ...'
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 20 {
+		return 0, bosherr.Errorf("Expected stat of process '%d' to include start time", pid)
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// FindIsolated finds container that does not share network namespace with the agent
+// so that faults cannot affect the host (e.g. target that resolves to a host cgroup)
+func (f ContainerFinder) FindIsolated(target ContainerTarget) (Container, error) {
+	container, err := f.Find(target)
+	if err != nil {
+		return container, err
+	}
+
+	hostNetns, err := f.netns("self")
+	if err != nil {
+		return container, err
+	}
+
+	if container.Netns == hostNetns {
+		return container, bosherr.Errorf(
+			"Container '%s' must not share network namespace with the host", container.Cgroup)
+	}
+
+	return container, nil
+}
+
+// IfaceNames lists non-local network interfaces inside container's network namespace
+func (f ContainerFinder) IfaceNames(container Container) ([]string, error) {
+	// e.g. ' eth0: 1296 16 0 0 0 0 0 0 ...'; first two lines are headers
+	dev, err := f.fs.ReadFileString(fmt.Sprintf("/proc/%d/net/dev", container.MainPID))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing network interfaces of container '%s'", container.Cgroup)
+	}
+
+	var ifaceNames []string
+
+	for _, line := range strings.Split(dev, "\n") {
+		pieces := strings.SplitN(line, ":", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(pieces[0])
+
+		if len(name) > 0 && !strings.HasPrefix(name, "lo") {
+			ifaceNames = append(ifaceNames, name)
+		}
+	}
+
+	return ifaceNames, nil
+}
+
+// ProcessName returns command name of a container process (same as used by pkill)
+func (f ContainerFinder) ProcessName(pid int) (string, error) {
+	comm, err := f.fs.ReadFileString(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading name of process '%d'", pid)
+	}
+
+	return strings.TrimSpace(comm), nil
+}
+
+// processCgroups returns memory cgroup (or unified cgroup) of each running process
+func (f ContainerFinder) processCgroups() (map[int]string, error) {
+	paths, err := f.fs.Glob("/proc/[0-9]*/cgroup")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing processes")
+	}
+
+	cgroups := map[int]string{}
+
+	for _, path := range paths {
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(path)))
+		if err != nil {
+			continue
+		}
+
+		// Process may have exited since it was listed
+		content, err := f.fs.ReadFileString(path)
+		if err != nil {
+			continue
+		}
+
+		if cgroup := f.processCgroup(content); len(cgroup) > 0 {
+			cgroups[pid] = cgroup
+		}
+	}
+
+	return cgroups, nil
+}
+
+// e.g. '4:memory:/garden/abc' (v1) or '0::/system.slice/ssh.service' (v2)
+func (ContainerFinder) processCgroup(content string) string {
+	var unified string
+
+	for _, line := range strings.Split(content, "\n") {
+		pieces := strings.SplitN(line, ":", 3)
+		if len(pieces) != 3 {
+			continue
+		}
+
+		for _, controller := range strings.Split(pieces[1], ",") {
+			if controller == "memory" {
+				return pieces[2]
+			}
+		}
+
+		if pieces[0] == "0" && len(pieces[1]) == 0 {
+			unified = pieces[2]
+		}
+	}
+
+	return unified
+}
+
+// matchLabel returns cgroup path up to the first element that matches pattern
+func (ContainerFinder) matchLabel(pattern, cgroup string) string {
+	elements := strings.Split(strings.TrimPrefix(cgroup, "/"), "/")
+
+	for i, element := range elements {
+		if matched, _ := filepath.Match(pattern, element); matched {
+			return "/" + strings.Join(elements[:i+1], "/")
+		}
+	}
+
+	return ""
+}
+
+func (f ContainerFinder) netns(pid string) (string, error) {
+	netns, err := f.fs.Readlink("/proc/" + pid + "/ns/net")
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading network namespace of process '%s'", pid)
+	}
+
+	return netns, nil
+}
+
+// NetnsCmd returns command that runs given command in container's network namespace
+// only if main process still belongs to it (PIDs may be reused after container is gone)
+func (c Container) NetnsCmd(name string, args []string) (string, []string) {
+	pid := strconv.Itoa(c.MainPID)
+
+	script := fmt.Sprintf(`[ "$(readlink /proc/%s/ns/net)" = '%s' ] && exec nsenter --target %s --net -- "$0" "$@"`,
+		pid, c.Netns, pid)
+
+	return "sh", append([]string{"-c", script, name}, args...)
+}
+
+// NetnsCmdRunner returns runner that runs commands in container's network namespace
+func (c Container) NetnsCmdRunner(cmdRunner boshsys.CmdRunner) boshsys.CmdRunner {
+	return netnsCmdRunner{cmdRunner, c}
+}
+
+type netnsCmdRunner struct {
+	boshsys.CmdRunner
+	container Container
+}
+
+func (r netnsCmdRunner) RunCommand(name string, args ...string) (string, string, int, error) {
+	name, args = r.container.NetnsCmd(name, args)
+	return r.CmdRunner.RunCommand(name, args...)
+}
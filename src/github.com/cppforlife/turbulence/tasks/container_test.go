@@ -0,0 +1,103 @@
+package tasks_test
+
+import (
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("ContainerFinder", func() {
+	var (
+		fs     *fakesys.FakeFileSystem
+		finder ContainerFinder
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		finder = NewContainerFinder(fs)
+
+		fs.SetGlob("/proc/[0-9]*/cgroup", []string{
+			"/proc/1/cgroup", "/proc/120/cgroup", "/proc/95/cgroup", "/proc/300/cgroup", "/proc/301/cgroup", "/proc/400/cgroup",
+		})
+
+		fs.WriteFileString("/proc/1/cgroup", "4:memory:/\n0::/init.scope\n")
+		fs.WriteFileString("/proc/120/cgroup", "4:memory:/garden/app-abc-1/sub\n0::/\n")
+		fs.WriteFileString("/proc/95/cgroup", "4:memory:/garden/app-abc-1\n0::/\n")
+		fs.WriteFileString("/proc/300/cgroup", "0::/garden/app-def-2\n")
+		fs.WriteFileString("/proc/301/cgroup", "0::/garden/app-def-2\n")
+		fs.WriteFileString("/proc/400/cgroup", "4:memory:/system.slice/monit.service\n")
+
+		// Process 95 was started in the container after its init process 120 (e.g. via runc exec)
+		fs.WriteFileString("/proc/95/status", "Name:\tsh\nPPid:\t50\nNSpid:\t95\t7\n")
+		fs.WriteFileString("/proc/95/stat", "95 (sh) S 50 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 900 19\n")
+		fs.WriteFileString("/proc/120/status", "Name:\tinit\nPPid:\t50\nNSpid:\t120\t1\n")
+
+		// Processes without own PID namespace; PID 300 wrapped around after 301 was started
+		fs.WriteFileString("/proc/300/status", "Name:\tapp\nPPid:\t60\nNSpid:\t300\n")
+		fs.WriteFileString("/proc/300/stat", "300 (my (app)) S 60 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 5000 19\n")
+		fs.WriteFileString("/proc/301/status", "Name:\tapp\nPPid:\t60\nNSpid:\t301\n")
+		fs.WriteFileString("/proc/301/stat", "301 (app) S 60 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 100 19\n")
+
+		fs.Symlink("net:[1]", "/proc/self/ns/net")
+		fs.Symlink("net:[2]", "/proc/120/ns/net")
+		fs.Symlink("net:[1]", "/proc/301/ns/net")
+
+		fs.WriteFileString("/proc/400/status", "Name:\tmonit\nPPid:\t1\nNSpid:\t400\n")
+		fs.WriteFileString("/proc/400/stat", "400 (monit) S 1 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 80 19\n")
+		fs.Symlink("net:[1]", "/proc/400/ns/net")
+	})
+
+	It("finds container processes by label with its init process", func() {
+		container, err := finder.Find(ContainerTarget{Label: "*-abc-*"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(container.Cgroup).To(Equal("/garden/app-abc-1"))
+		Expect(container.PIDs).To(Equal([]int{95, 120}))
+		Expect(container.MainPID).To(Equal(120))
+		Expect(container.Netns).To(ContainSubstring("net:[2]"))
+	})
+
+	It("finds container processes by cgroup and PID with the oldest process as main process", func() {
+		container, err := finder.Find(ContainerTarget{Cgroup: "/garden/app-def-2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(container.PIDs).To(Equal([]int{300, 301}))
+		Expect(container.MainPID).To(Equal(301))
+
+		container, err = finder.Find(ContainerTarget{PID: 300})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(container.PIDs).To(Equal([]int{300, 301}))
+	})
+
+	It("returns error if target matches multiple or no containers", func() {
+		_, err := finder.Find(ContainerTarget{Label: "app-*"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("'/garden/app-abc-1', '/garden/app-def-2'"))
+
+		_, err = finder.Find(ContainerTarget{Label: "missing"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("refuses containers that share network namespace with the host", func() {
+		_, err := finder.FindIsolated(ContainerTarget{PID: 300})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must not share network namespace"))
+
+		_, err = finder.FindIsolated(ContainerTarget{Label: "app-abc-*"})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = finder.FindIsolated(ContainerTarget{Label: "system.slice"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must not share network namespace"))
+	})
+
+	It("runs commands in network namespace only if main process still belongs to it", func() {
+		name, args := Container{PIDs: []int{95}, MainPID: 95, Netns: "net:[2]"}.NetnsCmd("tc", []string{"qdisc", "show"})
+		Expect(name).To(Equal("sh"))
+		Expect(args).To(Equal([]string{
+			"-c", `[ "$(readlink /proc/95/ns/net)" = 'net:[2]' ] && exec nsenter --target 95 --net -- "$0" "$@"`,
+			"tc", "qdisc", "show",
+		}))
+	})
+})
@@ -1,6 +1,12 @@
 package tasks
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -11,26 +17,97 @@ type ControlNetOptions struct {
 	Type    string
 	Timeout string // Times may be suffixed with ms,s,m,h
 
-	// slow: tc qdisc add dev eth0 root netem delay 50ms 10ms distribution normal
+	// slow: tc qdisc replace dev eth0 root netem delay 50ms 10ms distribution normal
 	Delay          string
 	DelayVariation string
 
-	// flaky: tc qdisc add dev eth0 root netem loss 20% 75%
+	// flaky: tc qdisc replace dev eth0 root netem loss 20% 75%
 	Loss            string
 	LossCorrelation string
 
-	// reset: tc qdisc del dev eth0 root
+	// gradually grow delay and loss from start values
+	Ramp *RampOptions `json:",omitempty"`
+
+	// apply to container's network interfaces instead of host's
+	Target *ContainerTarget `json:",omitempty"`
+
+	// reset: tc qdisc del dev eth0 root (followed by restoring previous qdiscs and classes)
 }
 
 func (ControlNetOptions) _private() {}
 
+var (
+	controlNetTimeRegexp    = regexp.MustCompile(`^\d+(\.\d+)?(us|ms|s)$`)
+	controlNetPercentRegexp = regexp.MustCompile(`^\d+(\.\d+)?%$`)
+)
+
+func (o ControlNetOptions) Validate() error {
+	var v validator
+
+	v.Duration("Timeout", o.Timeout)
+
+	if len(o.Delay) == 0 && len(o.Loss) == 0 {
+		v.Add("Delay", "or 'Loss' must be specified")
+	}
+
+	v.Match("Delay", o.Delay, controlNetTimeRegexp, "a time suffixed with us,ms,s")
+	v.Match("DelayVariation", o.DelayVariation, controlNetTimeRegexp, "a time suffixed with us,ms,s")
+	v.Match("Loss", o.Loss, controlNetPercentRegexp, "a percentage suffixed with %")
+	v.Match("LossCorrelation", o.LossCorrelation, controlNetPercentRegexp, "a percentage suffixed with %")
+
+	if o.Ramp != nil {
+		o.Ramp.validate(&v, o.Timeout)
+	}
+
+	if o.Target != nil {
+		o.Target.validate(&v)
+	}
+
+	return v.Err()
+}
+
+// scaled returns options with delay and loss reduced to a fraction of their values
+func (o ControlNetOptions) scaled(fraction float64) ControlNetOptions {
+	if fraction >= 1 {
+		return o
+	}
+
+	if len(o.Delay) > 0 {
+		// Delay is validated to be parsable (us, ms, s)
+		delay, _ := time.ParseDuration(o.Delay)
+		o.Delay = strconv.FormatInt(int64(float64(delay)*fraction)/int64(time.Microsecond), 10) + "us"
+	}
+
+	if len(o.Loss) > 0 {
+		loss, _ := strconv.ParseFloat(strings.TrimSuffix(o.Loss, "%"), 64)
+		o.Loss = fmt.Sprintf("%.2f%%", loss*fraction)
+	}
+
+	return o
+}
+
 type ControlNetTask struct {
-	cmdRunner boshsys.CmdRunner
-	opts      ControlNetOptions
+	cmdRunner  boshsys.CmdRunner
+	containers ContainerFinder
+	journal    *TaskJournal
+	progress   ProgressReporter
+	adjustCh   <-chan Options
+	opts       ControlNetOptions
+
+	logTag string
+	logger boshlog.Logger
 }
 
-func NewControlNetTask(cmdRunner boshsys.CmdRunner, opts ControlNetOptions, _ boshlog.Logger) ControlNetTask {
-	return ControlNetTask{cmdRunner, opts}
+func NewControlNetTask(
+	cmdRunner boshsys.CmdRunner,
+	containers ContainerFinder,
+	journal *TaskJournal,
+	progress ProgressReporter,
+	adjustCh <-chan Options,
+	opts ControlNetOptions,
+	logger boshlog.Logger,
+) ControlNetTask {
+	return ControlNetTask{cmdRunner, containers, journal, progress, adjustCh, opts, "tasks.ControlNetTask", logger}
 }
 
 func (t ControlNetTask) Execute(stopCh chan struct{}) error {
@@ -39,92 +116,173 @@ func (t ControlNetTask) Execute(stopCh chan struct{}) error {
 		return err
 	}
 
-	if len(t.opts.Delay) == 0 && len(t.opts.Loss) == 0 {
-		return bosherr.Error("Must specify delay or loss")
-	}
+	var ifaceNames []string
 
-	ifaceNames, err := NonLocalIfaceNames()
-	if err != nil {
-		return err
-	}
+	if t.opts.Target != nil {
+		container, err := t.containers.FindIsolated(*t.opts.Target)
+		if err != nil {
+			return err
+		}
 
-	if len(t.opts.Delay) > 0 {
-		variation := t.opts.DelayVariation
+		t.cmdRunner = container.NetnsCmdRunner(t.cmdRunner)
+		t.journal = t.journal.InContainer(container)
 
-		if len(variation) == 0 {
-			variation = "10ms"
+		ifaceNames, err = t.containers.IfaceNames(container)
+		if err != nil {
+			return err
 		}
-
-		for _, ifaceName := range ifaceNames {
-			err := t.configureDelay(ifaceName, t.opts.Delay, variation)
-			if err != nil {
-				return err
-			}
+	} else {
+		ifaceNames, err = NonLocalIfaceNames()
+		if err != nil {
+			return err
 		}
 	}
 
-	if len(t.opts.Loss) > 0 {
-		correlation := t.opts.LossCorrelation
+	t.progress.ReportState(TaskStateApplying)
 
-		if len(correlation) == 0 {
-			correlation = "75%"
+	r := newRamp(t.opts.Ramp)
+	defer r.Stop()
+
+	var snapshots []QdiscSnapshot
+
+	netemArgs := t.netemArgs(t.opts.scaled(r.Fraction()))
+
+	for _, ifaceName := range ifaceNames {
+		snapshot, err := t.configureIface(ifaceName, netemArgs)
+		if err != nil {
+			return err
 		}
 
-		for _, ifaceName := range ifaceNames {
-			err := t.configurePacketLoss(ifaceName, t.opts.Loss, correlation)
-			if err != nil {
-				return err
+		snapshots = append(snapshots, snapshot)
+	}
+
+	t.progress.ReportState(TaskStateHolding)
+
+	for holding := true; holding; {
+		select {
+		case <-timeoutCh:
+			if r.HasDown() {
+				r.StartDown()
+			} else {
+				holding = false
+			}
+		case <-stopCh:
+			holding = false // stopping does not wait for ramp down
+		case newOpts := <-t.adjustCh:
+			// Timeout, ramp and target of the original options continue to apply
+			adjOpts := newOpts.(ControlNetOptions)
+			adjOpts.Timeout, adjOpts.Ramp, adjOpts.Target = t.opts.Timeout, t.opts.Ramp, t.opts.Target
+			t.opts = adjOpts
+			netemArgs = t.adjust(snapshots, netemArgs, t.netemArgs(t.opts.scaled(r.Fraction())))
+		case <-r.TickCh():
+			if r.IsDone() {
+				holding = false
+			} else {
+				netemArgs = t.adjust(snapshots, netemArgs, t.netemArgs(t.opts.scaled(r.Fraction())))
 			}
 		}
 	}
 
-	select {
-	case <-timeoutCh:
-	case <-stopCh:
-	}
+	t.progress.ReportState(TaskStateReverting)
 
-	for _, ifaceName := range ifaceNames {
-		err := t.resetIface(ifaceName)
+	for _, snapshot := range snapshots {
+		err := t.restoreIface(snapshot)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return t.journal.Clear()
 }
 
-func (t ControlNetTask) configureDelay(ifaceName, delay, variation string) error {
-	args := []string{
-		"qdisc", "add", "dev", ifaceName, "root",
-		"netem", "delay", delay, variation, "distribution", "normal",
+func (t ControlNetTask) configureIface(ifaceName string, netemArgs []string) (QdiscSnapshot, error) {
+	snapshot, err := TakeQdiscSnapshot(t.cmdRunner, ifaceName)
+	if err != nil {
+		return snapshot, err
 	}
 
-	_, _, _, err := t.cmdRunner.RunCommand("tc", args...)
+	// Journal reverts in the opposite order of recording
+	restoreCmds := snapshot.RestoreCmds()
+
+	for i := len(restoreCmds) - 1; i >= 0; i-- {
+		err := t.journal.Record("tc", restoreCmds[i]...)
+		if err != nil {
+			return snapshot, bosherr.WrapError(err, "Recording tc restore")
+		}
+	}
+
+	// Replace works regardless of whether root qdisc was created by the kernel
+	args := append([]string{"qdisc", "replace", "dev", ifaceName, "root"}, netemArgs...)
+
+	_, _, _, err = t.cmdRunner.RunCommand("tc", args...)
 	if err != nil {
-		return bosherr.WrapError(err, "Shelling out to tc to add delay")
+		return snapshot, bosherr.WrapError(err, "Shelling out to tc to add netem")
 	}
 
-	return nil
+	return snapshot, nil
 }
 
-func (t ControlNetTask) configurePacketLoss(ifaceName, percent, correlation string) error {
-	args := []string{
-		"qdisc", "add", "dev", ifaceName, "root",
-		"netem", "loss", percent, correlation,
+// adjust changes netem parameters in place unless they are already applied;
+// task keeps holding previous parameters on interfaces that failed to change
+func (t ControlNetTask) adjust(snapshots []QdiscSnapshot, appliedArgs, netemArgs []string) []string {
+	if strings.Join(appliedArgs, " ") == strings.Join(netemArgs, " ") {
+		return appliedArgs
 	}
 
-	_, _, _, err := t.cmdRunner.RunCommand("tc", args...)
-	if err != nil {
-		return bosherr.WrapError(err, "Shelling out to tc to add packet loss")
+	for _, snapshot := range snapshots {
+		args := append([]string{"qdisc", "change", "dev", snapshot.IfaceName, "root"}, netemArgs...)
+
+		_, _, _, err := t.cmdRunner.RunCommand("tc", args...)
+		if err != nil {
+			t.logger.Error(t.logTag, "Failed to adjust netem for '%s': %s", snapshot.IfaceName, err)
+		}
 	}
 
-	return nil
+	return netemArgs
+}
+
+func (ControlNetTask) netemArgs(opts ControlNetOptions) []string {
+	args := []string{"netem"}
+
+	if len(opts.Delay) > 0 {
+		variation := opts.DelayVariation
+
+		if len(variation) == 0 {
+			variation = "10ms"
+		}
+
+		args = append(args, "delay", opts.Delay, variation, "distribution", "normal")
+	}
+
+	if len(opts.Loss) > 0 {
+		correlation := opts.LossCorrelation
+
+		if len(correlation) == 0 {
+			correlation = "75%"
+		}
+
+		args = append(args, "loss", opts.Loss, correlation)
+	}
+
+	return args
 }
 
-func (t ControlNetTask) resetIface(ifaceName string) error {
-	_, _, _, err := t.cmdRunner.RunCommand("tc", "qdisc", "del", "dev", ifaceName, "root")
+func (t ControlNetTask) restoreIface(snapshot QdiscSnapshot) error {
+	for _, args := range snapshot.RestoreCmds() {
+		_, _, _, err := t.cmdRunner.RunCommand("tc", args...)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Restoring tc configuration for '%s'", snapshot.IfaceName)
+		}
+	}
+
+	current, err := TakeQdiscSnapshot(t.cmdRunner, snapshot.IfaceName)
 	if err != nil {
-		return bosherr.WrapError(err, "Resetting tc")
+		return err
+	}
+
+	if !snapshot.Matches(current) {
+		return bosherr.Errorf("Restored tc configuration for '%s' does not match original: expected '%s' but was '%s'",
+			snapshot.IfaceName, snapshot.Qdiscs, current.Qdiscs)
 	}
 
 	return nil
@@ -0,0 +1,19 @@
+package tasks
+
+import (
+	"time"
+)
+
+// TestRamp exposes ramp to tasks_test without waiting for real time to pass
+type TestRamp struct{ r *ramp }
+
+func NewTestRamp(opts RampOptions) TestRamp {
+	r := newRamp(&opts)
+	r.Stop()
+	return TestRamp{r}
+}
+
+func (t TestRamp) FractionAt(elapsed time.Duration) float64 { return t.r.fractionAt(elapsed) }
+
+// StartDownAfter starts going down once elapsed time passed since ramp started
+func (t TestRamp) StartDownAfter(elapsed time.Duration) { t.r.startDownAt(t.r.startedAt.Add(elapsed)) }
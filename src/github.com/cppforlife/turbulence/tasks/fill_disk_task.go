@@ -19,6 +19,21 @@ type FillDiskOptions struct {
 
 func (FillDiskOptions) _private() {}
 
+func (FillDiskOptions) Validate() error { return nil }
+
+func (o FillDiskOptions) fillerPath() string {
+	switch {
+	case o.Persistent:
+		return "/var/vcap/store/.filler"
+	case o.Ephemeral:
+		return "/var/vcap/data/.filler"
+	case o.Temporary:
+		return "/tmp/.filler"
+	default:
+		return "/.filler"
+	}
+}
+
 type FillDiskTask struct {
 	cmdRunner boshsys.CmdRunner
 	opts      FillDiskOptions
@@ -29,19 +44,7 @@ func NewFillDiskTask(cmdRunner boshsys.CmdRunner, opts FillDiskOptions, _ boshlo
 }
 
 func (t FillDiskTask) Execute(stopCh chan struct{}) error {
-	if t.opts.Persistent {
-		return t.fill("/var/vcap/store/.filler")
-	}
-
-	if t.opts.Ephemeral {
-		return t.fill("/var/vcap/data/.filler")
-	}
-
-	if t.opts.Temporary {
-		return t.fill("/tmp/.filler")
-	}
-
-	return t.fill("/.filler")
+	return t.fill(t.opts.fillerPath())
 }
 
 func (t FillDiskTask) fill(path string) error {
@@ -1,7 +1,10 @@
 package tasks
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -14,15 +17,36 @@ type FirewallOptions struct {
 	Timeout string // Times may be suffixed with ms,s,m,h
 
 	BlockBOSHAgent bool
+
+	// Block container's traffic instead of host's
+	Target *ContainerTarget `json:",omitempty"`
 }
 
 func (FirewallOptions) _private() {}
 
+func (o FirewallOptions) Validate() error {
+	var v validator
+	v.Duration("Timeout", o.Timeout)
+
+	if o.Target != nil {
+		o.Target.validate(&v)
+	}
+
+	return v.Err()
+}
+
 type FirewallTask struct {
-	cmdRunner boshsys.CmdRunner
-	opts      FirewallOptions
+	taskID     string
+	cmdRunner  boshsys.CmdRunner
+	containers ContainerFinder
+	journal    *TaskJournal
+	progress   ProgressReporter
+	opts       FirewallOptions
 
 	allowedOutputDest []FirewallTaskDest
+
+	logTag string
+	logger boshlog.Logger
 }
 
 type FirewallTaskDest struct {
@@ -32,13 +56,42 @@ type FirewallTaskDest struct {
 	IsBOSHMbus bool
 }
 
+const (
+	// Leaves room for 'turb-' prefix and '-out' suffix
+	firewallChainIDLen   = 19
+	firewallChainHashLen = 8
+)
+
+// firewallChain is a task specific chain that built-in chain jumps to
+type firewallChain struct {
+	BuiltIn string // INPUT or OUTPUT
+	Name    string
+	Rules   []string
+}
+
 func NewFirewallTask(
+	taskID string,
 	cmdRunner boshsys.CmdRunner,
+	containers ContainerFinder,
+	journal *TaskJournal,
+	progress ProgressReporter,
 	opts FirewallOptions,
 	allowedOutputDest []FirewallTaskDest,
-	_ boshlog.Logger,
+	logger boshlog.Logger,
 ) FirewallTask {
-	return FirewallTask{cmdRunner, opts, allowedOutputDest}
+	return FirewallTask{
+		taskID:     taskID,
+		cmdRunner:  cmdRunner,
+		containers: containers,
+		journal:    journal,
+		progress:   progress,
+		opts:       opts,
+
+		allowedOutputDest: allowedOutputDest,
+
+		logTag: "tasks.FirewallTask",
+		logger: logger,
+	}
 }
 
 func (t FirewallTask) Execute(stopCh chan struct{}) error {
@@ -47,22 +100,95 @@ func (t FirewallTask) Execute(stopCh chan struct{}) error {
 		return err
 	}
 
-	rules := t.rules()
-
-	for _, r := range rules {
-		err := t.iptables("-A", r)
+	if t.opts.Target != nil {
+		container, err := t.containers.FindIsolated(*t.opts.Target)
 		if err != nil {
 			return err
 		}
+
+		// Agent and BOSH Agent traffic does not go through container's network namespace
+		t.cmdRunner = container.NetnsCmdRunner(t.cmdRunner)
+		t.journal = t.journal.InContainer(container)
+		t.allowedOutputDest = nil
+	}
+
+	tools := []string{"iptables"}
+
+	// IPv6 traffic is blocked as well when possible
+	if t.cmdRunner.CommandExists("ip6tables") {
+		tools = append(tools, "ip6tables")
+	} else {
+		t.logger.Info(t.logTag, "Skipping blocking IPv6 traffic since ip6tables is not available")
+	}
+
+	t.progress.ReportState(TaskStateApplying)
+
+	for _, tool := range tools {
+		for _, chain := range t.chains(tool == "ip6tables") {
+			err := t.addChain(tool, chain)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
+	t.progress.ReportState(TaskStateHolding)
+
 	select {
 	case <-timeoutCh:
 	case <-stopCh:
 	}
 
-	for _, r := range rules {
-		err := t.iptables("-D", r)
+	t.progress.ReportState(TaskStateReverting)
+
+	for _, tool := range tools {
+		for _, chain := range t.chains(tool == "ip6tables") {
+			err := t.removeChain(tool, chain)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return t.journal.Clear()
+}
+
+// addChain records revert commands before each change so that
+// journal can tear chain down in the reverse order
+func (t FirewallTask) addChain(tool string, chain firewallChain) error {
+	// Chain is only torn down if it was created by this task (i.e. did not exist);
+	// agent crashing right after creating it leaves behind an empty chain
+	err := t.run(tool, []string{"-N", chain.Name})
+	if err != nil {
+		return err
+	}
+
+	err = t.journal.Record(tool, "-X", chain.Name)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording firewall chain removal")
+	}
+
+	err = t.journal.Record(tool, "-F", chain.Name)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording firewall chain flush")
+	}
+
+	for _, rule := range chain.Rules {
+		err := t.run(tool, append([]string{"-A", chain.Name}, strings.Split(rule, " ")...))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Jump to the chain once all of its rules are in place
+	return t.recordAndRun(tool, t.jumpArgs("-D", chain), t.jumpArgs("-I", chain))
+}
+
+// removeChain stops sending traffic to the chain first so that
+// all of its rules stop applying at once
+func (t FirewallTask) removeChain(tool string, chain firewallChain) error {
+	for _, args := range [][]string{t.jumpArgs("-D", chain), {"-F", chain.Name}, {"-X", chain.Name}} {
+		err := t.run(tool, args)
 		if err != nil {
 			return err
 		}
@@ -71,51 +197,83 @@ func (t FirewallTask) Execute(stopCh chan struct{}) error {
 	return nil
 }
 
-func (t FirewallTask) rules() []string {
-	var rules []string
+func (t FirewallTask) recordAndRun(tool string, revertArgs, args []string) error {
+	err := t.journal.Record(tool, revertArgs...)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Recording %s change", tool)
+	}
 
-	// Allow response traffic from allowed destinations
-	inputRuleTpl := "INPUT ! -i lo -p tcp -s %s --sport %d -m state --state NEW,ESTABLISHED -j ACCEPT"
+	return t.run(tool, args)
+}
 
-	for _, dest := range t.allowedOutputDest {
-		if !t.opts.BlockBOSHAgent || !dest.IsBOSHMbus {
-			rules = append(rules, fmt.Sprintf(inputRuleTpl, dest.Host, dest.Port))
-		}
+func (t FirewallTask) run(tool string, args []string) error {
+	_, _, _, err := t.cmdRunner.RunCommand(tool, args...)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Shelling out to %s", tool)
 	}
 
-	// Allow all localhost input traffic; allow SSH traffic; drop rest
-	inputRules := []string{
-		"INPUT ! -i lo -p tcp --dport 22 -m state --state NEW,ESTABLISHED -j ACCEPT",
-		"INPUT ! -i lo -j DROP",
+	return nil
+}
+
+func (FirewallTask) jumpArgs(action string, chain firewallChain) []string {
+	return []string{action, chain.BuiltIn, "-j", chain.Name}
+}
+
+// chainName is tagged with task ID so that leftover rules could be found via 'iptables -S';
+// chain names are limited to 28 characters hence long IDs are shortened to their beginning
+// followed by a hash of the whole ID so that concurrent tasks do not share chains
+func (t FirewallTask) chainName(suffix string) string {
+	id := strings.Replace(t.taskID, "-", "", -1)
+
+	if len(id) > firewallChainIDLen {
+		sum := sha1.Sum([]byte(t.taskID))
+		hash := hex.EncodeToString(sum[:])
+		id = id[:firewallChainIDLen-firewallChainHashLen] + hash[:firewallChainHashLen]
 	}
 
-	rules = append(rules, inputRules...)
+	return fmt.Sprintf("turb-%s-%s", id, suffix)
+}
 
-	// Allow outgoing traffic to allowed destinations
-	outputRuleTpl := "OUTPUT ! -o lo -p tcp -d %s --dport %d -m state --state NEW,ESTABLISHED -j ACCEPT"
+func (t FirewallTask) chains(ipv6 bool) []firewallChain {
+	var inputRules, outputRules []string
 
 	for _, dest := range t.allowedOutputDest {
-		if !t.opts.BlockBOSHAgent || !dest.IsBOSHMbus {
-			rules = append(rules, fmt.Sprintf(outputRuleTpl, dest.Host, dest.Port))
+		if t.opts.BlockBOSHAgent && dest.IsBOSHMbus {
+			continue
 		}
-	}
 
-	// Allow all localhost output traffic; allow SSH traffic; drop rest
-	outputRules := []string{
-		"OUTPUT ! -o lo -p tcp --sport 22 -m state --state NEW,ESTABLISHED -j ACCEPT",
-		"OUTPUT ! -o lo -j DROP",
+		if t.isIPv6(dest.Host) != ipv6 {
+			continue
+		}
+
+		// Allow response traffic from allowed destinations
+		inputRules = append(inputRules, fmt.Sprintf(
+			"! -i lo -p tcp -s %s --sport %d -m state --state NEW,ESTABLISHED -j ACCEPT", dest.Host, dest.Port))
+
+		// Allow outgoing traffic to allowed destinations
+		outputRules = append(outputRules, fmt.Sprintf(
+			"! -o lo -p tcp -d %s --dport %d -m state --state NEW,ESTABLISHED -j ACCEPT", dest.Host, dest.Port))
 	}
 
-	return append(rules, outputRules...)
-}
+	// Allow all localhost traffic; allow SSH traffic; drop rest
+	inputRules = append(inputRules,
+		"! -i lo -p tcp --dport 22 -m state --state NEW,ESTABLISHED -j ACCEPT",
+		"! -i lo -j DROP",
+	)
 
-func (t FirewallTask) iptables(action, rule string) error {
-	args := append([]string{action}, strings.Split(rule, " ")...)
+	outputRules = append(outputRules,
+		"! -o lo -p tcp --sport 22 -m state --state NEW,ESTABLISHED -j ACCEPT",
+		"! -o lo -j DROP",
+	)
 
-	_, _, _, err := t.cmdRunner.RunCommand("iptables", args...)
-	if err != nil {
-		return bosherr.WrapError(err, "Shelling out to iptables")
+	return []firewallChain{
+		{BuiltIn: "INPUT", Name: t.chainName("in"), Rules: inputRules},
+		{BuiltIn: "OUTPUT", Name: t.chainName("out"), Rules: outputRules},
 	}
+}
 
-	return nil
+// Host names are only used with iptables as before
+func (FirewallTask) isIPv6(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
 }
@@ -0,0 +1,124 @@
+package tasks_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+type recordingProgress struct {
+	states []string
+}
+
+func (p *recordingProgress) ReportState(state string) { p.states = append(p.states, state) }
+
+var _ = Describe("FirewallTask", func() {
+	var (
+		fs        *fakesys.FakeFileSystem
+		cmdRunner *fakesys.FakeCmdRunner
+		progress  *recordingProgress
+		task      FirewallTask
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		progress = &recordingProgress{}
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		journal := NewJournal("/journal", fs, cmdRunner, logger)
+
+		dests := []FirewallTaskDest{{Host: "10.0.0.1", Port: 4222, IsBOSHMbus: true}}
+
+		task = NewFirewallTask("abcd1234-5678", cmdRunner, NewContainerFinder(fs), journal.ForTask("abcd1234-5678"),
+			progress, FirewallOptions{BlockBOSHAgent: true}, dests, logger)
+	})
+
+	It("adds task specific chains and tears them down after jumps to them are removed", func() {
+		stopCh := make(chan struct{})
+		close(stopCh)
+
+		Expect(task.Execute(stopCh)).ToNot(HaveOccurred())
+
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{
+			{"iptables", "-N", "turb-abcd12345678-in"},
+			{"iptables", "-A", "turb-abcd12345678-in", "!", "-i", "lo", "-p", "tcp", "--dport", "22", "-m", "state", "--state", "NEW,ESTABLISHED", "-j", "ACCEPT"},
+			{"iptables", "-A", "turb-abcd12345678-in", "!", "-i", "lo", "-j", "DROP"},
+			{"iptables", "-I", "INPUT", "-j", "turb-abcd12345678-in"},
+			{"iptables", "-N", "turb-abcd12345678-out"},
+			{"iptables", "-A", "turb-abcd12345678-out", "!", "-o", "lo", "-p", "tcp", "--sport", "22", "-m", "state", "--state", "NEW,ESTABLISHED", "-j", "ACCEPT"},
+			{"iptables", "-A", "turb-abcd12345678-out", "!", "-o", "lo", "-j", "DROP"},
+			{"iptables", "-I", "OUTPUT", "-j", "turb-abcd12345678-out"},
+			{"iptables", "-D", "INPUT", "-j", "turb-abcd12345678-in"},
+			{"iptables", "-F", "turb-abcd12345678-in"},
+			{"iptables", "-X", "turb-abcd12345678-in"},
+			{"iptables", "-D", "OUTPUT", "-j", "turb-abcd12345678-out"},
+			{"iptables", "-F", "turb-abcd12345678-out"},
+			{"iptables", "-X", "turb-abcd12345678-out"},
+		}))
+
+		Expect(progress.states).To(Equal([]string{TaskStateApplying, TaskStateHolding, TaskStateReverting}))
+		Expect(fs.FileExists("/journal/abcd1234-5678.json")).To(BeFalse())
+	})
+
+	It("uses distinct chains for tasks with IDs that share a long prefix", func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		journal := NewJournal("/journal", fs, cmdRunner, logger)
+
+		stopCh := make(chan struct{})
+		close(stopCh)
+
+		for _, id := range []string{"d77adc3b-1111-4000-8000-000000000001", "d77adc3b-1111-4000-8000-000000000002"} {
+			task := NewFirewallTask(id, cmdRunner, NewContainerFinder(fs), journal.ForTask(id),
+				progress, FirewallOptions{}, nil, logger)
+
+			Expect(task.Execute(stopCh)).ToNot(HaveOccurred())
+		}
+
+		var chains []string
+
+		for _, cmd := range cmdRunner.RunCommands {
+			if cmd[1] == "-N" {
+				Expect(len(cmd[2])).To(BeNumerically("<=", 28))
+				chains = append(chains, cmd[2])
+			}
+		}
+
+		Expect(chains).To(HaveLen(4))
+		Expect(chains[0]).To(HavePrefix("turb-d77adc3b111"))
+		Expect(chains[0]).ToNot(Equal(chains[2]))
+		Expect(chains[1]).ToNot(Equal(chains[3]))
+	})
+
+	It("does not tear down existing chain with the same name", func() {
+		cmdRunner.AddCmdResult("iptables -N turb-abcd12345678-in", fakesys.FakeCmdResult{
+			Error: errors.New("Chain already exists")})
+
+		Expect(task.Execute(make(chan struct{}))).To(HaveOccurred())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		Expect(NewJournal("/journal", fs, cmdRunner, logger).Revert("abcd1234-5678")).To(Succeed())
+
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{{"iptables", "-N", "turb-abcd12345678-in"}}))
+	})
+
+	It("also blocks IPv6 traffic when ip6tables is available", func() {
+		cmdRunner.AvailableCommands["ip6tables"] = true
+
+		stopCh := make(chan struct{})
+		close(stopCh)
+
+		Expect(task.Execute(stopCh)).ToNot(HaveOccurred())
+
+		Expect(cmdRunner.RunCommands).To(ContainElement(
+			[]string{"ip6tables", "-I", "INPUT", "-j", "turb-abcd12345678-in"}))
+		Expect(cmdRunner.RunCommands).To(ContainElement(
+			[]string{"ip6tables", "-X", "turb-abcd12345678-out"}))
+	})
+})
@@ -1,12 +1,20 @@
 package tasks
 
+import (
+	"time"
+)
+
 type Task struct {
 	ID string
 
 	Optionss OptionsSlice // todo shoudl be singular
+
+	// Task must not change the system; commands are only reported
+	DryRun bool `json:",omitempty"`
 }
 
 type Options interface {
+	Validate() error
 	_private()
 }
 
@@ -21,12 +29,36 @@ type State struct {
 }
 
 type Repo interface {
-	QueueAndWait(string, []Task) error
+	// QueueAndWait waits until agent consumes tasks or timeout passes
+	QueueAndWait(string, []Task, time.Duration) error
 	Consume(string) ([]Task, error)
 
+	// Cancel removes task (along with tasks queued together with it) from agent's inbox
+	// if agent has not consumed it yet; QueueAndWait then returns an error
+	Cancel(string) error
+
+	// Poll waits until there are new tasks for an agent or some of its active tasks
+	// need to be stopped or adjusted (excluding already stopped or adjusted tasks)
+	Poll(string, PollRequest, time.Duration) (PollResponse, error)
+
+	// Tasks that were consumed by an agent but have not finished
+	ListActive(string) ([]Task, error)
+
+	// Agent that consumed a task that has not finished
+	FetchAgentID(string) (string, bool, error)
+
 	Wait(string) (ResultRequest, error)
 	Update(string, ResultRequest) error
 
+	// Collect drops results of finished tasks that are no longer retained
+	// (also done whenever a task finishes) and fails tasks restored after restart
+	// that agents did not report executing in time
+	Collect()
+
+	// Progress is reported by agents periodically while task is running
+	FetchProgress(string) (Progress, error)
+	UpdateProgress(string, ProgressRequest) error
+
 	FetchState(string) (State, error)
 	UpdateState(string, StateRequest) error
 }
@@ -0,0 +1,246 @@
+package tasks
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Journal keeps track of system changes applied by tasks so that
+// they can be reverted if agent dies before tasks revert them.
+// Each task's changes are kept in a separate file.
+type Journal struct {
+	dir       string
+	fs        boshsys.FileSystem
+	cmdRunner boshsys.CmdRunner
+
+	logTag string
+	logger boshlog.Logger
+}
+
+type JournalCmd struct {
+	Name string
+	Args []string
+}
+
+// JournalRecord lists commands that revert changes applied by a task
+type JournalRecord struct {
+	TaskID     string
+	RevertCmds []JournalCmd
+}
+
+type TaskJournal struct {
+	journal    Journal
+	taskID     string
+	revertCmds []JournalCmd
+	dryRun     bool
+
+	// Set when changes are applied inside container's network namespace
+	container *Container
+}
+
+func NewJournal(dir string, fs boshsys.FileSystem, cmdRunner boshsys.CmdRunner, logger boshlog.Logger) Journal {
+	return Journal{
+		dir:       dir,
+		fs:        fs,
+		cmdRunner: cmdRunner,
+
+		logTag: "tasks.Journal",
+		logger: logger,
+	}
+}
+
+func (j Journal) ForTask(taskID string) *TaskJournal {
+	return &TaskJournal{journal: j, taskID: taskID}
+}
+
+// ForDryRunTask returns journal that does not record anything
+// since dry run tasks do not apply any changes
+func (j Journal) ForDryRunTask(taskID string) *TaskJournal {
+	return &TaskJournal{journal: j, taskID: taskID, dryRun: true}
+}
+
+// Records returns changes that have not been reverted yet
+func (j Journal) Records() ([]JournalRecord, error) {
+	paths, err := j.paths()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []JournalRecord
+
+	for _, path := range paths {
+		record, err := j.read(path)
+		if err != nil {
+			j.logger.Error(j.logTag, "Skipping unreadable journal record '%s': %s", path, err)
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// RevertAll reverts changes left over by all tasks (e.g. from previous agent run)
+func (j Journal) RevertAll() error {
+	paths, err := j.paths()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		err := j.revertPath(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revert reverts changes left over by a single task (e.g. if it failed half way)
+func (j Journal) Revert(taskID string) error {
+	path := j.path(taskID)
+
+	if !j.fs.FileExists(path) {
+		return nil
+	}
+
+	return j.revertPath(path)
+}
+
+func (j Journal) paths() ([]string, error) {
+	paths, err := j.fs.Glob(filepath.Join(j.dir, "*.json"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing journal records")
+	}
+
+	return paths, nil
+}
+
+func (j Journal) read(path string) (JournalRecord, error) {
+	var record JournalRecord
+
+	bytes, err := j.fs.ReadFile(path)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Reading journal record '%s'", path)
+	}
+
+	err = json.Unmarshal(bytes, &record)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Unmarshalling journal record '%s'", path)
+	}
+
+	return record, nil
+}
+
+func (j Journal) revertPath(path string) error {
+	bytes, err := j.fs.ReadFile(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading journal record '%s'", path)
+	}
+
+	var record JournalRecord
+
+	err = json.Unmarshal(bytes, &record)
+	if err != nil {
+		// Do not leave broken records since they will never be readable
+		j.logger.Error(j.logTag, "Removing unreadable journal record '%s': %s", path, err)
+		return j.fs.RemoveAll(path)
+	}
+
+	// Revert in the opposite order of how changes were applied
+	for i := len(record.RevertCmds) - 1; i >= 0; i-- {
+		cmd := record.RevertCmds[i]
+
+		j.logger.Info(j.logTag, "Reverting task '%s' change: %s %s",
+			record.TaskID, cmd.Name, strings.Join(cmd.Args, " "))
+
+		// Change may not have been applied or was already partially reverted
+		_, _, _, err := j.cmdRunner.RunCommand(cmd.Name, cmd.Args...)
+		if err != nil {
+			j.logger.Error(j.logTag, "Failed to revert task '%s' change: %s", record.TaskID, err)
+		}
+	}
+
+	err = j.fs.RemoveAll(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing journal record '%s'", path)
+	}
+
+	return nil
+}
+
+func (j Journal) write(record JournalRecord) error {
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling journal record")
+	}
+
+	err = j.fs.MkdirAll(j.dir, 0700)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating journal directory")
+	}
+
+	path := j.path(record.TaskID)
+
+	// Write and rename so that record is never partially written
+	err = j.fs.WriteFile(path+".tmp", bytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing journal record '%s'", path)
+	}
+
+	err = j.fs.Rename(path+".tmp", path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming journal record '%s'", path)
+	}
+
+	return nil
+}
+
+func (j Journal) path(taskID string) string {
+	return filepath.Join(j.dir, filepath.Base(taskID)+".json")
+}
+
+// InContainer returns journal that records commands to be run
+// in container's network namespace
+func (j *TaskJournal) InContainer(container Container) *TaskJournal {
+	return &TaskJournal{journal: j.journal, taskID: j.taskID, dryRun: j.dryRun, container: &container}
+}
+
+// Record must be called before applying a change
+// with a command that would revert that change.
+func (j *TaskJournal) Record(name string, args ...string) error {
+	if j.dryRun {
+		return nil
+	}
+
+	if j.container != nil {
+		name, args = j.container.NetnsCmd(name, args)
+	}
+
+	j.revertCmds = append(j.revertCmds, JournalCmd{Name: name, Args: args})
+
+	return j.journal.write(JournalRecord{TaskID: j.taskID, RevertCmds: j.revertCmds})
+}
+
+// Clear must be called once all changes were successfully reverted.
+func (j *TaskJournal) Clear() error {
+	j.revertCmds = nil
+
+	if j.dryRun {
+		return nil
+	}
+
+	err := j.journal.fs.RemoveAll(j.journal.path(j.taskID))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Clearing task '%s' journal record", j.taskID)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package tasks_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		fs        *fakesys.FakeFileSystem
+		cmdRunner *fakesys.FakeCmdRunner
+		journal   Journal
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		journal = NewJournal("/journal", fs, cmdRunner, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("reverts recorded changes in reverse order and removes record", func() {
+		taskJournal := journal.ForTask("task-id")
+
+		Expect(taskJournal.Record("iptables", "-D", "rule1")).ToNot(HaveOccurred())
+		Expect(taskJournal.Record("iptables", "-D", "rule2")).ToNot(HaveOccurred())
+		Expect(fs.FileExists("/journal/task-id.json")).To(BeTrue())
+
+		fs.SetGlob("/journal/*.json", []string{"/journal/task-id.json"})
+
+		Expect(journal.RevertAll()).ToNot(HaveOccurred())
+
+		Expect(cmdRunner.RunCommands).To(Equal([][]string{
+			{"iptables", "-D", "rule2"},
+			{"iptables", "-D", "rule1"},
+		}))
+
+		Expect(fs.FileExists("/journal/task-id.json")).To(BeFalse())
+	})
+
+	It("does not revert anything after record was cleared", func() {
+		taskJournal := journal.ForTask("task-id")
+
+		Expect(taskJournal.Record("tc", "qdisc", "del", "dev", "eth0", "root")).ToNot(HaveOccurred())
+		Expect(taskJournal.Clear()).ToNot(HaveOccurred())
+
+		Expect(journal.Revert("task-id")).ToNot(HaveOccurred())
+		Expect(cmdRunner.RunCommands).To(BeEmpty())
+	})
+})
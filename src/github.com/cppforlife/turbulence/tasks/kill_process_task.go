@@ -3,6 +3,7 @@ package tasks
 import (
 	"math/rand"
 	"path/filepath"
+	"regexp"
 	"strconv"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -23,13 +24,41 @@ type KillProcessOptions struct {
 	MonitoredProcessName string
 
 	// If names are empty, randomly selected monitored process is killed
+
+	// Optionally kill container processes instead of host's; ProcessName is matched
+	// against container processes, otherwise container's init process is killed.
+	// Container must not share network namespace with the host
+	Target *ContainerTarget `json:",omitempty"`
 }
 
 func (KillProcessOptions) _private() {}
 
+func (o KillProcessOptions) Validate() error {
+	var v validator
+
+	if _, err := filepath.Match(o.MonitoredProcessName, ""); err != nil {
+		v.Add("MonitoredProcessName", "must be a valid pattern (got '%s')", o.MonitoredProcessName)
+	}
+
+	if o.Target != nil {
+		o.Target.validate(&v)
+
+		if len(o.MonitoredProcessName) > 0 {
+			v.Add("MonitoredProcessName", "cannot be used with 'Target'")
+		}
+
+		if _, err := regexp.Compile(o.ProcessName); err != nil {
+			v.Add("ProcessName", "must be a valid regular expression (got '%s')", o.ProcessName)
+		}
+	}
+
+	return v.Err()
+}
+
 type KillProcessTask struct {
 	monitClient monit.Client
 	cmdRunner   boshsys.CmdRunner
+	containers  ContainerFinder
 	opts        KillProcessOptions
 
 	logTag string
@@ -39,13 +68,18 @@ type KillProcessTask struct {
 func NewKillProcessTask(
 	monitClient monit.Client,
 	cmdRunner boshsys.CmdRunner,
+	containers ContainerFinder,
 	opts KillProcessOptions,
 	logger boshlog.Logger,
 ) KillProcessTask {
-	return KillProcessTask{monitClient, cmdRunner, opts, "tasks.KillProcessTask", logger}
+	return KillProcessTask{monitClient, cmdRunner, containers, opts, "tasks.KillProcessTask", logger}
 }
 
 func (t KillProcessTask) Execute(stopCh chan struct{}) error {
+	if t.opts.Target != nil {
+		return t.killContainerProcesses(*t.opts.Target, t.opts.ProcessName)
+	}
+
 	if len(t.opts.ProcessName) > 0 {
 		return t.killProcesses(t.opts.ProcessName)
 	}
@@ -68,6 +102,50 @@ func (t KillProcessTask) killProcesses(name string) error {
 	return nil
 }
 
+func (t KillProcessTask) killContainerProcesses(target ContainerTarget, name string) error {
+	// Host cgroups (e.g. /system.slice) would include agent, monit and other host services
+	container, err := t.containers.FindIsolated(target)
+	if err != nil {
+		return err
+	}
+
+	if len(name) == 0 {
+		return t.killService(monit.Service{Name: container.Cgroup, PID: container.MainPID})
+	}
+
+	// Validated to be a regular expression
+	nameRegexp := regexp.MustCompile(name)
+
+	var matchedServices []monit.Service
+
+	for _, pid := range container.PIDs {
+		processName, err := t.containers.ProcessName(pid)
+		if err != nil {
+			t.logger.Debug(t.logTag, "Skipping process '%d' that may have exited: %s", pid, err)
+			continue
+		}
+
+		if nameRegexp.MatchString(processName) {
+			matchedServices = append(matchedServices, monit.Service{Name: processName, PID: pid})
+		}
+	}
+
+	if len(matchedServices) == 0 {
+		return bosherr.Errorf("Process '%s' must match at least one process in container '%s'", name, container.Cgroup)
+	}
+
+	var firstErr error
+
+	for _, service := range matchedServices {
+		err := t.killService(service)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
 func (t KillProcessTask) killMatchingServices(name string) error {
 	services, err := t.monitClient.Services()
 	if err != nil {
@@ -6,3 +6,5 @@ type KillOptions struct {
 }
 
 func (KillOptions) _private() {}
+
+func (KillOptions) Validate() error { return nil }
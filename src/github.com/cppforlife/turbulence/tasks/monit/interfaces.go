@@ -1,5 +1,11 @@
 package monit
 
+// Provider returns clients of a process manager (e.g. monit or systemd)
+type Provider interface {
+	IsAvailable() bool
+	Get() (Client, error)
+}
+
 type Client interface {
 	Services() ([]Service, error)
 }
@@ -11,29 +11,40 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-var (
-	monitCredsPath = "/var/vcap/monit/monit.user"
-	monitHost      = "127.0.0.1:2822"
+const (
+	DefaultCredsPath = "/var/vcap/monit/monit.user"
+	DefaultHost      = "127.0.0.1:2822"
 )
 
 type ClientProvider struct {
+	credsPath string
+	host      string
+
 	fs     boshsys.FileSystem
 	logger boshlog.Logger
 }
 
-func NewClientProvider(fs boshsys.FileSystem, logger boshlog.Logger) ClientProvider {
-	return ClientProvider{fs: fs, logger: logger}
+func NewClientProvider(credsPath, host string, fs boshsys.FileSystem, logger boshlog.Logger) ClientProvider {
+	return ClientProvider{credsPath: credsPath, host: host, fs: fs, logger: logger}
+}
+
+// IsAvailable returns true if monit credentials are present
+func (p ClientProvider) IsAvailable() bool {
+	return p.fs.FileExists(p.credsPath)
 }
 
 func (p ClientProvider) Get() (Client, error) {
-	credsStr, err := p.fs.ReadFileString(monitCredsPath)
+	credsStr, err := p.fs.ReadFileString(p.credsPath)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Getting monit credentials")
 	}
 
 	creds := strings.SplitN(credsStr, ":", 2)
+	if len(creds) != 2 {
+		return nil, bosherr.Errorf("Expected monit credentials in '%s' to be formatted as 'username:password'", p.credsPath)
+	}
 
 	httpClient := boshhttp.NewRetryClient(http.DefaultClient, 2, 1*time.Second, p.logger)
 
-	return NewHTTPClient(monitHost, creds[0], creds[1], httpClient, p.logger), nil
+	return NewHTTPClient(p.host, creds[0], creds[1], httpClient, p.logger), nil
 }
@@ -7,6 +7,8 @@ type NoopOptions struct {
 
 func (NoopOptions) _private() {}
 
+func (NoopOptions) Validate() error { return nil }
+
 type NoopTask struct {
 	opts NoopOptions
 }
@@ -14,6 +14,16 @@ func OptionsType(taskOpts Options) string {
 	return strings.TrimSuffix(t, "Options")
 }
 
+func (s OptionsSlice) Validate() error {
+	var errs []error
+
+	for i, o := range s {
+		errs = append(errs, NewValidationErrorWithPrefix(fmt.Sprintf("[%d]", i), o.Validate()))
+	}
+
+	return MergeValidationErrors(errs...)
+}
+
 func (s *OptionsSlice) UnmarshalJSON(data []byte) error {
 	var maps []map[string]interface{}
 
@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"time"
+)
+
+// States reported by tasks while they are running
+const (
+	TaskStateApplying  = "applying"
+	TaskStateHolding   = "holding"
+	TaskStateReverting = "reverting"
+)
+
+// Only the tail of the output is kept for each task
+const maxProgressOutputLen = 64 * 1024
+
+type Progress struct {
+	State       string
+	HeartbeatAt time.Time
+
+	// Captured output of commands run by the task
+	Output string
+}
+
+// ProgressReporter is notified when task moves from one state to another
+type ProgressReporter interface {
+	ReportState(string)
+}
+
+func (p Progress) Apply(req ProgressRequest, now time.Time) Progress {
+	if len(req.State) > 0 {
+		p.State = req.State
+	}
+
+	p.HeartbeatAt = now
+	p.Output += req.Output
+
+	if len(p.Output) > maxProgressOutputLen {
+		p.Output = p.Output[len(p.Output)-maxProgressOutputLen:]
+	}
+
+	return p
+}
@@ -0,0 +1,36 @@
+package tasks_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Progress", func() {
+	Describe("Apply", func() {
+		now := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+		It("keeps last state for heartbeats and appends output", func() {
+			p := Progress{}.Apply(ProgressRequest{State: TaskStateHolding, Output: "out1\n"}, now)
+			p = p.Apply(ProgressRequest{Output: "out2\n"}, now.Add(time.Second))
+
+			Expect(p).To(Equal(Progress{
+				State:       TaskStateHolding,
+				HeartbeatAt: now.Add(time.Second),
+				Output:      "out1\nout2\n",
+			}))
+		})
+
+		It("keeps only the tail of the output", func() {
+			p := Progress{}.Apply(ProgressRequest{Output: strings.Repeat("a", 64*1024)}, now)
+			p = p.Apply(ProgressRequest{Output: "b"}, now)
+
+			Expect(len(p.Output)).To(Equal(64 * 1024))
+			Expect(strings.HasSuffix(p.Output, "ab")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,369 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// QdiscSnapshot captures qdisc, class and filter tree attached to the root of an interface
+// so that it could be restored after netem qdisc replaces it.
+type QdiscSnapshot struct {
+	IfaceName string
+
+	Qdiscs  string // output of 'tc qdisc show'
+	Classes string // output of 'tc class show'
+	Filters string // output of 'tc filter show' for each qdisc and class
+}
+
+func TakeQdiscSnapshot(cmdRunner boshsys.CmdRunner, ifaceName string) (QdiscSnapshot, error) {
+	snapshot := QdiscSnapshot{IfaceName: ifaceName}
+
+	qdiscs, _, _, err := cmdRunner.RunCommand("tc", "qdisc", "show", "dev", ifaceName)
+	if err != nil {
+		return snapshot, bosherr.WrapErrorf(err, "Listing qdiscs for '%s'", ifaceName)
+	}
+
+	classes, _, _, err := cmdRunner.RunCommand("tc", "class", "show", "dev", ifaceName)
+	if err != nil {
+		return snapshot, bosherr.WrapErrorf(err, "Listing classes for '%s'", ifaceName)
+	}
+
+	snapshot.Qdiscs = snapshot.normalize(qdiscs)
+	snapshot.Classes = snapshot.normalize(classes)
+
+	// Kernel created qdiscs do not have filters that task would remove
+	if snapshot.IsDefault() {
+		return snapshot, nil
+	}
+
+	var filters []string
+
+	for _, parent := range snapshot.filterParents() {
+		output, _, _, err := cmdRunner.RunCommand("tc", "filter", "show", "dev", ifaceName, "parent", parent)
+		if err != nil {
+			return snapshot, bosherr.WrapErrorf(err, "Listing filters for '%s'", ifaceName)
+		}
+
+		filters = append(filters, output)
+	}
+
+	snapshot.Filters = snapshot.normalize(strings.Join(filters, "\n"))
+
+	// Refuse to touch interface instead of losing its configuration
+	_, err = snapshot.filterCmds()
+	if err != nil {
+		return snapshot, bosherr.WrapErrorf(err, "Interface '%s' has tc filters which cannot be restored after the task", ifaceName)
+	}
+
+	return snapshot, nil
+}
+
+// IsDefault returns true when interface uses qdiscs created by the kernel;
+// they are recreated by the kernel once root qdisc is deleted.
+func (s QdiscSnapshot) IsDefault() bool {
+	for _, line := range s.lines(s.Qdiscs) {
+		fields := strings.Fields(line)
+
+		if len(fields) < 3 || s.isIngress(fields) {
+			continue
+		}
+
+		if fields[2] != "0:" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RestoreCmds return tc arguments that restore interface's configuration
+// assuming that it currently has a root qdisc installed by the task
+func (s QdiscSnapshot) RestoreCmds() [][]string {
+	cmds := [][]string{{"qdisc", "del", "dev", s.IfaceName, "root"}}
+
+	if s.IsDefault() {
+		return cmds
+	}
+
+	for _, line := range s.lines(s.Qdiscs) {
+		fields := strings.Fields(line)
+
+		// e.g. qdisc htb 1: root r2q 10 default 0x10 direct_packets_stat 0
+		if len(fields) < 4 || s.isIngress(fields) {
+			continue
+		}
+
+		kind, handle, rest := fields[1], fields[2], fields[3:]
+		args := []string{"qdisc", "add", "dev", s.IfaceName}
+
+		if rest[0] == "root" {
+			args = append(args, "root")
+			rest = rest[1:]
+		} else if rest[0] == "parent" && len(rest) > 1 {
+			args = append(args, "parent", rest[1])
+			rest = rest[2:]
+		}
+
+		args = append(args, "handle", handle, kind)
+		cmds = append(cmds, append(args, s.withoutStats(rest)...))
+	}
+
+	for _, line := range s.lines(s.Classes) {
+		fields := strings.Fields(line)
+
+		// e.g. class htb 1:10 parent 1:1 leaf 10: prio 0 rate 1Mbit ceil 1Mbit burst 1600b cburst 1600b
+		if len(fields) < 4 {
+			continue
+		}
+
+		kind, classID, rest := fields[1], fields[2], fields[3:]
+		parent := strings.SplitN(classID, ":", 2)[0] + ":"
+
+		if rest[0] == "root" {
+			rest = rest[1:]
+		} else if rest[0] == "parent" && len(rest) > 1 {
+			parent = rest[1]
+			rest = rest[2:]
+		}
+
+		args := []string{"class", "add", "dev", s.IfaceName, "parent", parent, "classid", classID, kind}
+		cmds = append(cmds, append(args, s.withoutStats(rest)...))
+	}
+
+	// Filters were checked when snapshot was taken
+	filterCmds, _ := s.filterCmds()
+
+	return append(cmds, filterCmds...)
+}
+
+// Matches checks that current configuration is the same as captured one
+func (s QdiscSnapshot) Matches(current QdiscSnapshot) bool {
+	if s.IsDefault() && current.IsDefault() {
+		return true // kernel may pick different default qdisc handles
+	}
+
+	if s.Qdiscs != current.Qdiscs || s.Classes != current.Classes {
+		return false
+	}
+
+	// Filter output includes kernel assigned handles; compare how they would be restored
+	filterCmds, _ := s.filterCmds()
+	currentFilterCmds, _ := current.filterCmds()
+
+	return reflect.DeepEqual(filterCmds, currentFilterCmds)
+}
+
+// filterParents returns handles of qdiscs and classes that filters may be attached to
+func (s QdiscSnapshot) filterParents() []string {
+	var parents []string
+
+	for _, line := range s.lines(s.Qdiscs) {
+		fields := strings.Fields(line)
+
+		if len(fields) < 3 || s.isIngress(fields) {
+			continue
+		}
+
+		parents = append(parents, fields[2])
+	}
+
+	for _, line := range s.lines(s.Classes) {
+		fields := strings.Fields(line)
+
+		if len(fields) < 3 {
+			continue
+		}
+
+		parents = append(parents, fields[2])
+	}
+
+	return parents
+}
+
+// filterCmds turns u32 and fw filters back into tc arguments;
+// other kinds of filters and actions cannot be reliably restored
+func (s QdiscSnapshot) filterCmds() ([][]string, error) {
+	var cmds [][]string
+	var u32Args []string
+
+	for _, line := range s.lines(s.Filters) {
+		fields := strings.Fields(line)
+
+		if fields[0] == "match" && u32Args != nil {
+			// e.g. match 0a000001/ffffffff at 16
+			if len(fields) != 4 || fields[2] != "at" {
+				return nil, bosherr.Errorf("Unexpected u32 match '%s'", line)
+			}
+
+			value := strings.SplitN(fields[1], "/", 2)
+			if len(value) != 2 {
+				return nil, bosherr.Errorf("Unexpected u32 match '%s'", line)
+			}
+
+			u32Args = append(u32Args, "match", "u32", value[0], value[1], "at", fields[3])
+			continue
+		}
+
+		if u32Args != nil {
+			cmds = append(cmds, u32Args)
+			u32Args = nil
+		}
+
+		if fields[0] != "filter" {
+			return nil, bosherr.Errorf("Unexpected filter configuration '%s'", line)
+		}
+
+		args, kind, err := s.filterArgs(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		// Matches of u32 filter entry follow on separate lines
+		if kind == "u32" && len(args) > 0 {
+			u32Args = args
+		} else if len(args) > 0 {
+			cmds = append(cmds, args)
+		}
+	}
+
+	if u32Args != nil {
+		cmds = append(cmds, u32Args)
+	}
+
+	return cmds, nil
+}
+
+// filterArgs returns arguments for a filter entry; nil for lines that only
+// describe filter's chain or hash table which are created with the filter.
+// e.g. filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::800 order 2048 key ht 800 bkt 0 flowid 1:10
+// e.g. filter parent 1: protocol ip pref 2 fw chain 0 handle 0x6 classid 1:10
+func (s QdiscSnapshot) filterArgs(fields []string) ([]string, string, error) {
+	line := strings.Join(fields, " ")
+	args := []string{"filter", "add", "dev", s.IfaceName}
+
+	var kind string
+
+	for len(fields) > 0 && len(kind) == 0 {
+		switch fields[0] {
+		case "parent", "protocol", "pref":
+			if len(fields) < 2 {
+				return nil, "", bosherr.Errorf("Unexpected filter '%s'", line)
+			}
+			args = append(args, fields[0], fields[1])
+			fields = fields[2:]
+		default:
+			kind = fields[0]
+			fields = fields[1:]
+		}
+	}
+
+	var handle, flowID string
+	var isEntry bool
+
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "chain" && i+1 < len(fields):
+			if fields[i+1] != "0" {
+				return nil, "", bosherr.Errorf("Unexpected filter chain in '%s'", line)
+			}
+			i++
+		case fields[i] == "fh" && i+1 < len(fields) && kind == "u32":
+			// Only entries of the default hash table are supported, e.g. 800::800
+			if strings.HasSuffix(fields[i+1], ":") {
+				if fields[i+1] != "800:" {
+					return nil, "", bosherr.Errorf("Unexpected u32 hash table in '%s'", line)
+				}
+				return nil, kind, nil
+			}
+			if !strings.HasPrefix(fields[i+1], "800:") {
+				return nil, "", bosherr.Errorf("Unexpected u32 hash table in '%s'", line)
+			}
+			isEntry = true
+			i++
+		case (fields[i] == "order" || fields[i] == "bkt") && i+1 < len(fields) && kind == "u32":
+			i++
+		case fields[i] == "key" && i+2 < len(fields) && fields[i+1] == "ht" && kind == "u32":
+			if fields[i+2] != "800" {
+				return nil, "", bosherr.Errorf("Unexpected u32 hash table in '%s'", line)
+			}
+			i += 2
+		case fields[i] == "handle" && i+1 < len(fields) && kind == "fw":
+			handle = fields[i+1]
+			isEntry = true
+			i++
+		case (fields[i] == "flowid" || fields[i] == "classid") && i+1 < len(fields):
+			flowID = fields[i+1]
+			i++
+		case fields[i] == "not_in_hw" || fields[i] == "in_hw" || fields[i] == "terminal":
+		default:
+			return nil, "", bosherr.Errorf("Unexpected '%s' in filter '%s'", fields[i], line)
+		}
+	}
+
+	switch kind {
+	case "u32", "fw":
+	default:
+		return nil, kind, bosherr.Errorf("Unexpected filter kind '%s'", kind)
+	}
+
+	if !isEntry {
+		return nil, kind, nil
+	}
+
+	if len(handle) > 0 {
+		args = append(args, "handle", handle)
+	}
+
+	args = append(args, kind)
+
+	if len(flowID) > 0 {
+		args = append(args, "classid", flowID)
+	}
+
+	return args, kind, nil
+}
+
+func (QdiscSnapshot) isIngress(fields []string) bool {
+	return len(fields) > 1 && fields[1] == "ingress"
+}
+
+// withoutStats removes values that are shown but cannot be configured
+func (QdiscSnapshot) withoutStats(fields []string) []string {
+	var result []string
+
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "refcnt", "direct_packets_stat", "leaf":
+			i++ // skip value
+		default:
+			result = append(result, fields[i])
+		}
+	}
+
+	return result
+}
+
+func (s QdiscSnapshot) normalize(output string) string {
+	var lines []string
+
+	for _, line := range s.lines(output) {
+		lines = append(lines, strings.Join(s.withoutStats(strings.Fields(line)), " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (QdiscSnapshot) lines(output string) []string {
+	var lines []string
+
+	for _, line := range strings.Split(output, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
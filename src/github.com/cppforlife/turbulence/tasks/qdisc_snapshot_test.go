@@ -0,0 +1,115 @@
+package tasks_test
+
+import (
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("QdiscSnapshot", func() {
+	var (
+		cmdRunner *fakesys.FakeCmdRunner
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+	})
+
+	It("only deletes root qdisc when interface uses kernel defaults", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{
+			Stdout: "qdisc mq 0: root\nqdisc fq_codel 0: parent :1 limit 10240p flows 1024\nqdisc ingress ffff: parent ffff:fff1 ----------------\n",
+		})
+
+		snapshot, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(snapshot.IsDefault()).To(BeTrue())
+		Expect(snapshot.RestoreCmds()).To(Equal([][]string{{"qdisc", "del", "dev", "eth0", "root"}}))
+	})
+
+	It("restores custom qdiscs and classes", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{
+			Stdout: "qdisc htb 1: root refcnt 2 r2q 10 default 0x10 direct_packets_stat 0\nqdisc sfq 10: parent 1:10 limit 127p quantum 1514b\n",
+		})
+		cmdRunner.AddCmdResult("tc class show dev eth0", fakesys.FakeCmdResult{
+			Stdout: "class htb 1:10 root leaf 10: prio 0 rate 1Mbit ceil 1Mbit burst 1600b cburst 1600b\n",
+		})
+
+		snapshot, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(snapshot.IsDefault()).To(BeFalse())
+		Expect(snapshot.RestoreCmds()).To(Equal([][]string{
+			{"qdisc", "del", "dev", "eth0", "root"},
+			{"qdisc", "add", "dev", "eth0", "root", "handle", "1:", "htb", "r2q", "10", "default", "0x10"},
+			{"qdisc", "add", "dev", "eth0", "parent", "1:10", "handle", "10:", "sfq", "limit", "127p", "quantum", "1514b"},
+			{"class", "add", "dev", "eth0", "parent", "1:", "classid", "1:10", "htb", "prio", "0", "rate", "1Mbit", "ceil", "1Mbit", "burst", "1600b", "cburst", "1600b"},
+		}))
+	})
+
+	It("restores filters attached to custom qdiscs and classes", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root refcnt 2 bands 3 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1\n"})
+		cmdRunner.AddCmdResult("tc filter show dev eth0 parent 1:", fakesys.FakeCmdResult{
+			Stdout: `filter parent 1: protocol ip pref 1 u32 chain 0
+filter parent 1: protocol ip pref 1 u32 chain 0 fh 800: ht divisor 1
+filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::800 order 2048 key ht 800 bkt 0 flowid 1:1 not_in_hw
+  match 0a000001/ffffffff at 16
+  match 00160000/ffff0000 at 20
+filter parent 1: protocol ip pref 2 fw chain 0
+filter parent 1: protocol ip pref 2 fw chain 0 handle 0x6 classid 1:3
+`,
+		})
+
+		snapshot, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(snapshot.RestoreCmds()).To(Equal([][]string{
+			{"qdisc", "del", "dev", "eth0", "root"},
+			{"qdisc", "add", "dev", "eth0", "root", "handle", "1:", "prio", "bands", "3", "priomap", "1", "2", "2", "2", "1", "2", "0", "0", "1", "1", "1", "1", "1", "1", "1", "1"},
+			{"filter", "add", "dev", "eth0", "parent", "1:", "protocol", "ip", "pref", "1", "u32", "classid", "1:1",
+				"match", "u32", "0a000001", "ffffffff", "at", "16", "match", "u32", "00160000", "ffff0000", "at", "20"},
+			{"filter", "add", "dev", "eth0", "parent", "1:", "protocol", "ip", "pref", "2", "handle", "0x6", "fw", "classid", "1:3"},
+		}))
+	})
+
+	It("matches restored filters regardless of kernel assigned handles", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root bands 3\n"})
+		cmdRunner.AddCmdResult("tc filter show dev eth0 parent 1:", fakesys.FakeCmdResult{
+			Stdout: "filter parent 1: protocol ip pref 1 u32 fh 800::800 order 2048 key ht 800 bkt 0 flowid 1:1\n  match 0a000001/ffffffff at 16\n",
+		})
+
+		snapshot, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root bands 3\n"})
+		cmdRunner.AddCmdResult("tc filter show dev eth0 parent 1:", fakesys.FakeCmdResult{
+			Stdout: "filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::801 order 2049 key ht 800 bkt 0 flowid 1:1 not_in_hw\n  match 0a000001/ffffffff at 16\n",
+		})
+
+		current, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Matches(current)).To(BeTrue())
+
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root bands 3\n"})
+		cmdRunner.AddCmdResult("tc filter show dev eth0 parent 1:", fakesys.FakeCmdResult{})
+
+		current, err = TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Matches(current)).To(BeFalse())
+	})
+
+	It("refuses custom configuration with filters that cannot be restored", func() {
+		cmdRunner.AddCmdResult("tc qdisc show dev eth0", fakesys.FakeCmdResult{Stdout: "qdisc prio 1: root bands 3\n"})
+		cmdRunner.AddCmdResult("tc filter show dev eth0 parent 1:", fakesys.FakeCmdResult{
+			Stdout: "filter parent 1: protocol ip pref 1 flower chain 0\nfilter parent 1: protocol ip pref 1 flower chain 0 handle 0x1\n  dst_ip 10.0.0.1\n",
+		})
+
+		_, err := TakeQdiscSnapshot(cmdRunner, "eth0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cannot be restored"))
+		Expect(err.Error()).To(ContainSubstring("Unexpected filter kind 'flower'"))
+	})
+})
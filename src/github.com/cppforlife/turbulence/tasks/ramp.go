@@ -0,0 +1,142 @@
+package tasks
+
+import (
+	"math"
+	"time"
+)
+
+// RampOptions gradually grows fault from a percentage of target values
+// to target values instead of applying target values right away
+type RampOptions struct {
+	Duration string // Times may be suffixed with ms,s,m,h
+
+	// Values change in equal increments; linearly (every second) if not set
+	Steps int
+
+	StartPercent int // Percentage of target values to start with; defaults to 0
+
+	// Ramps back down to start values at the same rate once timeout is reached
+	// (from values applied at that time if ramp up did not finish)
+	Down bool
+}
+
+func (o RampOptions) validate(v *validator, timeout string) {
+	if len(o.Duration) == 0 {
+		v.Add("Ramp.Duration", "must be specified")
+	}
+
+	v.Duration("Ramp.Duration", o.Duration)
+	v.NonNegative("Ramp.Steps", o.Steps)
+
+	if o.StartPercent < 0 || o.StartPercent >= 100 {
+		v.Add("Ramp.StartPercent", "must be between 0 and 99 (got %d)", o.StartPercent)
+	}
+
+	if o.Down && len(timeout) == 0 {
+		v.Add("Ramp.Down", "requires 'Timeout' to be specified")
+	}
+}
+
+var rampTickInterval = time.Second
+
+// ramp keeps track of how much of target values should be currently applied
+type ramp struct {
+	opts     RampOptions
+	duration time.Duration
+
+	startedAt time.Time
+	down      bool
+	downFrom  float64 // progress reached by the time ramp started going down
+
+	ticker *time.Ticker
+}
+
+// newRamp returns nil if options do not include a ramp
+func newRamp(opts *RampOptions) *ramp {
+	if opts == nil {
+		return nil
+	}
+
+	// Duration is validated before task is created
+	duration, _ := time.ParseDuration(opts.Duration)
+
+	return &ramp{
+		opts:     *opts,
+		duration: duration,
+
+		startedAt: time.Now(),
+		ticker:    time.NewTicker(rampTickInterval),
+	}
+}
+
+// TickCh fires when applied values may need to change; never fires without a ramp
+func (r *ramp) TickCh() <-chan time.Time {
+	if r == nil {
+		return nil
+	}
+	return r.ticker.C
+}
+
+func (r *ramp) Stop() {
+	if r != nil {
+		r.ticker.Stop()
+	}
+}
+
+// HasDown returns true if ramp should start going down once timeout is reached
+func (r *ramp) HasDown() bool { return r != nil && r.opts.Down }
+
+func (r *ramp) StartDown() { r.startDownAt(time.Now()) }
+
+func (r *ramp) startDownAt(now time.Time) {
+	r.downFrom = r.progressAt(now.Sub(r.startedAt))
+	r.down = true
+	r.startedAt = now
+}
+
+// IsDone returns true once ramp has reached start values on its way down
+func (r *ramp) IsDone() bool {
+	return r != nil && r.down && r.fractionAt(time.Now().Sub(r.startedAt)) <= r.startFraction()
+}
+
+// Fraction returns portion of target values (between 0 and 1) to apply now
+func (r *ramp) Fraction() float64 {
+	if r == nil {
+		return 1
+	}
+	return r.fractionAt(time.Now().Sub(r.startedAt))
+}
+
+func (r *ramp) fractionAt(elapsed time.Duration) float64 {
+	progress := r.progressAt(elapsed)
+
+	if r.down {
+		progress = math.Max(r.downFrom-progress, 0)
+	}
+
+	start := r.startFraction()
+
+	return start + (1-start)*progress
+}
+
+func (r *ramp) startFraction() float64 { return float64(r.opts.StartPercent) / 100 }
+
+// progressAt returns how far (between 0 and 1) ramp went in elapsed time
+func (r *ramp) progressAt(elapsed time.Duration) float64 {
+	progress := 1.0
+
+	if r.duration > 0 && elapsed < r.duration {
+		progress = float64(elapsed) / float64(r.duration)
+
+		if r.opts.Steps > 0 {
+			steps := float64(r.opts.Steps)
+			progress = math.Floor(progress*steps) / steps
+		}
+	}
+
+	if progress < 0 {
+		progress = 0
+	}
+
+	return progress
+}
@@ -0,0 +1,58 @@
+package tasks_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Ramp", func() {
+	It("grows from start values to target values", func() {
+		ramp := NewTestRamp(RampOptions{Duration: "10s", StartPercent: 20})
+
+		Expect(ramp.FractionAt(0)).To(BeNumerically("~", 0.2))
+		Expect(ramp.FractionAt(5 * time.Second)).To(BeNumerically("~", 0.6))
+		Expect(ramp.FractionAt(10 * time.Second)).To(BeNumerically("~", 1))
+		Expect(ramp.FractionAt(time.Minute)).To(BeNumerically("~", 1))
+	})
+
+	It("changes values in equal steps", func() {
+		ramp := NewTestRamp(RampOptions{Duration: "10s", Steps: 4})
+
+		Expect(ramp.FractionAt(2 * time.Second)).To(BeNumerically("~", 0))
+		Expect(ramp.FractionAt(3 * time.Second)).To(BeNumerically("~", 0.25))
+		Expect(ramp.FractionAt(9 * time.Second)).To(BeNumerically("~", 0.75))
+	})
+
+	It("goes down from target values once ramp up finished", func() {
+		ramp := NewTestRamp(RampOptions{Duration: "10s", Down: true})
+		ramp.StartDownAfter(time.Minute)
+
+		Expect(ramp.FractionAt(0)).To(BeNumerically("~", 1))
+		Expect(ramp.FractionAt(5 * time.Second)).To(BeNumerically("~", 0.5))
+		Expect(ramp.FractionAt(10 * time.Second)).To(BeNumerically("~", 0))
+	})
+
+	It("goes down from currently applied values if ramp up did not finish", func() {
+		ramp := NewTestRamp(RampOptions{Duration: "10s", StartPercent: 20, Down: true})
+		ramp.StartDownAfter(5 * time.Second)
+
+		Expect(ramp.FractionAt(0)).To(BeNumerically("~", 0.6))
+		Expect(ramp.FractionAt(2 * time.Second)).To(BeNumerically("~", 0.44))
+		Expect(ramp.FractionAt(5 * time.Second)).To(BeNumerically("~", 0.2))
+		Expect(ramp.FractionAt(time.Minute)).To(BeNumerically("~", 0.2))
+	})
+
+	It("goes down in steps from currently applied step", func() {
+		ramp := NewTestRamp(RampOptions{Duration: "10s", Steps: 4, Down: true})
+		ramp.StartDownAfter(6 * time.Second)
+
+		Expect(ramp.FractionAt(0)).To(BeNumerically("~", 0.5))
+		Expect(ramp.FractionAt(2 * time.Second)).To(BeNumerically("~", 0.5))
+		Expect(ramp.FractionAt(3 * time.Second)).To(BeNumerically("~", 0.25))
+		Expect(ramp.FractionAt(5 * time.Second)).To(BeNumerically("~", 0))
+	})
+})
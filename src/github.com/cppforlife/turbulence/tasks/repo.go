@@ -1,23 +1,45 @@
 package tasks
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	"github.com/cppforlife/turbulence/storage"
 )
 
+// Active tasks are persisted so that they can still be stopped
+// and report their results after API server restarts
+const activeTasksCollection = "active_tasks"
+
 type repo struct {
 	inboxes     map[string]agentInbox
+	queued      map[string]*queuedTasks // by task ID
 	inboxesLock sync.RWMutex
 
-	tasks     map[string]ResultRequest
-	tasksLock sync.RWMutex
-	taskChs   map[string]chan struct{}
+	results     map[string]*taskResult
+	resultsLock sync.RWMutex
+	retention   Retention
+
+	activeTasks     map[string]activeTask
+	activeTasksLock sync.RWMutex
+	store           storage.Store
+
+	taskStates      map[string]State
+	taskAdjustments map[string]Adjustment
+	taskStatesLock  sync.RWMutex
+
+	taskProgress     map[string]Progress
+	taskProgressLock sync.RWMutex
 
-	taskStates     map[string]State
-	taskStatesLock sync.RWMutex
+	// Closed and replaced when there is something new for a polling agent
+	agentSignals     map[string]chan struct{}
+	agentSignalsLock sync.Mutex
 
 	logTag string
 	logger boshlog.Logger
@@ -28,29 +50,107 @@ type agentInbox struct {
 	tasks    []Task
 }
 
-func NewRepo(logger boshlog.Logger) Repo {
-	return &repo{
+// queuedTasks are tasks queued together that have not been consumed yet
+type queuedTasks struct {
+	agentID   string
+	tasks     []Task
+	cancelled chan struct{}
+}
+
+type activeTask struct {
+	agentID string
+	task    Task
+
+	// Set for tasks restored after API server restarted until agent reports executing them
+	restoredAt time.Time
+}
+
+// activeTaskRecord is how active task is persisted
+type activeTaskRecord struct {
+	AgentID string
+	Task    Task
+}
+
+// taskResult is kept from queueing until it's collected some time after task finishes
+type taskResult struct {
+	doneCh     chan struct{}
+	req        ResultRequest
+	finishedAt time.Time
+}
+
+// Retention limits how many results of finished tasks are kept in memory and for how long
+type Retention struct {
+	TTL         time.Duration // no limit if 0
+	MaxFinished int           // no limit if 0
+}
+
+var DefaultRetention = Retention{TTL: time.Hour, MaxFinished: 1000}
+
+// restoredTaskTimeout is how long agent has to report executing a task restored
+// after API server restarted; agents poll continuously and stop their tasks
+// on their own if they cannot reach API for a while
+const restoredTaskTimeout = 10 * time.Minute
+
+// DefaultDeliveryTimeout is how long agents have to pick up tasks unless configured otherwise
+const DefaultDeliveryTimeout = 30 * time.Second
+
+func NewRepo(retention Retention, store storage.Store, logger boshlog.Logger) (Repo, error) {
+	r := &repo{
 		inboxes: map[string]agentInbox{},
+		queued:  map[string]*queuedTasks{},
+
+		results:   map[string]*taskResult{},
+		retention: retention,
+
+		activeTasks: map[string]activeTask{},
+		store:       store,
 
-		tasks:   map[string]ResultRequest{},
-		taskChs: map[string]chan struct{}{},
+		taskStates:      map[string]State{},
+		taskAdjustments: map[string]Adjustment{},
 
-		taskStates: map[string]State{},
+		taskProgress: map[string]Progress{},
+
+		agentSignals: map[string]chan struct{}{},
 
 		logTag: "tasks.repo",
 		logger: logger,
 	}
+
+	return r, r.load()
+}
+
+// load restores tasks that agents were executing before API server restarted;
+// nobody waits for their results anymore
+func (r *repo) load() error {
+	docs, err := r.store.List(activeTasksCollection)
+	if err != nil {
+		return bosherr.WrapError(err, "Loading active tasks")
+	}
+
+	for _, doc := range docs {
+		var rec activeTaskRecord
+
+		err := json.Unmarshal(doc, &rec)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling active task")
+		}
+
+		r.activeTasks[rec.Task.ID] = activeTask{agentID: rec.AgentID, task: rec.Task, restoredAt: time.Now().UTC()}
+		r.results[rec.Task.ID] = &taskResult{doneCh: make(chan struct{})}
+	}
+
+	return nil
 }
 
-func (r *repo) QueueAndWait(agentID string, tasks []Task) error {
+func (r *repo) QueueAndWait(agentID string, tasks []Task, timeout time.Duration) error {
 	// Set up wait channels for tasks
-	r.tasksLock.Lock()
+	r.resultsLock.Lock()
 
 	for _, task := range tasks {
-		r.taskChs[task.ID] = make(chan struct{})
+		r.results[task.ID] = &taskResult{doneCh: make(chan struct{})}
 	}
 
-	r.tasksLock.Unlock()
+	r.resultsLock.Unlock()
 
 	// Set up agent inbox
 	r.inboxesLock.Lock()
@@ -64,37 +164,135 @@ func (r *repo) QueueAndWait(agentID string, tasks []Task) error {
 
 	r.inboxes[agentID] = rec
 
+	queued := &queuedTasks{agentID: agentID, tasks: tasks, cancelled: make(chan struct{})}
+
+	for _, task := range tasks {
+		r.queued[task.ID] = queued
+	}
+
 	// Local ref before unlocking
 	consumed := rec.consumed
 
 	// Unlock before blocking
 	r.inboxesLock.Unlock()
 
+	r.signalAgent(agentID)
+
 	select {
 	case <-consumed:
 		r.logger.Debug(r.logTag, "Finished waiting since agent '%s' consumed tasks", agentID)
 		return nil
 
-	case <-time.After(30 * time.Second):
-		r.logger.Error(r.logTag, "Timed out waiting for agent '%s' to consume tasks", agentID)
+	case <-queued.cancelled:
+		return r.cancelled(agentID, tasks)
 
+	case <-time.After(timeout):
 		// Clean up agent inbox
 		r.inboxesLock.Lock()
 
-		delete(r.inboxes, agentID)
+		// Agent may have consumed tasks (or they were cancelled) right as timeout passed
+		select {
+		case <-consumed:
+			r.inboxesLock.Unlock()
+			return nil
+		case <-queued.cancelled:
+			r.inboxesLock.Unlock()
+			return r.cancelled(agentID, tasks)
+		default:
+		}
+
+		// Other tasks in the inbox may still be waiting (e.g. kept queued by other incidents)
+		r.removeFromInbox(agentID, tasks)
 
 		r.inboxesLock.Unlock()
 
-		// Clean up task inboxes
-		r.tasksLock.Lock()
+		r.logger.Error(r.logTag, "Timed out waiting for agent '%s' to consume tasks", agentID)
 
-		for _, task := range tasks {
-			delete(r.taskChs, task.ID)
-		}
+		r.forgetQueued(tasks)
+
+		msg := fmt.Sprintf("Timed out waiting for agent '%s' to consume tasks", agentID)
+
+		return TaskError{Category: ErrorCategoryTimeout, Message: msg}
+	}
+}
+
+// cancelled is called once Cancel removed tasks from agent inbox
+func (r *repo) cancelled(agentID string, tasks []Task) error {
+	r.logger.Debug(r.logTag, "Finished waiting since tasks for agent '%s' were cancelled", agentID)
+
+	r.forgetQueued(tasks)
+
+	msg := fmt.Sprintf("Cancelled before agent '%s' consumed tasks", agentID)
+
+	return TaskError{Category: ErrorCategoryCancelled, Message: msg}
+}
+
+// forgetQueued cleans up results of tasks that were never consumed
+func (r *repo) forgetQueued(tasks []Task) {
+	r.resultsLock.Lock()
+
+	var taskIDs []string
+
+	for _, task := range tasks {
+		delete(r.results, task.ID)
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	r.resultsLock.Unlock()
+
+	r.forget(taskIDs)
+}
+
+func (r *repo) Cancel(taskID string) error {
+	if len(taskID) == 0 {
+		return bosherr.Error("Must provide non-empty task ID")
+	}
+
+	r.inboxesLock.Lock()
+	defer r.inboxesLock.Unlock()
 
-		r.tasksLock.Unlock()
+	queued, found := r.queued[taskID]
+	if !found {
+		// Task was already consumed, timed out or is not known
+		return nil
+	}
 
-		return bosherr.Errorf("Timed out waiting for agent '%s' to consume tasks", agentID)
+	r.removeFromInbox(queued.agentID, queued.tasks)
+
+	close(queued.cancelled)
+
+	r.logger.Debug(r.logTag, "Cancelled queued tasks for agent '%s'", queued.agentID)
+
+	return nil
+}
+
+// removeFromInbox must be called with inboxes lock held
+func (r *repo) removeFromInbox(agentID string, tasks []Task) {
+	rec := r.inboxes[agentID]
+
+	removedIDs := map[string]struct{}{}
+
+	for _, task := range tasks {
+		removedIDs[task.ID] = struct{}{}
+	}
+
+	var remaining []Task
+
+	for _, task := range tasks {
+		delete(r.queued, task.ID)
+	}
+
+	for _, task := range rec.tasks {
+		if _, found := removedIDs[task.ID]; !found {
+			remaining = append(remaining, task)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(r.inboxes, agentID)
+	} else {
+		rec.tasks = remaining
+		r.inboxes[agentID] = rec
 	}
 }
 
@@ -108,6 +306,22 @@ func (r *repo) Consume(agentID string) ([]Task, error) {
 
 	rec, found := r.inboxes[agentID]
 	if found {
+		r.activeTasksLock.Lock()
+
+		for _, task := range rec.tasks {
+			delete(r.queued, task.ID)
+
+			r.activeTasks[task.ID] = activeTask{agentID: agentID, task: task}
+
+			// Task is delivered regardless; it just cannot be stopped after restart
+			err := r.store.Save(activeTasksCollection, task.ID, activeTaskRecord{AgentID: agentID, Task: task})
+			if err != nil {
+				r.logger.Error(r.logTag, "Failed to save active task '%s': %s", task.ID, err.Error())
+			}
+		}
+
+		r.activeTasksLock.Unlock()
+
 		// Unblock all waiting clients
 		close(rec.consumed)
 
@@ -123,27 +337,222 @@ func (r *repo) Consume(agentID string) ([]Task, error) {
 	return rec.tasks, nil
 }
 
+func (r *repo) Poll(agentID string, req PollRequest, timeout time.Duration) (PollResponse, error) {
+	if len(agentID) == 0 {
+		return PollResponse{}, bosherr.Error("Must provide non-empty agent ID")
+	}
+
+	// Tasks consumed below are not known to the agent yet
+	if req.RunningTaskIDs != nil {
+		r.failLostTasks(agentID, req.RunningTaskIDs)
+	}
+
+	timeoutCh := time.After(timeout)
+
+	for {
+		// Retrieve signal before checking so that changes in between are not missed
+		signalCh := r.agentSignal(agentID)
+
+		tasks, err := r.Consume(agentID)
+		if err != nil {
+			return PollResponse{}, err
+		}
+
+		resp := PollResponse{
+			Tasks:          tasks,
+			StoppedTaskIDs: r.pendingStops(agentID, req.StoppedTaskIDs),
+			Adjustments:    r.pendingAdjustments(agentID, req.AdjustmentVersions),
+		}
+
+		if len(resp.Tasks) > 0 || len(resp.StoppedTaskIDs) > 0 || len(resp.Adjustments) > 0 {
+			return resp, nil
+		}
+
+		select {
+		case <-signalCh:
+		case <-timeoutCh:
+			return resp, nil
+		}
+	}
+}
+
+// failLostTasks fails agent's active tasks that agent is not executing
+// so that they are not waited for (or sent stops and adjustments) forever
+func (r *repo) failLostTasks(agentID string, runningTaskIDs []string) {
+	running := map[string]struct{}{}
+
+	for _, id := range runningTaskIDs {
+		running[id] = struct{}{}
+	}
+
+	var lostIDs []string
+
+	r.activeTasksLock.Lock()
+
+	for id, active := range r.activeTasks {
+		if active.agentID != agentID {
+			continue
+		}
+
+		if _, found := running[id]; found {
+			active.restoredAt = time.Time{}
+			r.activeTasks[id] = active
+		} else {
+			lostIDs = append(lostIDs, id)
+		}
+	}
+
+	r.activeTasksLock.Unlock()
+
+	for _, id := range lostIDs {
+		r.fail(id, fmt.Sprintf(
+			"Agent '%s' is no longer executing task (e.g. agent restarted or failed to report result)", agentID))
+	}
+}
+
+// failUnconfirmedTasks fails restored tasks that agents did not report executing in time
+func (r *repo) failUnconfirmedTasks(now time.Time) {
+	var unconfirmed []activeTask
+
+	r.activeTasksLock.RLock()
+
+	for _, active := range r.activeTasks {
+		if !active.restoredAt.IsZero() && now.Sub(active.restoredAt) > restoredTaskTimeout {
+			unconfirmed = append(unconfirmed, active)
+		}
+	}
+
+	r.activeTasksLock.RUnlock()
+
+	for _, active := range unconfirmed {
+		r.fail(active.task.ID, fmt.Sprintf(
+			"Agent '%s' did not report executing task after API server restarted", active.agentID))
+	}
+}
+
+func (r *repo) fail(taskID, msg string) {
+	r.logger.Error(r.logTag, "Failing task '%s': %s", taskID, msg)
+
+	taskErr := TaskError{Category: ErrorCategoryExecution, Message: msg}
+
+	r.Update(taskID, ResultRequest{Error: msg, TaskError: &taskErr})
+}
+
+// pendingStops returns IDs of agent's active tasks that should be stopped
+// excluding ones that agent already knows about
+func (r *repo) pendingStops(agentID string, stoppedTaskIDs []string) []string {
+	knownStops := map[string]struct{}{}
+
+	for _, id := range stoppedTaskIDs {
+		knownStops[id] = struct{}{}
+	}
+
+	activeTasks, _ := r.ListActive(agentID)
+
+	r.taskStatesLock.RLock()
+	defer r.taskStatesLock.RUnlock()
+
+	var ids []string
+
+	for _, task := range activeTasks {
+		if _, found := knownStops[task.ID]; !found && r.taskStates[task.ID].Stop {
+			ids = append(ids, task.ID)
+		}
+	}
+
+	return ids
+}
+
+// pendingAdjustments returns latest adjustments of agent's active tasks
+// that agent has not applied yet
+func (r *repo) pendingAdjustments(agentID string, appliedVersions map[string]int) []Adjustment {
+	activeTasks, _ := r.ListActive(agentID)
+
+	r.taskStatesLock.RLock()
+	defer r.taskStatesLock.RUnlock()
+
+	var adjs []Adjustment
+
+	for _, task := range activeTasks {
+		adj, found := r.taskAdjustments[task.ID]
+		if found && adj.Version > appliedVersions[task.ID] && !r.taskStates[task.ID].Stop {
+			adjs = append(adjs, adj)
+		}
+	}
+
+	return adjs
+}
+
+func (r *repo) agentSignal(agentID string) chan struct{} {
+	r.agentSignalsLock.Lock()
+	defer r.agentSignalsLock.Unlock()
+
+	ch, found := r.agentSignals[agentID]
+	if !found {
+		ch = make(chan struct{})
+		r.agentSignals[agentID] = ch
+	}
+
+	return ch
+}
+
+func (r *repo) signalAgent(agentID string) {
+	r.agentSignalsLock.Lock()
+	defer r.agentSignalsLock.Unlock()
+
+	if ch, found := r.agentSignals[agentID]; found {
+		close(ch)
+		delete(r.agentSignals, agentID)
+	}
+}
+
+func (r *repo) ListActive(agentID string) ([]Task, error) {
+	r.activeTasksLock.RLock()
+	defer r.activeTasksLock.RUnlock()
+
+	var tasks []Task
+
+	for _, active := range r.activeTasks {
+		if active.agentID == agentID {
+			tasks = append(tasks, active.task)
+		}
+	}
+
+	return tasks, nil
+}
+
+func (r *repo) FetchAgentID(taskID string) (string, bool, error) {
+	if len(taskID) == 0 {
+		return "", false, bosherr.Error("Must provide non-empty task ID")
+	}
+
+	r.activeTasksLock.RLock()
+	defer r.activeTasksLock.RUnlock()
+
+	active, found := r.activeTasks[taskID]
+
+	return active.agentID, found, nil
+}
+
 func (r *repo) Wait(taskID string) (ResultRequest, error) {
 	if len(taskID) == 0 {
 		return ResultRequest{}, bosherr.Error("Must provide non-empty task ID")
 	}
 
-	r.tasksLock.Lock()
+	r.resultsLock.RLock()
+	result, found := r.results[taskID]
+	r.resultsLock.RUnlock()
 
-	ch, found := r.taskChs[taskID]
 	if !found {
-		return ResultRequest{}, bosherr.Error("Waiting must happen after queueing")
+		return ResultRequest{}, bosherr.Errorf(
+			"Waiting must happen after queueing and before task '%s' result is collected", taskID)
 	}
 
-	r.tasksLock.Unlock()
-
-	<-ch
+	// Result is saved before channel is closed and stays available
+	// to waiters even after it's collected
+	<-result.doneCh
 
-	// Fetch saved task
-	r.tasksLock.Lock()
-	defer r.tasksLock.Unlock()
-
-	return r.tasks[taskID], nil
+	return result.req, nil
 }
 
 func (r *repo) Update(taskID string, taskReq ResultRequest) error {
@@ -151,24 +560,150 @@ func (r *repo) Update(taskID string, taskReq ResultRequest) error {
 		return bosherr.Error("Must provide non-empty task ID")
 	}
 
-	r.tasksLock.Lock()
-	defer r.tasksLock.Unlock()
+	r.activeTasksLock.Lock()
+	delete(r.activeTasks, taskID)
+	r.deleteActiveTaskRecord(taskID)
+	r.activeTasksLock.Unlock()
 
-	// Save task before closing channel
-	r.tasks[taskID] = taskReq
+	r.resultsLock.Lock()
 
-	if ch, found := r.taskChs[taskID]; found {
-		// Unblock all waiting clients
-		close(ch)
+	result, found := r.results[taskID]
+	if !found || !result.finishedAt.IsZero() {
+		r.resultsLock.Unlock()
 
-		// Reset task
-		// todo delete(r.taskChs, taskID) how to garbage collect
+		// Agent may report late (e.g. after result was collected or queueing timed out)
+		r.logger.Debug(r.logTag, "Ignoring result of unknown or finished task '%s'", taskID)
 
-		r.logger.Debug(r.logTag, "Updated task '%s'", taskID)
-	} else {
-		r.logger.Debug(r.logTag, "Did not wait for task '%s'", taskID)
+		return nil
 	}
 
+	// Save result before closing channel
+	result.req = taskReq
+	result.finishedAt = time.Now().UTC()
+
+	// Unblock all waiting clients
+	close(result.doneCh)
+
+	collectedIDs := r.collect(result.finishedAt)
+
+	r.resultsLock.Unlock()
+
+	r.logger.Debug(r.logTag, "Updated task '%s'", taskID)
+
+	r.forget(collectedIDs)
+
+	return nil
+}
+
+func (r *repo) Collect() {
+	now := time.Now().UTC()
+
+	r.failUnconfirmedTasks(now)
+
+	r.resultsLock.Lock()
+	collectedIDs := r.collect(now)
+	r.resultsLock.Unlock()
+
+	r.forget(collectedIDs)
+}
+
+// collect removes results of tasks that finished longer than retention TTL ago
+// or exceed maximum number of retained results (oldest first); must hold results lock
+func (r *repo) collect(now time.Time) []string {
+	var finishedIDs []string
+
+	for id, result := range r.results {
+		if !result.finishedAt.IsZero() {
+			finishedIDs = append(finishedIDs, id)
+		}
+	}
+
+	sort.Slice(finishedIDs, func(i, j int) bool {
+		return r.results[finishedIDs[i]].finishedAt.Before(r.results[finishedIDs[j]].finishedAt)
+	})
+
+	var collectedIDs []string
+
+	for i, id := range finishedIDs {
+		expired := r.retention.TTL > 0 && now.Sub(r.results[id].finishedAt) > r.retention.TTL
+		excess := r.retention.MaxFinished > 0 && len(finishedIDs)-i > r.retention.MaxFinished
+
+		// Remaining results finished more recently
+		if !expired && !excess {
+			break
+		}
+
+		delete(r.results, id)
+		collectedIDs = append(collectedIDs, id)
+	}
+
+	if len(collectedIDs) > 0 {
+		r.logger.Debug(r.logTag, "Collected %d finished task(s)", len(collectedIDs))
+	}
+
+	return collectedIDs
+}
+
+func (r *repo) deleteActiveTaskRecord(taskID string) {
+	err := r.store.Delete(activeTasksCollection, taskID)
+	if err != nil {
+		r.logger.Error(r.logTag, "Failed to delete active task '%s': %s", taskID, err.Error())
+	}
+}
+
+// forget removes state and progress of tasks whose results are no longer kept
+func (r *repo) forget(taskIDs []string) {
+	if len(taskIDs) == 0 {
+		return
+	}
+
+	r.activeTasksLock.Lock()
+	r.taskStatesLock.Lock()
+	r.taskProgressLock.Lock()
+
+	for _, id := range taskIDs {
+		delete(r.activeTasks, id)
+		r.deleteActiveTaskRecord(id)
+		delete(r.taskStates, id)
+		delete(r.taskAdjustments, id)
+		delete(r.taskProgress, id)
+	}
+
+	r.taskProgressLock.Unlock()
+	r.taskStatesLock.Unlock()
+	r.activeTasksLock.Unlock()
+}
+
+func (r *repo) FetchProgress(taskID string) (Progress, error) {
+	if len(taskID) == 0 {
+		return Progress{}, bosherr.Error("Must provide non-empty task ID")
+	}
+
+	r.taskProgressLock.RLock()
+	defer r.taskProgressLock.RUnlock()
+
+	return r.taskProgress[taskID], nil
+}
+
+func (r *repo) UpdateProgress(taskID string, req ProgressRequest) error {
+	if len(taskID) == 0 {
+		return bosherr.Error("Must provide non-empty task ID")
+	}
+
+	// Hold results lock so that task cannot be collected in the meantime
+	r.resultsLock.RLock()
+	defer r.resultsLock.RUnlock()
+
+	if _, found := r.results[taskID]; !found {
+		r.logger.Debug(r.logTag, "Ignoring progress of unknown task '%s'", taskID)
+		return nil
+	}
+
+	r.taskProgressLock.Lock()
+	defer r.taskProgressLock.Unlock()
+
+	r.taskProgress[taskID] = r.taskProgress[taskID].Apply(req, time.Now().UTC())
+
 	return nil
 }
 
@@ -188,10 +723,49 @@ func (r *repo) UpdateState(taskID string, req StateRequest) error {
 		return bosherr.Error("Must provide non-empty task ID")
 	}
 
+	r.activeTasksLock.RLock()
+	active, found := r.activeTasks[taskID]
+	r.activeTasksLock.RUnlock()
+
+	if len(req.Options) > 0 {
+		if !found {
+			return NewValidationError("Options", fmt.Sprintf("cannot be changed since task '%s' is not running", taskID))
+		}
+
+		err := ValidateAdjustment(active.task, req.Options)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hold results lock so that task cannot be collected in the meantime
+	r.resultsLock.RLock()
+
+	if _, found := r.results[taskID]; !found {
+		r.resultsLock.RUnlock()
+		r.logger.Debug(r.logTag, "Ignoring state of unknown task '%s'", taskID)
+		return nil
+	}
+
 	r.taskStatesLock.Lock()
-	defer r.taskStatesLock.Unlock()
 
-	r.taskStates[taskID] = State{Stop: req.Stop}
+	// Stopping and adjusting are independent (e.g. adjusting does not unstop)
+	state := r.taskStates[taskID]
+	state.Stop = state.Stop || req.Stop
+	r.taskStates[taskID] = state
+
+	if len(req.Options) > 0 {
+		adj := r.taskAdjustments[taskID]
+		r.taskAdjustments[taskID] = Adjustment{TaskID: taskID, Version: adj.Version + 1, Options: req.Options}
+	}
+
+	r.taskStatesLock.Unlock()
+	r.resultsLock.RUnlock()
+
+	// Let polling agent know about the change right away
+	if found {
+		r.signalAgent(active.agentID)
+	}
 
 	return nil
 }
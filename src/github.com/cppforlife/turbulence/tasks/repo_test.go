@@ -0,0 +1,286 @@
+package tasks_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/storage"
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Repo", func() {
+	var (
+		repo Repo
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		repo, err = NewRepo(DefaultRetention, storage.NewMemoryStore(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	queue := func(agentID string, task Task) {
+		go repo.QueueAndWait(agentID, []Task{task}, DefaultDeliveryTimeout)
+
+		resp, err := repo.Poll(agentID, PollRequest{}, time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Tasks).To(Equal([]Task{task}))
+	}
+
+	Describe("FetchAgentID", func() {
+		It("returns agent that consumed task until task finishes", func() {
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{NoopOptions{}}})
+
+			agentID, found, err := repo.FetchAgentID("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(agentID).To(Equal("agent1"))
+
+			err = repo.Update("task1", ResultRequest{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err = repo.FetchAgentID("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("UpdateState", func() {
+		It("returns adjustments that agent has not applied yet", func() {
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{ControlNetOptions{Delay: "50ms"}}})
+
+			err := repo.UpdateState("task1", StateRequest{Options: OptionsSlice{ControlNetOptions{Delay: "500ms"}}})
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := repo.Poll("agent1", PollRequest{}, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Adjustments).To(Equal([]Adjustment{{
+				TaskID:  "task1",
+				Version: 1,
+				Options: OptionsSlice{ControlNetOptions{Delay: "500ms"}},
+			}}))
+
+			// Already applied adjustment is not returned again
+			req := PollRequest{AdjustmentVersions: map[string]int{"task1": 1}}
+
+			resp, err = repo.Poll("agent1", req, 10*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Adjustments).To(BeEmpty())
+		})
+
+		It("rejects adjustments of a different task type or of tasks that are not running", func() {
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{ControlNetOptions{Delay: "50ms"}}})
+
+			err := repo.UpdateState("task1", StateRequest{Options: OptionsSlice{StressOptions{NumCPUWorkers: 1}}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must match task type 'ControlNet'"))
+
+			err = repo.UpdateState("task2", StateRequest{Options: OptionsSlice{ControlNetOptions{Delay: "50ms"}}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not running"))
+		})
+	})
+
+	Describe("Update", func() {
+		BeforeEach(func() {
+			var err error
+
+			repo, err = NewRepo(Retention{TTL: time.Hour, MaxFinished: 2}, storage.NewMemoryStore(), boshlog.NewLogger(boshlog.LevelNone))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("collects oldest finished tasks beyond retained count", func() {
+			for _, id := range []string{"task1", "task2", "task3"} {
+				queue("agent1", Task{ID: id, Optionss: OptionsSlice{NoopOptions{}}})
+				Expect(repo.UpdateProgress(id, ProgressRequest{State: TaskStateHolding})).ToNot(HaveOccurred())
+			}
+
+			Expect(repo.Update("task1", ResultRequest{Error: "task1-err"})).ToNot(HaveOccurred())
+
+			// Result is available until it's collected
+			req, err := repo.Wait("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(req).To(Equal(ResultRequest{Error: "task1-err"}))
+
+			Expect(repo.Update("task2", ResultRequest{Error: "task2-err"})).ToNot(HaveOccurred())
+			Expect(repo.Update("task3", ResultRequest{Error: "task3-err"})).ToNot(HaveOccurred())
+
+			_, err = repo.Wait("task1")
+			Expect(err).To(HaveOccurred())
+
+			progress, err := repo.FetchProgress("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(progress).To(Equal(Progress{}))
+
+			req, err = repo.Wait("task3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(req).To(Equal(ResultRequest{Error: "task3-err"}))
+		})
+
+		It("collects tasks that finished longer than TTL ago when collecting periodically", func() {
+			var err error
+
+			repo, err = NewRepo(Retention{TTL: 10 * time.Millisecond}, storage.NewMemoryStore(), boshlog.NewLogger(boshlog.LevelNone))
+			Expect(err).ToNot(HaveOccurred())
+
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{NoopOptions{}}})
+			Expect(repo.UpdateProgress("task1", ProgressRequest{State: TaskStateHolding})).ToNot(HaveOccurred())
+			Expect(repo.Update("task1", ResultRequest{})).ToNot(HaveOccurred())
+
+			repo.Collect()
+
+			_, err = repo.Wait("task1")
+			Expect(err).ToNot(HaveOccurred())
+
+			time.Sleep(20 * time.Millisecond)
+			repo.Collect()
+
+			_, err = repo.Wait("task1")
+			Expect(err).To(HaveOccurred())
+
+			progress, err := repo.FetchProgress("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(progress).To(Equal(Progress{}))
+		})
+
+		It("ignores late results and progress of finished or collected tasks", func() {
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{NoopOptions{}}})
+
+			Expect(repo.Update("task1", ResultRequest{})).ToNot(HaveOccurred())
+			Expect(repo.Update("task1", ResultRequest{Error: "late"})).ToNot(HaveOccurred())
+			Expect(repo.Update("unknown", ResultRequest{})).ToNot(HaveOccurred())
+			Expect(repo.UpdateProgress("unknown", ProgressRequest{State: TaskStateHolding})).ToNot(HaveOccurred())
+
+			req, err := repo.Wait("task1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(req).To(Equal(ResultRequest{}))
+
+			progress, err := repo.FetchProgress("unknown")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(progress).To(Equal(Progress{}))
+		})
+	})
+
+	Describe("Poll", func() {
+		It("fails active tasks that agent is no longer executing instead of sending their stops", func() {
+			queue("agent1", Task{ID: "lost", Optionss: OptionsSlice{NoopOptions{}}})
+			queue("agent1", Task{ID: "running", Optionss: OptionsSlice{NoopOptions{}}})
+
+			Expect(repo.UpdateState("lost", StateRequest{Stop: true})).To(Succeed())
+			Expect(repo.UpdateState("running", StateRequest{Stop: true})).To(Succeed())
+
+			resp, err := repo.Poll("agent1", PollRequest{RunningTaskIDs: []string{"running"}}, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StoppedTaskIDs).To(Equal([]string{"running"}))
+
+			result, err := repo.Wait("lost")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Err()).To(MatchError(ContainSubstring("Agent 'agent1' is no longer executing task")))
+
+			Expect(repo.ListActive("agent1")).To(Equal([]Task{{ID: "running", Optionss: OptionsSlice{NoopOptions{}}}}))
+		})
+
+		It("fails restored tasks that agent is no longer executing after API server restarted", func() {
+			dir, err := ioutil.TempDir("", "turbulence-tasks")
+			Expect(err).ToNot(HaveOccurred())
+
+			defer os.RemoveAll(dir)
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			store := storage.NewFileStore(dir, boshsys.NewOsFileSystem(logger), logger)
+
+			repo, err = NewRepo(DefaultRetention, store, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			queue("agent1", Task{ID: "lost", Optionss: OptionsSlice{NoopOptions{}}})
+			queue("agent1", Task{ID: "running", Optionss: OptionsSlice{NoopOptions{}}})
+
+			repo, err = NewRepo(DefaultRetention, store, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = repo.Poll("agent1", PollRequest{RunningTaskIDs: []string{"running"}}, time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+
+			result, err := repo.Wait("lost")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Err()).To(HaveOccurred())
+
+			Expect(repo.ListActive("agent1")).To(Equal([]Task{{ID: "running", Optionss: OptionsSlice{NoopOptions{Type: "Noop"}}}}))
+
+			docs, err := store.List("active_tasks")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(docs).To(HaveLen(1))
+		})
+
+		It("does not fail tasks of agents that do not report running tasks", func() {
+			queue("agent1", Task{ID: "task1", Optionss: OptionsSlice{NoopOptions{}}})
+
+			_, err := repo.Poll("agent1", PollRequest{}, time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(repo.ListActive("agent1")).To(HaveLen(1))
+		})
+	})
+
+	Describe("QueueAndWait", func() {
+		It("times out without dropping tasks that other incidents keep queued", func() {
+			kept := Task{ID: "kept", Optionss: OptionsSlice{NoopOptions{}}}
+			expired := Task{ID: "expired", Optionss: OptionsSlice{NoopOptions{}}}
+
+			keptErrCh := make(chan error)
+			go func() { keptErrCh <- repo.QueueAndWait("agent1", []Task{kept}, time.Minute) }()
+
+			err := repo.QueueAndWait("agent1", []Task{expired}, 10*time.Millisecond)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Timed out waiting for agent 'agent1'"))
+
+			resp, err := repo.Poll("agent1", PollRequest{}, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Tasks).To(Equal([]Task{kept}))
+
+			Eventually(keptErrCh).Should(Receive(BeNil()))
+		})
+
+		It("returns error once tasks are cancelled without dropping other queued tasks", func() {
+			kept := Task{ID: "kept", Optionss: OptionsSlice{NoopOptions{}}}
+			cancelled := Task{ID: "cancelled", Optionss: OptionsSlice{NoopOptions{}}}
+
+			keptErrCh := make(chan error)
+			go func() { keptErrCh <- repo.QueueAndWait("agent1", []Task{kept}, time.Minute) }()
+
+			cancelledErrCh := make(chan error, 1)
+			go func() { cancelledErrCh <- repo.QueueAndWait("agent1", []Task{cancelled}, time.Minute) }()
+
+			var err error
+
+			// Cancelling is a noop until task is queued
+			Eventually(func() chan error {
+				Expect(repo.Cancel("cancelled")).To(Succeed())
+				return cancelledErrCh
+			}).Should(Receive(&err))
+
+			Expect(err).To(Equal(TaskError{
+				Category: ErrorCategoryCancelled,
+				Message:  "Cancelled before agent 'agent1' consumed tasks",
+			}))
+
+			resp, err := repo.Poll("agent1", PollRequest{}, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Tasks).To(Equal([]Task{kept}))
+
+			Eventually(keptErrCh).Should(Receive(BeNil()))
+
+			// Consumed tasks can only be stopped
+			Expect(repo.Cancel("kept")).To(Succeed())
+			Expect(repo.ListActive("agent1")).To(Equal([]Task{kept}))
+		})
+	})
+})
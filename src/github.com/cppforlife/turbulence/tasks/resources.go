@@ -0,0 +1,44 @@
+package tasks
+
+// Resources lists system resources that a task changes and reverts
+// (e.g. root qdisc of an interface). Tasks that share a resource
+// must not run at the same time since they would undo each other's changes.
+func Resources(taskOpts Options, ifaceNames []string) []string {
+	switch opts := taskOpts.(type) {
+	case KillProcessOptions:
+		if opts.Target != nil {
+			return []string{"process:" + opts.ProcessName + "@" + opts.Target.resourceKey()}
+		}
+		if len(opts.ProcessName) > 0 {
+			return []string{"process:" + opts.ProcessName}
+		}
+		if len(opts.MonitoredProcessName) > 0 {
+			return []string{"monitored-process:" + opts.MonitoredProcessName}
+		}
+		return nil
+
+	case ControlNetOptions:
+		// Container interfaces are only known once container is found
+		if opts.Target != nil {
+			return []string{"qdisc:" + opts.Target.resourceKey()}
+		}
+
+		var resources []string
+		for _, ifaceName := range ifaceNames {
+			resources = append(resources, "qdisc:"+ifaceName)
+		}
+		return resources
+
+	case FirewallOptions:
+		if opts.Target != nil {
+			return []string{"iptables:" + opts.Target.resourceKey()}
+		}
+		return []string{"iptables:INPUT", "iptables:OUTPUT"}
+
+	case FillDiskOptions:
+		return []string{"filler:" + opts.fillerPath()}
+
+	default:
+		return nil
+	}
+}
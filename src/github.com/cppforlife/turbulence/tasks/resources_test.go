@@ -0,0 +1,24 @@
+package tasks_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Resources", func() {
+	It("returns qdisc of each interface for control net tasks", func() {
+		Expect(Resources(ControlNetOptions{}, []string{"eth0", "eth1"})).To(Equal([]string{"qdisc:eth0", "qdisc:eth1"}))
+	})
+
+	It("returns filler path for fill disk tasks", func() {
+		Expect(Resources(FillDiskOptions{Ephemeral: true}, nil)).To(Equal([]string{"filler:/var/vcap/data/.filler"}))
+		Expect(Resources(FillDiskOptions{}, nil)).To(Equal([]string{"filler:/.filler"}))
+	})
+
+	It("returns no resources for tasks that do not need to be reverted", func() {
+		Expect(Resources(StressOptions{}, nil)).To(BeEmpty())
+		Expect(Resources(KillProcessOptions{}, nil)).To(BeEmpty())
+	})
+})
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"fmt"
+	"regexp"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -21,6 +22,14 @@ type ShutdownOptions struct {
 
 func (ShutdownOptions) _private() {}
 
+var shutdownSysrqRegexp = regexp.MustCompile(`^[0-9a-z]$`)
+
+func (o ShutdownOptions) Validate() error {
+	var v validator
+	v.Match("Sysrq", o.Sysrq, shutdownSysrqRegexp, "a single sysrq command key")
+	return v.Err()
+}
+
 type ShutdownTask struct {
 	cmdRunner boshsys.CmdRunner
 	opts      ShutdownOptions
@@ -1,7 +1,10 @@
 package tasks
 
 import (
+	"math"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -21,72 +24,173 @@ type StressOptions struct {
 
 	NumHDDWorkers  int
 	HDDWorkerBytes string // Sizes may be suffixed with B,K,M,G
+
+	// gradually grow worker counts from start values
+	Ramp *RampOptions `json:",omitempty"`
 }
 
 func (StressOptions) _private() {}
 
+var (
+	stressTimeRegexp = regexp.MustCompile(`(?i)^\d+[smhdy]?$`)
+	stressSizeRegexp = regexp.MustCompile(`(?i)^\d+[bkmg]?$`)
+)
+
+func (o StressOptions) Validate() error {
+	var v validator
+
+	v.Match("Timeout", o.Timeout, stressTimeRegexp, "a time suffixed with s,m,h,d,y")
+
+	v.NonNegative("NumCPUWorkers", o.NumCPUWorkers)
+	v.NonNegative("NumIOWorkers", o.NumIOWorkers)
+	v.NonNegative("NumMemoryWorkers", o.NumMemoryWorkers)
+	v.NonNegative("NumHDDWorkers", o.NumHDDWorkers)
+
+	if o.NumCPUWorkers+o.NumIOWorkers+o.NumMemoryWorkers+o.NumHDDWorkers <= 0 {
+		v.Add("NumCPUWorkers", "or other worker count must be specified")
+	}
+
+	if o.NumMemoryWorkers > 0 && len(o.MemoryWorkerBytes) == 0 {
+		v.Add("MemoryWorkerBytes", "must be specified when 'NumMemoryWorkers' is set")
+	}
+
+	if o.NumHDDWorkers > 0 && len(o.HDDWorkerBytes) == 0 {
+		v.Add("HDDWorkerBytes", "must be specified when 'NumHDDWorkers' is set")
+	}
+
+	v.Match("MemoryWorkerBytes", o.MemoryWorkerBytes, stressSizeRegexp, "a size suffixed with B,K,M,G")
+	v.Match("HDDWorkerBytes", o.HDDWorkerBytes, stressSizeRegexp, "a size suffixed with B,K,M,G")
+
+	if o.Ramp != nil {
+		o.Ramp.validate(&v, o.Timeout)
+	}
+
+	return v.Err()
+}
+
+// scaled returns options with worker counts reduced to a fraction of their values;
+// configured worker types keep at least one worker
+func (o StressOptions) scaled(fraction float64) StressOptions {
+	if fraction >= 1 {
+		return o
+	}
+
+	scale := func(num int) int {
+		if num <= 0 {
+			return num
+		}
+		return int(math.Max(1, math.Floor(float64(num)*fraction)))
+	}
+
+	o.NumCPUWorkers = scale(o.NumCPUWorkers)
+	o.NumIOWorkers = scale(o.NumIOWorkers)
+	o.NumMemoryWorkers = scale(o.NumMemoryWorkers)
+	o.NumHDDWorkers = scale(o.NumHDDWorkers)
+
+	return o
+}
+
 type StressTask struct {
 	cmdRunner boshsys.CmdRunner
+	progress  ProgressReporter
+	adjustCh  <-chan Options
 	opts      StressOptions
 
 	logTag string
 	logger boshlog.Logger
 }
 
-func NewStressTask(cmdRunner boshsys.CmdRunner, opts StressOptions, logger boshlog.Logger) StressTask {
-	return StressTask{cmdRunner, opts, "task.StressTask", logger}
+func NewStressTask(
+	cmdRunner boshsys.CmdRunner,
+	progress ProgressReporter,
+	adjustCh <-chan Options,
+	opts StressOptions,
+	logger boshlog.Logger,
+) StressTask {
+	return StressTask{cmdRunner, progress, adjustCh, opts, "task.StressTask", logger}
 }
 
 func (t StressTask) Execute(stopCh chan struct{}) error {
-	// e.g. stress --cpu 2 --io 1 --vm 1 --vm-bytes 128M --timeout 10s --verbose
+	var deadline time.Time
+	var timeoutCh <-chan time.Time
 
-	args := []string{"--verbose"}
+	r := newRamp(t.opts.Ramp)
+	defer r.Stop()
 
-	if t.opts.NumCPUWorkers+t.opts.NumIOWorkers+t.opts.NumMemoryWorkers+t.opts.NumHDDWorkers == 0 {
-		return bosherr.Error("Must specify at least 1 type of worker")
+	if len(t.opts.Timeout) > 0 {
+		timeout := t.timeoutDuration(t.opts.Timeout)
+
+		if r.HasDown() {
+			// Stress keeps running past timeout while ramping down
+			timeoutCh = time.After(timeout)
+		} else {
+			// Restarted stress (after adjustment) only runs until original timeout
+			deadline = time.Now().Add(timeout)
+		}
 	}
 
-	if t.opts.NumCPUWorkers > 0 {
-		args = append(args, "--cpu", strconv.Itoa(t.opts.NumCPUWorkers))
+	s := stressRun{opts: t.opts, ramp: r, timeoutCh: timeoutCh, stopCh: stopCh}
+
+	for {
+		restart, err := t.runStress(t.args(s.opts.scaled(r.Fraction()), deadline), &s)
+		if err != nil || !restart {
+			return err
+		}
 	}
+}
+
+// stressRun holds state that carries over stress restarts
+type stressRun struct {
+	opts      StressOptions
+	ramp      *ramp
+	timeoutCh <-chan time.Time
+	stopCh    chan struct{}
+}
 
-	if t.opts.NumIOWorkers > 0 {
-		args = append(args, "--io", strconv.Itoa(t.opts.NumIOWorkers))
+func (t StressTask) args(opts StressOptions, deadline time.Time) []string {
+	// e.g. stress --cpu 2 --io 1 --vm 1 --vm-bytes 128M --timeout 10s --verbose
+
+	args := []string{"--verbose"}
+
+	if opts.NumCPUWorkers > 0 {
+		args = append(args, "--cpu", strconv.Itoa(opts.NumCPUWorkers))
 	}
 
-	if t.opts.NumMemoryWorkers > 0 {
-		if len(t.opts.MemoryWorkerBytes) == 0 {
-			return bosherr.Error("Must specify 'MemoryWorkerBytes'")
-		}
+	if opts.NumIOWorkers > 0 {
+		args = append(args, "--io", strconv.Itoa(opts.NumIOWorkers))
+	}
 
+	if opts.NumMemoryWorkers > 0 {
 		args = append(
 			args,
-			"--vm", strconv.Itoa(t.opts.NumMemoryWorkers),
-			"--vm-bytes", t.opts.MemoryWorkerBytes,
+			"--vm", strconv.Itoa(opts.NumMemoryWorkers),
+			"--vm-bytes", opts.MemoryWorkerBytes,
 		)
 	}
 
-	if t.opts.NumHDDWorkers > 0 {
-		if len(t.opts.HDDWorkerBytes) == 0 {
-			return bosherr.Error("Must specify 'HDDWorkerBytes'")
-		}
-
+	if opts.NumHDDWorkers > 0 {
 		args = append(
 			args,
-			"--hdd", strconv.Itoa(t.opts.NumHDDWorkers),
-			"--hdd-bytes", t.opts.HDDWorkerBytes,
+			"--hdd", strconv.Itoa(opts.NumHDDWorkers),
+			"--hdd-bytes", opts.HDDWorkerBytes,
 		)
 	}
 
 	// todo remove timeout option?
-	if len(t.opts.Timeout) > 0 {
-		args = append(args, "--timeout", t.opts.Timeout)
+	if !deadline.IsZero() {
+		secs := int(math.Ceil(deadline.Sub(time.Now()).Seconds()))
+		if secs < 1 {
+			secs = 1
+		}
+
+		args = append(args, "--timeout", strconv.Itoa(secs)+"s")
 	}
 
-	return t.runStress(args, stopCh)
+	return args
 }
 
-func (t StressTask) runStress(args []string, stopCh chan struct{}) error {
+// runStress returns true if stress was terminated to apply new worker counts
+func (t StressTask) runStress(args []string, s *stressRun) (bool, error) {
 	command := boshsys.Command{
 		Name: "stress",
 		Args: args,
@@ -94,9 +198,12 @@ func (t StressTask) runStress(args []string, stopCh chan struct{}) error {
 
 	process, err := t.cmdRunner.RunComplexCommandAsync(command)
 	if err != nil {
-		return bosherr.WrapError(err, "Shelling out to stress")
+		return false, bosherr.WrapError(err, "Shelling out to stress")
 	}
 
+	// Stress workers are running until process exits
+	t.progress.ReportState(TaskStateHolding)
+
 	var result boshsys.Result
 
 	isStopped := false
@@ -106,23 +213,90 @@ func (t StressTask) runStress(args []string, stopCh chan struct{}) error {
 		select {
 		case result = <-procExitedCh:
 			procExitedCh = nil
-		case <-stopCh:
-			// Ignore possible TerminateNicely error since we cannot return it
-			err := process.TerminateNicely(10 * time.Second)
-			if err != nil {
-				t.logger.Error(t.logTag, "Failed to terminate %s", err.Error())
+
+		case <-s.stopCh:
+			if !isStopped {
+				t.progress.ReportState(TaskStateReverting)
 			}
+
+			t.terminate(process)
 			isStopped = true
+
+		case <-s.timeoutCh:
+			s.timeoutCh = nil
+			s.ramp.StartDown()
+
+		case newOpts := <-t.adjustCh:
+			// Timeout and ramp of the original options continue to apply
+			adjOpts := newOpts.(StressOptions)
+			adjOpts.Timeout, adjOpts.Ramp = s.opts.Timeout, s.opts.Ramp
+			s.opts = adjOpts
+
+			if !isStopped && !t.sameArgs(args, s) {
+				// Worker counts cannot be changed for a running stress process
+				t.terminate(process)
+				return true, nil
+			}
+
+		case <-s.ramp.TickCh():
+			if !isStopped && s.ramp.IsDone() {
+				t.progress.ReportState(TaskStateReverting)
+				t.terminate(process)
+				isStopped = true
+			} else if !isStopped && !t.sameArgs(args, s) {
+				t.terminate(process)
+				return true, nil
+			}
 		}
 	}
 
 	if isStopped {
-		return nil // todo successfully stopped?
+		return false, nil // todo successfully stopped?
 	}
 
 	if result.Error != nil {
-		return bosherr.WrapError(result.Error, "Running stress")
+		return false, bosherr.WrapError(result.Error, "Running stress")
 	}
 
-	return nil
+	return false, nil
+}
+
+// sameArgs returns true if running stress already has currently expected worker counts
+func (t StressTask) sameArgs(args []string, s *stressRun) bool {
+	expectedArgs := t.args(s.opts.scaled(s.ramp.Fraction()), time.Time{})
+	return strings.Join(t.withoutTimeout(args), " ") == strings.Join(expectedArgs, " ")
+}
+
+func (StressTask) withoutTimeout(args []string) []string {
+	if len(args) >= 2 && args[len(args)-2] == "--timeout" {
+		return args[:len(args)-2]
+	}
+	return args
+}
+
+func (t StressTask) terminate(process boshsys.Process) {
+	// Ignore possible TerminateNicely error since we cannot return it
+	err := process.TerminateNicely(10 * time.Second)
+	if err != nil {
+		t.logger.Error(t.logTag, "Failed to terminate %s", err.Error())
+	}
+}
+
+// timeoutDuration converts validated stress time (e.g. 10m) to a duration
+func (StressTask) timeoutDuration(timeout string) time.Duration {
+	units := map[string]time.Duration{
+		"":  time.Second,
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"y": 365 * 24 * time.Hour,
+	}
+
+	timeout = strings.ToLower(timeout)
+	num := strings.TrimRight(timeout, "smhdy")
+
+	val, _ := strconv.Atoi(num)
+
+	return time.Duration(val) * units[timeout[len(num):]]
 }
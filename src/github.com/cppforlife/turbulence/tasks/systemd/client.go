@@ -0,0 +1,142 @@
+package systemd
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"github.com/cppforlife/turbulence/tasks/monit"
+)
+
+const selfCgroupPath = "/proc/self/cgroup"
+
+// ClientProvider provides clients that list running systemd services
+// in the same way monit clients list monitored processes
+type ClientProvider struct {
+	units     []string
+	cmdRunner boshsys.CmdRunner
+	fs        boshsys.FileSystem
+	logger    boshlog.Logger
+}
+
+// NewClientProvider only lists services with unit names matching one of given patterns
+func NewClientProvider(units []string, cmdRunner boshsys.CmdRunner, fs boshsys.FileSystem, logger boshlog.Logger) ClientProvider {
+	return ClientProvider{units: units, cmdRunner: cmdRunner, fs: fs, logger: logger}
+}
+
+func (p ClientProvider) IsAvailable() bool {
+	return p.cmdRunner.CommandExists("systemctl")
+}
+
+func (p ClientProvider) Get() (monit.Client, error) {
+	return client{p.units, p.cmdRunner, p.fs, "systemd.client", p.logger}, nil
+}
+
+type client struct {
+	units     []string
+	cmdRunner boshsys.CmdRunner
+	fs        boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func (c client) Services() ([]monit.Service, error) {
+	// Agent must not kill itself, even if its unit matches configured patterns
+	ownUnit, err := c.ownUnit()
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g. ssh.service loaded active running OpenBSD Secure Shell server
+	stdout, _, _, err := c.cmdRunner.RunCommand(
+		"systemctl", "list-units", "--type=service", "--state=running", "--no-legend", "--plain")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing systemd services")
+	}
+
+	var services []monit.Service
+
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		unit := fields[0]
+
+		if unit == ownUnit || !c.isAllowed(unit) {
+			c.logger.Debug(c.logTag, "Skipping unit '%s'", unit)
+			continue
+		}
+
+		pid, err := c.mainPID(unit)
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip services without a main process (e.g. oneshot)
+		if pid != 0 {
+			name := strings.TrimSuffix(unit, ".service")
+			services = append(services, monit.Service{Name: name, PID: pid})
+		}
+	}
+
+	return services, nil
+}
+
+func (c client) isAllowed(unit string) bool {
+	for _, pattern := range c.units {
+		if matched, _ := path.Match(pattern, unit); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ownUnit returns service unit agent runs in; empty if agent is not run by systemd
+func (c client) ownUnit() (string, error) {
+	// e.g. 0::/system.slice/turbulence_agent.service or 1:name=systemd:/system.slice/turbulence_agent.service
+	contents, err := c.fs.ReadFileString(selfCgroupPath)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Reading agent's cgroup")
+	}
+
+	var unit string
+
+	for _, line := range strings.Split(contents, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || (parts[0] != "0" && parts[1] != "name=systemd") {
+			continue
+		}
+
+		// Innermost service (e.g. not user@1000.service that contains user's services)
+		for _, name := range strings.Split(parts[2], "/") {
+			if strings.HasSuffix(name, ".service") {
+				unit = name
+			}
+		}
+	}
+
+	return unit, nil
+}
+
+func (c client) mainPID(unit string) (int, error) {
+	// e.g. MainPID=1234
+	stdout, _, _, err := c.cmdRunner.RunCommand("systemctl", "show", "--property=MainPID", unit)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Getting main PID of '%s'", unit)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(stdout), "MainPID="))
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Parsing main PID of '%s'", unit)
+	}
+
+	return pid, nil
+}
@@ -0,0 +1,80 @@
+package systemd_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cppforlife/turbulence/tasks/monit"
+	. "github.com/cppforlife/turbulence/tasks/systemd"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		cmdRunner *fakesys.FakeCmdRunner
+		fs        *fakesys.FakeFileSystem
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		fs = fakesys.NewFakeFileSystem()
+
+		fs.WriteFileString("/proc/self/cgroup", "0::/system.slice/turbulence_agent.service\n")
+
+		cmdRunner.AddCmdResult("systemctl list-units --type=service --state=running --no-legend --plain", fakesys.FakeCmdResult{
+			Stdout: `ssh.service loaded active running OpenBSD Secure Shell server
+postgresql@12-main.service loaded active running PostgreSQL Cluster 12-main
+postgresql-setup.service loaded active running PostgreSQL setup
+turbulence_agent.service loaded active running Turbulence agent
+`,
+		})
+		cmdRunner.AddCmdResult("systemctl show --property=MainPID postgresql@12-main.service", fakesys.FakeCmdResult{Stdout: "MainPID=123\n"})
+		cmdRunner.AddCmdResult("systemctl show --property=MainPID postgresql-setup.service", fakesys.FakeCmdResult{Stdout: "MainPID=0\n"})
+		cmdRunner.AddCmdResult("systemctl show --property=MainPID turbulence_agent.service", fakesys.FakeCmdResult{Stdout: "MainPID=456\n"})
+	})
+
+	services := func(units ...string) ([]monit.Service, error) {
+		client, err := NewClientProvider(units, cmdRunner, fs, boshlog.NewLogger(boshlog.LevelNone)).Get()
+		Expect(err).ToNot(HaveOccurred())
+
+		return client.Services()
+	}
+
+	It("only lists running services that match configured unit patterns and have main process", func() {
+		svcs, err := services("postgresql*.service")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(svcs).To(Equal([]monit.Service{{Name: "postgresql@12-main", PID: 123}}))
+
+		for _, cmd := range cmdRunner.RunCommands {
+			Expect(cmd).ToNot(ContainElement("ssh.service"))
+		}
+	})
+
+	It("never lists service that agent runs in", func() {
+		cmdRunner.AddCmdResult("systemctl show --property=MainPID ssh.service", fakesys.FakeCmdResult{Stdout: "MainPID=789\n"})
+
+		svcs, err := services("*")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(svcs).To(Equal([]monit.Service{{Name: "ssh", PID: 789}, {Name: "postgresql@12-main", PID: 123}}))
+	})
+
+	It("finds agent's service in cgroup v1 hierarchy", func() {
+		fs.WriteFileString("/proc/self/cgroup", "4:memory:/system.slice/turbulence_agent.service\n1:name=systemd:/system.slice/turbulence_agent.service\n")
+
+		svcs, err := services("turbulence_agent.service")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(svcs).To(BeEmpty())
+	})
+
+	It("returns error if agent's cgroup cannot be read", func() {
+		fs.RegisterReadFileError("/proc/self/cgroup", errors.New("fake-err"))
+
+		_, err := services("*")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-err"))
+	})
+})
@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"strings"
+)
+
+// Error categories make it possible to tell apart faults that could not be injected
+// from faults that were injected but could not be reverted.
+const (
+	ErrorCategoryValidation   = "validation"
+	ErrorCategoryToolMissing  = "tool_missing"
+	ErrorCategoryPermission   = "permission"
+	ErrorCategoryRevertFailed = "revert_failed"
+	ErrorCategoryTimeout      = "timeout"
+	ErrorCategoryConflict     = "conflict"  // another task is using the same resources
+	ErrorCategoryCancelled    = "cancelled" // task was stopped before agent picked it up
+	ErrorCategoryExecution    = "execution" // none of the above
+)
+
+type TaskError struct {
+	Category string
+	Message  string
+
+	// Last command that failed while task was executing (if any)
+	Cmd        string `json:",omitempty"`
+	ExitStatus int    `json:",omitempty"`
+	Output     string `json:",omitempty"`
+}
+
+type FailedCmd struct {
+	Cmd        string
+	ExitStatus int
+	Output     string
+}
+
+func NewTaskError(category string, err error, failedCmd *FailedCmd) TaskError {
+	taskErr := TaskError{Category: category, Message: err.Error()}
+
+	if failedCmd != nil {
+		taskErr.Cmd = failedCmd.Cmd
+		taskErr.ExitStatus = failedCmd.ExitStatus
+		taskErr.Output = failedCmd.Output
+	}
+
+	return taskErr
+}
+
+func (e TaskError) Error() string { return e.Message }
+
+// ErrorCategory categorizes an error that occurred while task was executing
+func ErrorCategory(err error, failedCmd *FailedCmd, reverting bool) string {
+	if reverting {
+		return ErrorCategoryRevertFailed
+	}
+
+	msg := err.Error()
+
+	if failedCmd != nil {
+		msg += "\n" + failedCmd.Output
+
+		switch failedCmd.ExitStatus {
+		case 127:
+			return ErrorCategoryToolMissing
+		case 126:
+			return ErrorCategoryPermission
+		}
+	}
+
+	switch {
+	case strings.Contains(msg, "executable file not found"):
+		return ErrorCategoryToolMissing
+
+	case strings.Contains(msg, "Permission denied"), strings.Contains(msg, "Operation not permitted"):
+		return ErrorCategoryPermission
+
+	case strings.Contains(strings.ToLower(msg), "timed out"):
+		return ErrorCategoryTimeout
+
+	default:
+		return ErrorCategoryExecution
+	}
+}
@@ -0,0 +1,30 @@
+package tasks_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("ErrorCategory", func() {
+	It("returns revert failed when task was reverting regardless of error", func() {
+		failedCmd := &FailedCmd{Cmd: "iptables -D INPUT", ExitStatus: 127}
+		Expect(ErrorCategory(errors.New("err"), failedCmd, true)).To(Equal(ErrorCategoryRevertFailed))
+	})
+
+	It("detects missing tools and permission problems", func() {
+		Expect(ErrorCategory(errors.New("err"), &FailedCmd{ExitStatus: 127}, false)).To(Equal(ErrorCategoryToolMissing))
+		Expect(ErrorCategory(errors.New(`exec: "tc": executable file not found in $PATH`), nil, false)).To(Equal(ErrorCategoryToolMissing))
+
+		failedCmd := &FailedCmd{ExitStatus: 2, Output: "RTNETLINK answers: Operation not permitted"}
+		Expect(ErrorCategory(errors.New("err"), failedCmd, false)).To(Equal(ErrorCategoryPermission))
+	})
+
+	It("detects timeouts and falls back to execution", func() {
+		Expect(ErrorCategory(errors.New("Timed out waiting"), nil, false)).To(Equal(ErrorCategoryTimeout))
+		Expect(ErrorCategory(errors.New("err"), nil, false)).To(Equal(ErrorCategoryExecution))
+	})
+})
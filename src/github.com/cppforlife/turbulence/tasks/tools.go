@@ -0,0 +1,50 @@
+package tasks
+
+// Tools lists names of system tools that agent tasks rely on.
+// Most of them are binaries; 'sysrq' and 'monit' are checked differently.
+var Tools = []string{
+	"tc", "iptables", "ip6tables", "nsenter", "stress", "pkill", "kill", "dd", "halt", "reboot", "sysrq", "monit",
+}
+
+func RequiredTools(taskOpts Options) []string {
+	switch opts := taskOpts.(type) {
+	case KillProcessOptions:
+		if opts.Target != nil {
+			return []string{"kill"}
+		}
+		if len(opts.ProcessName) > 0 {
+			return []string{"pkill"}
+		}
+		return []string{"monit", "kill"}
+
+	case StressOptions:
+		return []string{"stress"}
+
+	case ControlNetOptions:
+		if opts.Target != nil {
+			return []string{"tc", "nsenter"}
+		}
+		return []string{"tc"}
+
+	case FirewallOptions:
+		if opts.Target != nil {
+			return []string{"iptables", "nsenter"}
+		}
+		return []string{"iptables"}
+
+	case FillDiskOptions:
+		return []string{"dd"}
+
+	case ShutdownOptions:
+		if opts.Crash || len(opts.Sysrq) > 0 {
+			return []string{"sysrq"}
+		}
+		if opts.Reboot {
+			return []string{"reboot"}
+		}
+		return []string{"halt"}
+
+	default:
+		return nil
+	}
+}
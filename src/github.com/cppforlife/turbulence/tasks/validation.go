@@ -0,0 +1,119 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	var msgs []string
+
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("'%s' %s", f.Field, f.Message))
+	}
+
+	return fmt.Sprintf("Invalid request: %s", strings.Join(msgs, ", "))
+}
+
+func NewValidationError(field, msg string) error {
+	return ValidationError{[]FieldError{{Field: field, Message: msg}}}
+}
+
+// NewValidationErrorWithPrefix returns nil if err is nil.
+// Errors that are not ValidationErrors are attributed to the prefix field itself.
+func NewValidationErrorWithPrefix(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	valErr, ok := err.(ValidationError)
+	if !ok {
+		return NewValidationError(prefix, err.Error())
+	}
+
+	var fields []FieldError
+
+	for _, f := range valErr.Fields {
+		field := prefix + "." + f.Field
+
+		// Avoid 'Tasks.[0]' for nested indices
+		if strings.HasPrefix(f.Field, "[") {
+			field = prefix + f.Field
+		}
+
+		fields = append(fields, FieldError{Field: field, Message: f.Message})
+	}
+
+	return ValidationError{fields}
+}
+
+// MergeValidationErrors combines field errors from multiple errors into one;
+// it returns nil if all errors are nil.
+func MergeValidationErrors(errs ...error) error {
+	var fields []FieldError
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if valErr, ok := err.(ValidationError); ok {
+			fields = append(fields, valErr.Fields...)
+		} else {
+			fields = append(fields, FieldError{Message: err.Error()})
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return ValidationError{fields}
+}
+
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) Add(field, msg string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(msg, args...)})
+}
+
+func (v *validator) Duration(field, val string) {
+	if len(val) > 0 {
+		if _, err := time.ParseDuration(val); err != nil {
+			v.Add(field, "must be a duration suffixed with ms,s,m,h (got '%s')", val)
+		}
+	}
+}
+
+func (v *validator) Match(field, val string, re *regexp.Regexp, desc string) {
+	if len(val) > 0 && !re.MatchString(val) {
+		v.Add(field, "must be %s (got '%s')", desc, val)
+	}
+}
+
+func (v *validator) NonNegative(field string, val int) {
+	if val < 0 {
+		v.Add(field, "must not be negative (got %d)", val)
+	}
+}
+
+func (v validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return ValidationError{v.fields}
+}
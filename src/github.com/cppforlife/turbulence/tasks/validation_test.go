@@ -0,0 +1,90 @@
+package tasks_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cppforlife/turbulence/tasks"
+)
+
+var _ = Describe("Validate", func() {
+	fieldsOf := func(err error) []string {
+		Expect(err).To(HaveOccurred())
+
+		valErr, ok := err.(ValidationError)
+		Expect(ok).To(BeTrue())
+
+		var fields []string
+
+		for _, f := range valErr.Fields {
+			fields = append(fields, f.Field)
+		}
+
+		return fields
+	}
+
+	It("accepts valid options", func() {
+		opts := OptionsSlice{
+			NoopOptions{},
+			KillOptions{},
+			KillProcessOptions{MonitoredProcessName: "*worker*"},
+			StressOptions{Timeout: "10m", NumMemoryWorkers: 1, MemoryWorkerBytes: "10K"},
+			ControlNetOptions{Timeout: "10m", Delay: "50ms", Loss: "20%"},
+			FirewallOptions{Timeout: "1h"},
+			FillDiskOptions{Persistent: true},
+			ShutdownOptions{Sysrq: "b"},
+		}
+
+		Expect(opts.Validate()).ToNot(HaveOccurred())
+	})
+
+	It("requires at least one stress worker", func() {
+		Expect(fieldsOf(StressOptions{}.Validate())).To(Equal([]string{"NumCPUWorkers"}))
+	})
+
+	It("requires stress worker sizes", func() {
+		err := StressOptions{NumMemoryWorkers: 1, NumHDDWorkers: 1, HDDWorkerBytes: "1X"}.Validate()
+		Expect(fieldsOf(err)).To(Equal([]string{"MemoryWorkerBytes", "HDDWorkerBytes"}))
+	})
+
+	It("requires delay or loss for control net", func() {
+		Expect(fieldsOf(ControlNetOptions{}.Validate())).To(Equal([]string{"Delay"}))
+	})
+
+	It("describes expected loss format", func() {
+		err := ControlNetOptions{Loss: "20"}.Validate()
+		Expect(err).To(MatchError("Invalid request: 'Loss' must be a percentage suffixed with % (got '20')"))
+	})
+
+	It("validates ramp", func() {
+		ramp := &RampOptions{Steps: -1, StartPercent: 100, Down: true}
+		err := ControlNetOptions{Delay: "50ms", Ramp: ramp}.Validate()
+		Expect(fieldsOf(err)).To(Equal([]string{
+			"Ramp.Duration", "Ramp.Steps", "Ramp.StartPercent", "Ramp.Down"}))
+
+		ramp = &RampOptions{Duration: "5m", Steps: 5, StartPercent: 10, Down: true}
+		Expect(StressOptions{Timeout: "10m", NumCPUWorkers: 4, Ramp: ramp}.Validate()).ToNot(HaveOccurred())
+	})
+
+	It("rejects unparsable timeouts", func() {
+		err := FirewallOptions{Timeout: "10 minutes"}.Validate()
+		Expect(fieldsOf(err)).To(Equal([]string{"Timeout"}))
+	})
+
+	It("rejects sysrq values that are not a single key", func() {
+		err := ShutdownOptions{Sysrq: "b; reboot"}.Validate()
+		Expect(fieldsOf(err)).To(Equal([]string{"Sysrq"}))
+	})
+
+	It("prefixes fields with task index", func() {
+		var opts OptionsSlice
+
+		err := json.Unmarshal([]byte(`[{"Type":"Noop"},{"Type":"ControlNet","Delay":"50"}]`), &opts)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = NewValidationErrorWithPrefix("Tasks", opts.Validate())
+		Expect(fieldsOf(err)).To(Equal([]string{"Tasks[1].Delay"}))
+	})
+})